@@ -0,0 +1,275 @@
+package main
+
+import (
+	"Flute_go/pkg/alc"
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/tools"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PacketInfo 一个 ALC/LCT 包的解码结果（JSON 输出的字段名即为脚本接口）
+type PacketInfo struct {
+	Index     uint64     `json:"index"`
+	Time      *time.Time `json:"time,omitempty"`
+	Src       string     `json:"src,omitempty"`
+	Dst       string     `json:"dst,omitempty"`
+	Size      int        `json:"size"`
+	Version   uint8      `json:"version"`
+	CCI       string     `json:"cci"`
+	TSI       uint64     `json:"tsi"`
+	TOI       string     `json:"toi"`
+	Codepoint uint8      `json:"codepoint"`
+	FEC       string     `json:"fec,omitempty"`
+
+	CloseObject  bool   `json:"close_object,omitempty"`
+	CloseSession bool   `json:"close_session,omitempty"`
+	HeaderLength uint64 `json:"header_length"`
+
+	Extensions []ExtInfo `json:"extensions,omitempty"`
+	FTI        *FTIInfo  `json:"fti,omitempty"`
+
+	SBN         *uint32 `json:"sbn,omitempty"`
+	ESI         *uint32 `json:"esi,omitempty"`
+	PayloadSize int     `json:"payload_size"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// ExtInfo LCT 头部扩展；已知扩展解出字段，其余给出原始字节
+type ExtInfo struct {
+	HET    uint8  `json:"het"`
+	Name   string `json:"name"`
+	Length int    `json:"length"`
+
+	// EXT_FDT
+	FDTVersion    *uint32 `json:"fdt_version,omitempty"`
+	FDTInstanceID *uint32 `json:"fdt_instance_id,omitempty"`
+	// EXT_CENC
+	Cenc string `json:"cenc,omitempty"`
+	// EXT_TIME
+	SCT   *time.Time `json:"sct,omitempty"`
+	ERTMs *uint32    `json:"ert_ms,omitempty"`
+	SLC   *time.Time `json:"slc,omitempty"`
+
+	Raw string `json:"raw,omitempty"`
+}
+
+// FTIInfo EXT_FTI 中的 OTI 与传输长度
+type FTIInfo struct {
+	FecEncodingID            string `json:"fec_encoding_id"`
+	TransferLength           uint64 `json:"transfer_length"`
+	EncodingSymbolLength     uint16 `json:"encoding_symbol_length"`
+	MaximumSourceBlockLength uint32 `json:"maximum_source_block_length"`
+	MaxNumberOfParitySymbols uint32 `json:"max_number_of_parity_symbols"`
+}
+
+// decodePacket 尽量解出所有字段；LCT 头可解而 ALC 部分出错时仍返回已解出的部分并填写 Error
+// 返回的 *alc.AlcPkt 在 ALC 解析失败时为 nil
+func decodePacket(data []byte) (*PacketInfo, *alc.AlcPkt) {
+	info := &PacketInfo{Size: len(data)}
+	hdr, err := lct.ParseLCTHeader(data)
+	if err != nil {
+		info.Error = err.Error()
+		return info, nil
+	}
+	info.Version = data[0] >> 4
+	info.CCI = hdr.Cci.String()
+	info.TSI = hdr.Tsi
+	info.TOI = hdr.Toi.String()
+	info.Codepoint = hdr.Cp
+	info.CloseObject = hdr.CloseObject
+	info.CloseSession = hdr.CloseSession
+	info.HeaderLength = hdr.Len
+	info.Extensions, err = decodeExtensions(data, hdr)
+	if err != nil {
+		info.Error = err.Error()
+	}
+
+	fecID, err := oti.FECEncodingIDFromByte(hdr.Cp)
+	if err != nil {
+		info.Error = fmt.Sprintf("codepoint %d: %v", hdr.Cp, err)
+		return info, nil
+	}
+	info.FEC = fecID.String()
+
+	pkt, err := alc.ParseAlcPkt(data)
+	if err != nil {
+		info.Error = err.Error()
+		return info, nil
+	}
+	if pkt.Oti != nil && pkt.TransferLength != nil {
+		info.FTI = &FTIInfo{
+			FecEncodingID:            pkt.Oti.FecEncodingID.String(),
+			TransferLength:           *pkt.TransferLength,
+			EncodingSymbolLength:     pkt.Oti.EncodingSymbolLength,
+			MaximumSourceBlockLength: pkt.Oti.MaximumSourceBlockLength,
+			MaxNumberOfParitySymbols: pkt.Oti.MaxNumberOfParitySymbols,
+		}
+	}
+	if pkt.DataPayloadOffset <= len(data) {
+		info.PayloadSize = len(data) - pkt.DataPayloadOffset
+	}
+	if hdr.CloseSession && info.PayloadSize == 0 {
+		return info, pkt
+	}
+	// FEC Payload ID 的格式只取决于 FEC 方案，不需要 FTI
+	if pid, err := alc.GetFecInlinePayloadId(pkt); err == nil {
+		info.SBN, info.ESI = &pid.Sbn, &pid.Esi
+	} else if info.Error == "" {
+		info.Error = fmt.Sprintf("FEC payload ID: %v", err)
+	}
+	return info, pkt
+}
+
+func decodeExtensions(data []byte, hdr *lct.LCTHeader) ([]ExtInfo, error) {
+	var exts []ExtInfo
+	rest := data[hdr.HeaderExtOffset:hdr.Len]
+	for len(rest) >= 4 {
+		het := rest[0]
+		hel := 4
+		if het < 128 {
+			hel = int(rest[1]) << 2
+		}
+		if hel == 0 || hel > len(rest) {
+			return exts, fmt.Errorf("bad LCT extension het=%d hel=%d", het, hel)
+		}
+		exts = append(exts, decodeExt(rest[:hel]))
+		rest = rest[hel:]
+	}
+	return exts, nil
+}
+
+func decodeExt(ext []byte) ExtInfo {
+	e := ExtInfo{HET: ext[0], Length: len(ext)}
+	switch ext[0] {
+	case 0:
+		e.Name = "NOP"
+	case uint8(lct.ExtFdt):
+		e.Name = lct.ExtFdt.String()
+		v := binary.BigEndian.Uint32(ext)
+		version, id := (v>>20)&0xF, v&0xFFFFF
+		e.FDTVersion, e.FDTInstanceID = &version, &id
+	case uint8(lct.ExtCenc):
+		e.Name = lct.ExtCenc.String()
+		e.Cenc = lct.Cenc(ext[1]).String()
+	case uint8(lct.ExtTime):
+		e.Name = "Time"
+		decodeTime(&e, ext)
+	case uint8(lct.ExtFti), uint8(lct.ExtAuth):
+		e.Name = lct.Ext(ext[0]).String()
+	default:
+		e.Name = "Unknown"
+		e.Raw = hex.EncodeToString(ext)
+	}
+	return e
+}
+
+// decodeTime EXT_TIME（RFC 5651）：Use 字段依次标记 SCT-High、SCT-Low、ERT、SLC 是否存在
+func decodeTime(e *ExtInfo, ext []byte) {
+	use := ext[2]
+	fields := ext[4:]
+	next := func() (uint32, bool) {
+		if len(fields) < 4 {
+			return 0, false
+		}
+		v := binary.BigEndian.Uint32(fields)
+		fields = fields[4:]
+		return v, true
+	}
+	var sctHi, sctLo uint32
+	hasHi := use&0x80 != 0
+	if hasHi {
+		sctHi, hasHi = next()
+	}
+	if use&0x40 != 0 {
+		sctLo, _ = next()
+	}
+	if hasHi {
+		if tm, err := tools.NTPToSystemTime(uint64(sctHi)<<32 | uint64(sctLo)); err == nil {
+			e.SCT = &tm
+		}
+	}
+	if use&0x20 != 0 {
+		if ert, ok := next(); ok {
+			e.ERTMs = &ert
+		}
+	}
+	if use&0x10 != 0 {
+		if slc, ok := next(); ok {
+			if tm, err := tools.NTPToSystemTime(uint64(slc) << 32); err == nil {
+				e.SLC = &tm
+			}
+		}
+	}
+}
+
+// String 单行文本格式
+func (p *PacketInfo) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#%d", p.Index)
+	if p.Time != nil {
+		fmt.Fprintf(&b, " %s", p.Time.Format("15:04:05.000000"))
+	}
+	if p.Src != "" || p.Dst != "" {
+		fmt.Fprintf(&b, " %s > %s", p.Src, p.Dst)
+	}
+	fmt.Fprintf(&b, " len=%d", p.Size)
+	if p.CCI == "" {
+		fmt.Fprintf(&b, " error=%q", p.Error)
+		return b.String()
+	}
+	fmt.Fprintf(&b, " v%d tsi=%d toi=%s cci=%s cp=%d", p.Version, p.TSI, p.TOI, p.CCI, p.Codepoint)
+	if p.FEC != "" {
+		fmt.Fprintf(&b, "(%s)", p.FEC)
+	}
+	if p.CloseObject {
+		b.WriteString(" B")
+	}
+	if p.CloseSession {
+		b.WriteString(" A")
+	}
+	for _, e := range p.Extensions {
+		b.WriteString(" [" + e.String() + "]")
+	}
+	if p.FTI != nil {
+		fmt.Fprintf(&b, " fti={%s L=%d E=%d B=%d parity=%d}", p.FTI.FecEncodingID, p.FTI.TransferLength,
+			p.FTI.EncodingSymbolLength, p.FTI.MaximumSourceBlockLength, p.FTI.MaxNumberOfParitySymbols)
+	}
+	if p.SBN != nil {
+		fmt.Fprintf(&b, " sbn=%d esi=%d", *p.SBN, *p.ESI)
+	}
+	fmt.Fprintf(&b, " payload=%d", p.PayloadSize)
+	if p.Error != "" {
+		fmt.Fprintf(&b, " error=%q", p.Error)
+	}
+	return b.String()
+}
+
+func (e ExtInfo) String() string {
+	switch {
+	case e.FDTInstanceID != nil:
+		return fmt.Sprintf("FDT id=%d v=%d", *e.FDTInstanceID, *e.FDTVersion)
+	case e.Cenc != "":
+		return "CENC " + e.Cenc
+	case e.HET == uint8(lct.ExtTime):
+		s := "TIME"
+		if e.SCT != nil {
+			s += " sct=" + e.SCT.UTC().Format(time.RFC3339Nano)
+		}
+		if e.ERTMs != nil {
+			s += fmt.Sprintf(" ert=%dms", *e.ERTMs)
+		}
+		if e.SLC != nil {
+			s += " slc=" + e.SLC.UTC().Format(time.RFC3339)
+		}
+		return s
+	case e.Raw != "":
+		return fmt.Sprintf("het=%d %s", e.HET, e.Raw)
+	}
+	return fmt.Sprintf("%s hel=%d", strings.ToUpper(e.Name), e.Length)
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"Flute_go/pkg/alc"
+	"Flute_go/pkg/fec"
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/object"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/receiver"
+	"Flute_go/pkg/tools"
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// FdtReport 一个收齐的 FDT-Instance
+type FdtReport struct {
+	TSI        uint64        `json:"tsi"`
+	InstanceID uint32        `json:"fdt_instance_id"`
+	Version    uint32        `json:"fdt_version"`
+	Expires    string        `json:"expires,omitempty"`
+	Files      []FdtFileInfo `json:"files,omitempty"`
+	XML        string        `json:"xml"`
+	Error      string        `json:"error,omitempty"`
+	// 仅在给出 -fdt-key 时验证
+	Signature *FdtSignatureInfo `json:"signature,omitempty"`
+}
+
+// FdtSignatureInfo 签名验证结果；验证失败时 Files 为空，XML 仍为收到的原文
+type FdtSignatureInfo struct {
+	Valid     bool   `json:"valid"`
+	KeyID     string `json:"key_id,omitempty"`
+	Algorithm string `json:"algorithm,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// FdtFileInfo FDT 中的文件项摘要
+type FdtFileInfo struct {
+	TOI             string  `json:"toi"`
+	ContentLocation string  `json:"content_location"`
+	ContentLength   *uint64 `json:"content_length,omitempty"`
+	TransferLength  *uint64 `json:"transfer_length,omitempty"`
+	ContentType     *string `json:"content_type,omitempty"`
+	ContentEncoding *string `json:"content_encoding,omitempty"`
+}
+
+type fdtKey struct {
+	tsi uint64
+	id  uint32
+}
+
+// fdtAssembler 从 TOI 0 的包重组 FDT-Instance；依赖包内 FTI，目前支持 RS28（NoCode 在 alc 中没有编解码器）
+type fdtAssembler struct {
+	pending map[fdtKey]*fdtRx
+	done    map[fdtKey]bool
+	trust   *object.TrustStore // 非 nil 时验证签名
+}
+
+type fdtRx struct {
+	version uint32
+	cenc    lct.Cenc
+	esl     uint64
+	blocks  []*fdtBlock
+	missing int
+}
+
+type fdtBlock struct {
+	size uint64
+	rs   *fec.RSGalois8Codec
+	data []byte
+}
+
+func newFdtAssembler(trust *object.TrustStore) *fdtAssembler {
+	return &fdtAssembler{pending: make(map[fdtKey]*fdtRx), done: make(map[fdtKey]bool), trust: trust}
+}
+
+// push 返回收齐的 FDT-Instance，否则为 nil；同一实例只报告一次
+func (a *fdtAssembler) push(pkt *alc.AlcPkt, sbn, esi uint32) *FdtReport {
+	if pkt.Lct.Toi != lct.TOI_FDT || pkt.FdtInfo == nil {
+		return nil
+	}
+	key := fdtKey{pkt.Lct.Tsi, pkt.FdtInfo.FdtInstanceID}
+	if a.done[key] {
+		return nil
+	}
+	rx := a.pending[key]
+	if rx == nil {
+		if pkt.Oti == nil || pkt.TransferLength == nil {
+			return nil
+		}
+		var err error
+		if rx, err = newFdtRx(*pkt.Oti, *pkt.TransferLength); err != nil {
+			a.done[key] = true
+			return &FdtReport{TSI: key.tsi, InstanceID: key.id, Version: pkt.FdtInfo.Version, Error: err.Error()}
+		}
+		rx.version = pkt.FdtInfo.Version
+		if pkt.Cenc != nil {
+			rx.cenc = *pkt.Cenc
+		}
+		a.pending[key] = rx
+	}
+	if int(sbn) >= len(rx.blocks) || pkt.DataPayloadOffset > len(pkt.Data) {
+		return nil
+	}
+	b := rx.blocks[sbn]
+	payload := pkt.Data[pkt.DataPayloadOffset:]
+	if b.data != nil || uint64(len(payload)) != rx.esl || int(esi) >= len(b.rs.DecodeShards) {
+		return nil
+	}
+	b.rs.PushSymbol(payload, esi)
+	if !b.rs.CanDecode() || !b.rs.Decode() {
+		return nil
+	}
+	block, _ := b.rs.SourceBlock()
+	b.data, b.rs = block[:b.size], nil
+	if rx.missing--; rx.missing > 0 {
+		return nil
+	}
+
+	delete(a.pending, key)
+	a.done[key] = true
+	var raw []byte
+	for _, b := range rx.blocks {
+		raw = append(raw, b.data...)
+	}
+	return buildFdtReport(key, rx.version, raw, rx.cenc, a.trust)
+}
+
+func newFdtRx(o oti.Oti, length uint64) (*fdtRx, error) {
+	switch o.FecEncodingID {
+	case oti.ReedSolomonGF28, oti.ReedSolomonGF28UnderSpecified:
+	default:
+		return nil, fmt.Errorf("FDT reassembly not supported for %s", o.FecEncodingID)
+	}
+	if o.EncodingSymbolLength == 0 || o.MaximumSourceBlockLength == 0 || length == 0 {
+		return nil, errors.New("invalid FTI")
+	}
+	esl := uint64(o.EncodingSymbolLength)
+	aLarge, aSmall, nbALarge, nbBlocks := object.BlockPartitioning(uint64(o.MaximumSourceBlockLength), length, esl)
+	rx := &fdtRx{esl: esl, missing: int(nbBlocks)}
+	remaining := length
+	for sbn := uint64(0); sbn < nbBlocks; sbn++ {
+		blockLen := aSmall
+		if sbn < nbALarge {
+			blockLen = aLarge
+		}
+		size := min(blockLen*esl, remaining)
+		remaining -= size
+		rs, err := fec.NewRSGalois8Codec(uint(tools.DivCeil(size, esl)), uint(o.MaxNumberOfParitySymbols), uint(esl))
+		if err != nil {
+			return nil, err
+		}
+		rs.Sbn = uint32(sbn)
+		rx.blocks = append(rx.blocks, &fdtBlock{size: size, rs: rs})
+	}
+	return rx, nil
+}
+
+func buildFdtReport(key fdtKey, version uint32, raw []byte, cenc lct.Cenc, trust *object.TrustStore) *FdtReport {
+	r := &FdtReport{TSI: key.tsi, InstanceID: key.id, Version: version}
+	data, err := receiver.Decompress(raw, cenc)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.XML = indentXML(data)
+	inst, err := receiver.ParseFdt(nil, key.tsi, key.id, data, trust, time.Now())
+	var sigErr *object.FdtSignatureError
+	switch {
+	case errors.As(err, &sigErr):
+		r.Signature = &FdtSignatureInfo{KeyID: sigErr.KeyID, Algorithm: sigErr.Algorithm, Error: sigErr.Error()}
+		return r
+	case err != nil:
+		r.Error = err.Error()
+		return r
+	case trust != nil:
+		r.Signature = &FdtSignatureInfo{Valid: true, KeyID: inst.Signature.KeyID, Algorithm: inst.Signature.Algorithm}
+	}
+	r.Expires = inst.Expires
+	for _, f := range inst.Files {
+		r.Files = append(r.Files, FdtFileInfo{
+			TOI:             f.TOI,
+			ContentLocation: f.ContentLocation,
+			ContentLength:   f.ContentLength,
+			TransferLength:  f.TransferLength,
+			ContentType:     f.ContentType,
+			ContentEncoding: f.ContentEncoding,
+		})
+	}
+	return r
+}
+
+// indentXML 重新缩进 XML，保留原有的命名空间前缀；解析失败时原样返回
+func indentXML(data []byte) string {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var out bytes.Buffer
+	depth := 0
+	open := false // 上一个开始标签还没写 '>'
+	var text []byte
+	indent := func() { out.WriteString(strings.Repeat("  ", depth)) }
+	qname := func(n xml.Name) string {
+		if n.Space != "" {
+			return n.Space + ":" + n.Local
+		}
+		return n.Local
+	}
+	closeOpen := func() {
+		if open {
+			out.WriteString(">\n")
+			open = false
+		}
+		if len(text) > 0 {
+			indent()
+			out.Write(text)
+			out.WriteString("\n")
+			text = nil
+		}
+	}
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return string(data)
+		}
+		switch t := tok.(type) {
+		case xml.ProcInst:
+			fmt.Fprintf(&out, "<?%s %s?>\n", t.Target, t.Inst)
+		case xml.StartElement:
+			closeOpen()
+			indent()
+			out.WriteString("<" + qname(t.Name))
+			for _, a := range t.Attr {
+				out.WriteString(" " + qname(a.Name) + `="`)
+				xml.EscapeText(&out, []byte(a.Value))
+				out.WriteString(`"`)
+			}
+			open = true
+			depth++
+		case xml.CharData:
+			if trimmed := bytes.TrimSpace(t); len(trimmed) > 0 {
+				var esc bytes.Buffer
+				xml.EscapeText(&esc, trimmed)
+				text = esc.Bytes()
+			}
+		case xml.EndElement:
+			if !open {
+				closeOpen()
+			}
+			depth--
+			switch {
+			case open && len(text) == 0:
+				out.WriteString("/>\n")
+			case open:
+				out.WriteString(">")
+				out.Write(text)
+				out.WriteString("</" + qname(t.Name) + ">\n")
+			default:
+				indent()
+				out.WriteString("</" + qname(t.Name) + ">\n")
+			}
+			open, text = false, nil
+		case xml.Comment:
+			closeOpen()
+			indent()
+			fmt.Fprintf(&out, "<!--%s-->\n", t)
+		}
+	}
+	return strings.TrimRight(out.String(), "\n")
+}
+
+// String 文本格式：摘要行 + 缩进后的 XML
+func (r *FdtReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FDT-Instance tsi=%d id=%d v=%d", r.TSI, r.InstanceID, r.Version)
+	if r.Expires != "" {
+		fmt.Fprintf(&b, " expires=%s", r.Expires)
+	}
+	fmt.Fprintf(&b, " files=%d", len(r.Files))
+	if r.Error != "" {
+		fmt.Fprintf(&b, " error=%q", r.Error)
+	}
+	if sig := r.Signature; sig != nil {
+		if sig.Valid {
+			fmt.Fprintf(&b, " signature=valid key=%q alg=%s", sig.KeyID, sig.Algorithm)
+		} else {
+			fmt.Fprintf(&b, " signature=rejected error=%q", sig.Error)
+		}
+	}
+	if r.XML != "" {
+		b.WriteString("\n")
+		b.WriteString(r.XML)
+	}
+	return b.String()
+}
+
+// keyList 可重复的 -fdt-key 参数
+type keyList []string
+
+func (l *keyList) String() string     { return strings.Join(*l, ",") }
+func (l *keyList) Set(v string) error { *l = append(*l, v); return nil }
+
+// loadTrustStore 解析 KEYID=FILE 形式的公钥（PEM 编码的 PKIX，Ed25519 或 ECDSA）；没有给出时返回 nil
+func loadTrustStore(specs []string) (*object.TrustStore, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	store := object.NewTrustStore()
+	for _, spec := range specs {
+		keyID, path, ok := strings.Cut(spec, "=")
+		if !ok || keyID == "" || path == "" {
+			return nil, fmt.Errorf("%q: expected KEYID=FILE", spec)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s: no PEM block", path)
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if err := store.Add(keyID, pub); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return store, nil
+}
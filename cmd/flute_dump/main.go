@@ -0,0 +1,353 @@
+// flute_dump 逐包解码 ALC/LCT：从 UDP 套接字实时读取，或从 pcap/pcapng、MPEG-2 TS（MPE/ULE）离线读取，
+// 输出 LCT 头、头部扩展、FTI、SBN/ESI 与载荷长度，可选重组并打印 FDT-Instance
+package main
+
+import (
+	"Flute_go/pkg/mpegts"
+	"Flute_go/pkg/object"
+	"Flute_go/pkg/pcap"
+	t "Flute_go/pkg/type"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type options struct {
+	json    bool
+	fdt     bool
+	fdtOnly bool
+	count   uint64
+	tsi     map[uint64]bool
+	toi     map[string]bool    // Uint128.String()
+	trust   *object.TrustStore // -fdt-key，非 nil 时验证 FDT 签名
+}
+
+func main() {
+	listen := flag.String("listen", "", "read live UDP on this address, e.g. 224.0.0.1:3400 (multicast groups are joined)")
+	ifaceName := flag.String("iface", "", "network interface for joining the multicast group")
+	pcapPath := flag.String("pcap", "", "read packets from this pcap/pcapng file ('-' = stdin)")
+	tsPath := flag.String("ts", "", "read MPE/ULE-encapsulated packets from this MPEG-2 TS file ('-' = stdin)")
+	tsPID := flag.Uint("pid", 0, "with -ts (required): PID carrying the encapsulated datagrams, 0x10-0x1FFE")
+	tsMode := flag.String("ts-mode", "mpe", "with -ts: encapsulation, mpe or ule")
+	dstFilter := flag.String("dst", "", "with -pcap/-ts: only UDP datagrams sent to this address, e.g. 224.0.0.1:3400 or :3400")
+	jsonOut := flag.Bool("json", false, "print one JSON object per line")
+	fdt := flag.Bool("fdt", false, "reassemble and print FDT-Instances")
+	fdtOnly := flag.Bool("fdt-only", false, "like -fdt, but do not print individual packets")
+	tsiList := flag.String("tsi", "", "only packets with these TSIs (comma-separated)")
+	toiList := flag.String("toi", "", "only packets with these TOIs (comma-separated, decimal or 32-digit hex)")
+	count := flag.Uint64("count", 0, "stop after this many packets have been printed (0 = no limit)")
+	var fdtKeys keyList
+	flag.Var(&fdtKeys, "fdt-key", "with -fdt: verify FDT signatures against this PEM public key, as KEYID=FILE (repeatable)")
+	flag.Parse()
+
+	opts := options{json: *jsonOut, fdt: *fdt || *fdtOnly, fdtOnly: *fdtOnly, count: *count}
+	var err error
+	if opts.tsi, err = parseTSIs(*tsiList); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -tsi: %v\n", err)
+		os.Exit(2)
+	}
+	if opts.toi, err = parseTOIs(*toiList); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -toi: %v\n", err)
+		os.Exit(2)
+	}
+	if opts.trust, err = loadTrustStore(fdtKeys); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -fdt-key: %v\n", err)
+		os.Exit(2)
+	}
+	sources := 0
+	for _, s := range []string{*listen, *pcapPath, *tsPath} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		fmt.Fprintln(os.Stderr, "exactly one of -listen, -pcap or -ts is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *tsPath != "" {
+		if err := checkPID(*tsPID); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -pid: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var src packetSource
+	switch {
+	case *pcapPath != "":
+		src, err = openFile(*pcapPath, *dstFilter, func(r io.Reader) (pcap.Source, error) {
+			return pcap.NewReader(r)
+		})
+	case *tsPath != "":
+		var mode mpegts.Mode
+		if mode, err = mpegts.ParseMode(*tsMode); err != nil {
+			break
+		}
+		src, err = openFile(*tsPath, *dstFilter, func(r io.Reader) (pcap.Source, error) {
+			return mpegts.NewReader(r, uint16(*tsPID), mode)
+		})
+	default:
+		src, err = openLive(ctx, *listen, *ifaceName)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	defer src.Close()
+
+	if err := dump(ctx, src, os.Stdout, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// packetSource 数据报来源；结束时 Next 返回 io.EOF
+type packetSource interface {
+	Next() (*pcap.Packet, error)
+	Close() error
+}
+
+// dump 逐包解码并输出，直到来源结束、ctx 取消或达到 -count
+func dump(ctx context.Context, src packetSource, w io.Writer, opts options) error {
+	enc := json.NewEncoder(w)
+	emit := func(kind string, v fmt.Stringer) error {
+		if !opts.json {
+			_, err := fmt.Fprintln(w, v)
+			return err
+		}
+		return enc.Encode(struct {
+			Type string `json:"type"`
+			Data any    `json:"data"`
+		}{kind, v})
+	}
+
+	fdts := newFdtAssembler(opts.trust)
+	var index, printed uint64
+	for opts.count == 0 || printed < opts.count {
+		p, err := src.Next()
+		if err == io.EOF || (err != nil && ctx.Err() != nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		index++
+		info, pkt := decodePacket(p.Payload)
+		info.Index = index
+		if !p.Timestamp.IsZero() {
+			ts := p.Timestamp
+			info.Time = &ts
+		}
+		if p.Src.IsValid() {
+			info.Src = p.Src.String()
+		}
+		if p.Dst.IsValid() {
+			info.Dst = p.Dst.String()
+		}
+
+		if !opts.match(info) {
+			continue
+		}
+		if !opts.fdtOnly {
+			if err := emit("packet", info); err != nil {
+				return err
+			}
+			printed++
+		}
+		if opts.fdt && pkt != nil && info.SBN != nil {
+			if r := fdts.push(pkt, *info.SBN, *info.ESI); r != nil {
+				if err := emit("fdt", r); err != nil {
+					return err
+				}
+				if opts.fdtOnly {
+					printed++
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// match 设置了过滤条件时，连 LCT 头都解不出的包不输出
+func (o *options) match(info *PacketInfo) bool {
+	if len(o.tsi) > 0 && (info.CCI == "" || !o.tsi[info.TSI]) {
+		return false
+	}
+	return len(o.toi) == 0 || (info.CCI != "" && o.toi[info.TOI])
+}
+
+func parseTSIs(s string) (map[uint64]bool, error) {
+	m := make(map[uint64]bool)
+	for _, f := range splitList(s) {
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		m[v] = true
+	}
+	return m, nil
+}
+
+// parseTOIs 十进制，或与 FDT 中 TOI 属性相同的 32 位十六进制
+func parseTOIs(s string) (map[string]bool, error) {
+	m := make(map[string]bool)
+	for _, f := range splitList(s) {
+		if len(f) == 32 {
+			hi, err1 := strconv.ParseUint(f[:16], 16, 64)
+			lo, err2 := strconv.ParseUint(f[16:], 16, 64)
+			if err := errors.Join(err1, err2); err != nil {
+				return nil, err
+			}
+			m[t.Uint128{High: hi, Low: lo}.String()] = true
+			continue
+		}
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		m[t.FromUint64(v).String()] = true
+	}
+	return m, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// pcapSource 离线来源：pcap/pcapng 抓包或 TS 解封装
+type pcapSource struct {
+	r   pcap.Source
+	f   *os.File
+	dst netip.AddrPort
+}
+
+func openFile(path, dst string, newReader func(io.Reader) (pcap.Source, error)) (*pcapSource, error) {
+	s := &pcapSource{f: os.Stdin}
+	if dst != "" {
+		host, port, err := net.SplitHostPort(dst)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -dst: %w", err)
+		}
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -dst port: %w", err)
+		}
+		var addr netip.Addr
+		if host != "" {
+			if addr, err = netip.ParseAddr(host); err != nil {
+				return nil, fmt.Errorf("invalid -dst address: %w", err)
+			}
+		}
+		s.dst = netip.AddrPortFrom(addr, uint16(p))
+	}
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		s.f = f
+	}
+	r, err := newReader(s.f)
+	if err != nil {
+		s.f.Close()
+		return nil, err
+	}
+	s.r = r
+	return s, nil
+}
+
+func (s *pcapSource) Next() (*pcap.Packet, error) {
+	for {
+		p, err := s.r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if s.dst.Port() != 0 && s.dst.Port() != p.Dst.Port() {
+			continue
+		}
+		if s.dst.Addr().IsValid() && s.dst.Addr() != p.Dst.Addr() {
+			continue
+		}
+		return p, nil
+	}
+}
+
+func (s *pcapSource) Close() error { return s.f.Close() }
+
+type liveSource struct {
+	conn net.PacketConn
+	dst  netip.AddrPort
+	buf  []byte
+}
+
+func openLive(ctx context.Context, addr, ifaceName string) (*liveSource, error) {
+	uaddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s failed: %w", addr, err)
+	}
+	var conn net.PacketConn
+	if uaddr.IP.IsMulticast() {
+		var ifi *net.Interface
+		if ifaceName != "" {
+			if ifi, err = net.InterfaceByName(ifaceName); err != nil {
+				return nil, err
+			}
+		}
+		conn, err = net.ListenMulticastUDP("udp", ifi, uaddr)
+	} else {
+		conn, err = net.ListenUDP("udp", uaddr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listen %s failed: %w", addr, err)
+	}
+	fmt.Fprintf(os.Stderr, "[flute-dump] listening on %s\n", uaddr)
+	// 取消时关闭套接字以唤醒阻塞中的读取
+	context.AfterFunc(ctx, func() { conn.Close() })
+	return &liveSource{conn: conn, dst: uaddr.AddrPort(), buf: make([]byte, 65536)}, nil
+}
+
+func (s *liveSource) Next() (*pcap.Packet, error) {
+	n, from, err := s.conn.ReadFrom(s.buf)
+	if err != nil {
+		return nil, err
+	}
+	p := &pcap.Packet{Timestamp: time.Now(), Dst: s.dst, Payload: append([]byte(nil), s.buf[:n]...)}
+	if ua, ok := from.(*net.UDPAddr); ok {
+		p.Src = ua.AddrPort()
+	}
+	return p, nil
+}
+
+func (s *liveSource) Close() error { return s.conn.Close() }
+
+// checkPID -pid 默认 0 是 PAT 的 PID，不会携带 MPE/ULE，因此与 -ts 一起时必须显式给出
+func checkPID(pid uint) error {
+	if pid == 0 {
+		return errors.New("required with -ts")
+	}
+	if pid < mpegts.MinPID || pid > mpegts.MaxPID {
+		return fmt.Errorf("%#x outside %#x-%#x", pid, mpegts.MinPID, mpegts.MaxPID)
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"Flute_go/pkg/clock"
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/mpegts"
+	"Flute_go/pkg/object"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/pcap"
+	"Flute_go/pkg/sender"
+	"Flute_go/pkg/transport"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// capture 用 RS28 发送一个文件并记录为 pcapng
+func capture(t *testing.T) []byte {
+	return captureSigned(t, nil)
+}
+
+// captureSigned signer 非 nil 时对 FDT-Instance 签名
+func captureSigned(t *testing.T, signer *object.FdtSigner) []byte {
+	t.Helper()
+	clk := clock.NewVirtual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	cfg := sender.DefaultConfig()
+	cfg.Clock = clk
+	cfg.FDTSigner = signer
+	o, err := oti.NewReedSolomonRS28(512, 20, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := transport.NewUDPEndpoint(nil, "224.0.0.1", 3400)
+	s := sender.NewSender(endpoint, 7, o, &cfg)
+	u, _ := url.Parse("file:///dump/hello.txt")
+	obj, err := sender.CreateFromBuffer(bytes.Repeat([]byte("hello flute "), 300), "text/plain", u, 1,
+		nil, nil, nil, nil, lct.CencNull, true, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddObject(0, obj); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Publish(clk.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w, err := pcap.NewEndpointWriter(&buf, endpoint, 3400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for data := s.Read(clk.Now()); data != nil; data = s.Read(clk.Now()) {
+		if err := w.WritePacket(clk.Now(), data); err != nil {
+			t.Fatal(err)
+		}
+		clk.Advance(time.Millisecond)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type record struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+func runDump(t *testing.T, data []byte, opts options) []record {
+	t.Helper()
+	r, err := pcap.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runSource(t, r, opts)
+}
+
+func runSource(t *testing.T, r pcap.Source, opts options) []record {
+	t.Helper()
+	var out bytes.Buffer
+	opts.json = true
+	if err := dump(context.Background(), &pcapSource{r: r}, &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	var recs []record
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var rec record
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestDumpPacketsAndFdt(t *testing.T) {
+	recs := runDump(t, capture(t), options{fdt: true})
+	var packets, fdts int
+	var sawFdtExt, sawData bool
+	for _, rec := range recs {
+		switch rec.Type {
+		case "packet":
+			packets++
+			var p PacketInfo
+			if err := json.Unmarshal(rec.Data, &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Error != "" || p.TSI != 7 || p.FTI == nil || p.SBN == nil || p.FEC != oti.ReedSolomonGF28.String() {
+				t.Fatalf("unexpected packet: %s", rec.Data)
+			}
+			if p.Dst != "224.0.0.1:3400" || p.Time == nil {
+				t.Fatalf("missing capture metadata: %s", rec.Data)
+			}
+			for _, e := range p.Extensions {
+				if e.FDTInstanceID != nil {
+					sawFdtExt = true
+				}
+			}
+			if p.TOI != lct.TOI_FDT.String() && p.PayloadSize == 512 {
+				sawData = true
+			}
+		case "fdt":
+			fdts++
+			var r FdtReport
+			if err := json.Unmarshal(rec.Data, &r); err != nil {
+				t.Fatal(err)
+			}
+			if r.Error != "" || r.TSI != 7 || len(r.Files) != 1 || r.Files[0].ContentLocation != "file:///dump/hello.txt" {
+				t.Fatalf("unexpected FDT: %s", rec.Data)
+			}
+			if !strings.Contains(r.XML, "\n  <File") {
+				t.Fatalf("FDT XML not indented:\n%s", r.XML)
+			}
+		}
+	}
+	if packets == 0 || fdts != 1 || !sawFdtExt || !sawData {
+		t.Fatalf("packets=%d fdts=%d fdtExt=%v data=%v", packets, fdts, sawFdtExt, sawData)
+	}
+}
+
+func TestDumpFilters(t *testing.T) {
+	data := capture(t)
+	toi, err := parseTOIs("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range runDump(t, data, options{toi: toi}) {
+		var p PacketInfo
+		json.Unmarshal(rec.Data, &p)
+		if p.TOI != lct.TOI_FDT.String() {
+			t.Fatalf("TOI filter let through %s", p.TOI)
+		}
+	}
+
+	tsi, _ := parseTSIs("8")
+	if recs := runDump(t, data, options{tsi: tsi}); len(recs) != 0 {
+		t.Fatalf("TSI filter let through %d packets", len(recs))
+	}
+
+	recs := runDump(t, data, options{fdtOnly: true, fdt: true, count: 1})
+	if len(recs) != 1 || recs[0].Type != "fdt" {
+		t.Fatalf("fdt-only: got %d records", len(recs))
+	}
+}
+
+// 抓包转成 ULE 封装的 TS 后，解出的包与 FDT 与直接读抓包一致
+func TestDumpMpegTS(t *testing.T) {
+	data := capture(t)
+	want := runDump(t, data, options{fdt: true})
+
+	r, err := pcap.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ts bytes.Buffer
+	enc, err := mpegts.NewEncapsulator(&ts, mpegts.Config{PID: 0x300, Mode: mpegts.ULE, Packing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conn *mpegts.Conn
+	for {
+		p, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conn == nil {
+			if conn, err = mpegts.NewConn(enc, p.Src, p.Dst); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := conn.WriteTo(p.Payload, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Close()
+
+	for _, pid := range []uint{0, 0x0F, 0x1FFF, 0x10300} {
+		if checkPID(pid) == nil {
+			t.Fatalf("-pid %#x must be rejected", pid)
+		}
+	}
+	if err := checkPID(0x300); err != nil {
+		t.Fatalf("checkPID failed: %v", err)
+	}
+	tr, err := mpegts.NewReader(&ts, 0x300, mpegts.ULE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := runSource(t, tr, options{fdt: true})
+	if len(got) != len(want) {
+		t.Fatalf("got %d records from TS, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].Type != want[i].Type {
+			t.Fatalf("record %d: type %s, want %s", i, got[i].Type, want[i].Type)
+		}
+	}
+}
+
+// -fdt-key 给出时验证 FDT 签名：可信密钥报告有效，未知密钥报告拒绝且不列出文件
+func TestDumpFdtSignature(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	data := captureSigned(t, &object.FdtSigner{KeyID: "k1", Key: priv})
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "k1.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fdtReport := func(specs ...string) FdtReport {
+		t.Helper()
+		trust, err := loadTrustStore(specs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs := runDump(t, data, options{fdt: true, fdtOnly: true, trust: trust})
+		if len(recs) != 1 {
+			t.Fatalf("expected one FDT, got %d", len(recs))
+		}
+		var r FdtReport
+		if err := json.Unmarshal(recs[0].Data, &r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	if r := fdtReport(); r.Signature != nil || len(r.Files) != 1 {
+		t.Fatalf("signature reported without -fdt-key: %+v", r)
+	}
+	r := fdtReport("k1=" + path)
+	if r.Signature == nil || !r.Signature.Valid || r.Signature.KeyID != "k1" ||
+		r.Signature.Algorithm != object.FdtSigEd25519 || len(r.Files) != 1 {
+		t.Fatalf("trusted signature not verified: %+v", r.Signature)
+	}
+	r = fdtReport("other=" + path)
+	if r.Signature == nil || r.Signature.Valid || r.Signature.KeyID != "k1" || len(r.Files) != 0 {
+		t.Fatalf("unknown key not rejected: %+v", r)
+	}
+	if !strings.Contains(r.String(), "signature=rejected") {
+		t.Fatalf("text output misses the rejection: %s", r.String())
+	}
+
+	if _, err := loadTrustStore([]string{path}); err == nil {
+		t.Fatal("key without KEYID accepted")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"Flute_go/pkg/clock"
+	"Flute_go/pkg/pcap"
+	"Flute_go/pkg/transport"
+	"net"
+	"net/netip"
+	"os"
+)
+
+// captureConn 记录写出的每个包到 pcapng 文件，Close 时一并关闭文件
+type captureConn struct {
+	*pcap.CaptureConn
+	f *os.File
+}
+
+func (c *captureConn) Close() error {
+	err := c.CaptureConn.Close()
+	if ferr := c.f.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// openCapture path 为空时原样返回 inner；合成头部的源地址取本地 socket 地址，目的地址取 raddr
+func openCapture(path string, inner transport.PacketConn, raddr *net.UDPAddr, clk clock.Clock) (transport.PacketConn, error) {
+	if path == "" {
+		return inner, nil
+	}
+	dst := raddr.AddrPort()
+	dst = netip.AddrPortFrom(dst.Addr().Unmap(), dst.Port())
+	var src netip.AddrPort
+	if la, ok := inner.LocalAddr().(*net.UDPAddr); ok {
+		src = la.AddrPort()
+	}
+	srcAddr := src.Addr().Unmap()
+	if !srcAddr.IsValid() || srcAddr.Is4() != dst.Addr().Is4() {
+		// 绑定在通配地址（如双栈 "::"）时按目的地址族取未指定地址
+		srcAddr = netip.IPv4Unspecified()
+		if dst.Addr().Is6() {
+			srcAddr = netip.IPv6Unspecified()
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := pcap.NewWriter(f, netip.AddrPortFrom(srcAddr, src.Port()), dst)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &captureConn{CaptureConn: pcap.NewCaptureConn(inner, w, clk), f: f}, nil
+}
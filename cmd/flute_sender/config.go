@@ -0,0 +1,397 @@
+package main
+
+import (
+	"Flute_go/pkg/clock"
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/object"
+	"Flute_go/pkg/profile"
+	"Flute_go/pkg/sender"
+	t "Flute_go/pkg/type"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type AppConfig struct {
+	Sender SenderConfigSection `yaml:"sender"`
+}
+
+type SenderConfigSection struct {
+	Network        SenderNetworkConfig `yaml:"network"`
+	Fec            SenderFecConfig     `yaml:"fec"`
+	Flute          SenderFluteConfig   `yaml:"flute"`
+	Logging        SenderLoggingConfig `yaml:"logging"`
+	Files          []FileConfig        `yaml:"files"`
+	MaxRateKbps    *uint32             `yaml:"max_rate_kbps,omitempty"`        // 额外限速
+	SendIntervalUs *uint64             `yaml:"send_interval_micros,omitempty"` // 固定发包间隔，与 max_rate_kbps 同时设置时取较慢者
+	Watch          *WatchConfig        `yaml:"watch,omitempty"`                // 监视目录，自动增删对象
+	StateFile      string              `yaml:"state_file,omitempty"`           // 状态日志，重启后沿用 TOI/FDT ID
+	Security       *SecurityConfig     `yaml:"security,omitempty"`             // FDT 签名、TESLA、内容密钥
+}
+
+type SenderNetworkConfig struct {
+	Destination string `yaml:"destination"`  // "224.0.0.1:3400" / "192.168.0.10:9000"
+	BindAddress string `yaml:"bind_address"` // "0.0.0.0"
+	BindPort    uint16 `yaml:"bind_port"`    // 0 = 任意
+	MTU         int    `yaml:"mtu"`          // 路径 MTU，0 = 不检查
+	IPv6        bool   `yaml:"ipv6"`
+	// 可选：发送侧网络损伤仿真
+	Impairment *ImpairmentConfig `yaml:"impairment,omitempty"`
+	// 可选：封装为 MPEG-2 TS 写入文件/管道，不再打开 UDP socket
+	MpegTS *MpegTSConfig `yaml:"mpegts,omitempty"`
+}
+
+type SenderFecConfig struct {
+	Type                     string `yaml:"type"` // "no_code" | "reed_solomon_gf28" | "reed_solomon_gf28_under_specified" | "auto"
+	EncodingSymbolLength     uint16 `yaml:"encoding_symbol_length"`
+	MaxNumberOfParitySymbols uint32 `yaml:"max_number_of_parity_symbols"`
+	MaximumSourceBlockLength uint32 `yaml:"maximum_source_block_length"`
+	// 符号长度对齐（RFC 5052 的 Al）：auto 时按此推导，其它类型时检查 encoding_symbol_length；0 = 不检查（auto 默认 4）
+	SymbolAlignment uint8 `yaml:"symbol_alignment"`
+	// 不支持子块划分，只接受 0
+	SubBlocksLength uint16 `yaml:"sub_blocks_length"`
+	// type 为 auto 时：按 network.mtu 与最大文件推导符号长度和块长度
+	AutoScheme    string `yaml:"auto_scheme"`    // 同 type，默认 no_code
+	ParityPercent uint32 `yaml:"parity_percent"` // Reed-Solomon 校验比例，默认 20
+}
+
+type SenderFluteConfig struct {
+	TSI              uint32 `yaml:"tsi"`
+	InterleaveBlocks uint8  `yaml:"interleave_blocks"` // 0 = 默认 4
+	Profile          string `yaml:"profile"`           // "rfc6726"（默认）| "rfc3926"
+	TOIMaxLength     uint8  `yaml:"toi_max_length"`    // 16/32/48/64/80/112 位，0 = 112
+	// TOI 初始值，省略时为 1；random_toi 为 true 时随机
+	TOIInitialValue *uint64               `yaml:"toi_initial_value,omitempty"`
+	RandomTOI       bool                  `yaml:"random_toi"`
+	Groups          []string              `yaml:"groups"`
+	Fdt             FdtConfig             `yaml:"fdt"`
+	PriorityQueues  []PriorityQueueConfig `yaml:"priority_queues"` // 省略时只有优先级 0
+}
+
+type FdtConfig struct {
+	DurationS       uint32          `yaml:"duration_s"` // 0 = 3600
+	StartID         *uint32         `yaml:"start_id,omitempty"`
+	ContentEncoding string          `yaml:"content_encoding"`
+	InbandSCT       *bool           `yaml:"inband_sct,omitempty"`
+	PublishMode     string          `yaml:"publish_mode"` // "full"（默认）| "objects_being_transferred"
+	Carousel        *CarouselConfig `yaml:"carousel,omitempty"`
+}
+
+type PriorityQueueConfig struct {
+	Priority       uint32 `yaml:"priority"`
+	MultiplexFiles uint32 `yaml:"multiplex_files"`
+}
+
+type SenderLoggingConfig struct {
+	ProgressInterval uint32 `yaml:"progress_interval"`
+	Level            string `yaml:"level"` // debug | info（默认）| warn | error
+}
+
+type FileConfig struct {
+	Path            string `yaml:"path"`
+	ContentLocation string `yaml:"content_location"` // 省略时为 file:///<文件名>
+	ContentType     string `yaml:"content_type"`
+	Priority        uint32 `yaml:"priority"`
+	Version         uint32 `yaml:"version"` // 非 0 时作为 Content-Version
+	CacheInRAM      bool   `yaml:"cache_in_ram"`
+	// 0 = 1
+	MaxTransferCount  uint32                   `yaml:"max_transfer_count"`
+	Carousel          *CarouselConfig          `yaml:"carousel,omitempty"`
+	TargetAcquisition *TargetAcquisitionConfig `yaml:"target_acquisition,omitempty"`
+	CacheControl      *CacheControlConfig      `yaml:"cache_control,omitempty"`
+	ContentEncoding   string                   `yaml:"content_encoding"` // gzip / deflate / zlib / zstd / br
+	InbandCenc        *bool                    `yaml:"inband_cenc,omitempty"`
+	MD5               *bool                    `yaml:"md5,omitempty"`
+	Groups            []string                 `yaml:"groups"`
+	// 可选：按计划传输（见 ScheduleConfig）
+	Schedule *ScheduleConfig `yaml:"schedule,omitempty"`
+	// 可选：加密内容（见 SecurityConfig.ContentKeys）
+	Encryption *EncryptionConfig `yaml:"encryption,omitempty"`
+	// 可选：W3C trace context，随 FDT 下发，接收端的 span 与之处于同一 trace
+	TraceParent string `yaml:"traceparent"`
+	TraceState  string `yaml:"tracestate"`
+}
+
+type CarouselConfig struct {
+	Mode       string `yaml:"mode"` // "delay"（两次传输之间的间隔）| "interval"（两次开始之间的间隔）
+	IntervalMs uint32 `yaml:"interval_ms"`
+}
+
+type TargetAcquisitionConfig struct {
+	Mode       string `yaml:"mode"` // "as_fast_as_possible" | "within_duration" | "within_time"
+	DurationMs uint32 `yaml:"duration_ms"`
+	At         string `yaml:"at"` // RFC3339
+}
+
+type CacheControlConfig struct {
+	Mode      string `yaml:"mode"` // "no_cache" | "max_stale" | "expires" | "expire_at"
+	DurationS uint32 `yaml:"duration_s"`
+	At        string `yaml:"at"` // RFC3339
+}
+
+func loadConfig(path string) (*AppConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	var cfg AppConfig
+	dec := yaml.NewDecoder(strings.NewReader(string(b)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	return &cfg, nil
+}
+
+// buildSenderConfig 把 YAML 映射到 sender.Config，未填写的字段沿用 DefaultConfig
+func buildSenderConfig(c *SenderConfigSection) (sender.Config, error) {
+	sconf := sender.DefaultConfig()
+	f := &c.Flute
+
+	if f.InterleaveBlocks > 0 {
+		sconf.InterleaveBlocks = f.InterleaveBlocks
+	}
+	switch strings.ToLower(f.Profile) {
+	case "", "rfc6726":
+		sconf.Profile = profile.RFC6726
+	case "rfc3926":
+		sconf.Profile = profile.RFC3926
+	default:
+		return sconf, fmt.Errorf("unknown profile %q", f.Profile)
+	}
+	switch f.TOIMaxLength {
+	case 0, 112:
+		sconf.TOIMaxLength = sender.ToiMax112
+	case 16:
+		sconf.TOIMaxLength = sender.ToiMax16
+	case 32:
+		sconf.TOIMaxLength = sender.ToiMax32
+	case 48:
+		sconf.TOIMaxLength = sender.ToiMax48
+	case 64:
+		sconf.TOIMaxLength = sender.ToiMax64
+	case 80:
+		sconf.TOIMaxLength = sender.ToiMax80
+	default:
+		return sconf, fmt.Errorf("unsupported toi_max_length %d", f.TOIMaxLength)
+	}
+	switch {
+	case f.RandomTOI:
+		sconf.TOIInitialValue = nil
+	case f.TOIInitialValue != nil:
+		sconf.TOIInitialValue = &t.Uint128{Low: *f.TOIInitialValue}
+	}
+	sconf.Groups = f.Groups
+
+	if f.Fdt.DurationS > 0 {
+		sconf.FDTDuration = time.Duration(f.Fdt.DurationS) * time.Second
+	}
+	if f.Fdt.StartID != nil {
+		sconf.FDTStartID = *f.Fdt.StartID
+	}
+	cenc, err := lct.CencFromContentEncoding(f.Fdt.ContentEncoding)
+	if err != nil {
+		return sconf, fmt.Errorf("fdt: %w", err)
+	}
+	if !cenc.InbandCapable() {
+		return sconf, fmt.Errorf("fdt: content_encoding %q cannot be signalled in EXT_CENC (use zlib, deflate or gzip)", f.Fdt.ContentEncoding)
+	}
+	sconf.FDTCenc = cenc
+	if f.Fdt.InbandSCT != nil {
+		sconf.FDTInbandSCT = *f.Fdt.InbandSCT
+	}
+	switch f.Fdt.PublishMode {
+	case "", "full":
+		sconf.FDTPublishMode = sender.FullFDT
+	case "objects_being_transferred":
+		sconf.FDTPublishMode = sender.ObjectsBeingTransferred
+	default:
+		return sconf, fmt.Errorf("unknown fdt publish_mode %q", f.Fdt.PublishMode)
+	}
+	if f.Fdt.Carousel != nil {
+		cm, err := buildCarousel(f.Fdt.Carousel)
+		if err != nil {
+			return sconf, fmt.Errorf("fdt: %w", err)
+		}
+		sconf.FDTCarouselMode = *cm
+	}
+
+	if len(f.PriorityQueues) > 0 {
+		sconf.PriorityQueues = make(map[uint32]sender.PriorityQueue, len(f.PriorityQueues))
+		for _, pq := range f.PriorityQueues {
+			sconf.SetPriorityQueue(pq.Priority, sender.NewPriorityQueue(pq.MultiplexFiles))
+		}
+	}
+
+	level := slog.LevelInfo
+	if c.Logging.Level != "" {
+		if err := level.UnmarshalText([]byte(c.Logging.Level)); err != nil {
+			return sconf, fmt.Errorf("logging level: %w", err)
+		}
+	}
+	sconf.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	if err := buildSecurity(c, &sconf, clock.OrReal(sconf.Clock).Now()); err != nil {
+		return sconf, fmt.Errorf("security: %w", err)
+	}
+	return sconf, nil
+}
+
+func buildCarousel(c *CarouselConfig) (*sender.CarouselRepeatMode, error) {
+	cm := &sender.CarouselRepeatMode{Interval: time.Duration(c.IntervalMs) * time.Millisecond}
+	switch c.Mode {
+	case "", "delay":
+		cm.Choice = sender.DelayBetweenTransfers
+	case "interval":
+		cm.Choice = sender.IntervalBetweenStartTimes
+	default:
+		return nil, fmt.Errorf("unknown carousel mode %q", c.Mode)
+	}
+	return cm, nil
+}
+
+func buildTargetAcquisition(c *TargetAcquisitionConfig) (*sender.TargetAcquisition, error) {
+	switch c.Mode {
+	case "", "as_fast_as_possible":
+		return &sender.TargetAcquisition{Choice: sender.AsFastAsPossible}, nil
+	case "within_duration":
+		return &sender.TargetAcquisition{
+			Choice:   sender.WithinDuration,
+			Duration: time.Duration(c.DurationMs) * time.Millisecond,
+		}, nil
+	case "within_time":
+		at, err := time.Parse(time.RFC3339, c.At)
+		if err != nil {
+			return nil, fmt.Errorf("target_acquisition at: %w", err)
+		}
+		return &sender.TargetAcquisition{Choice: sender.WithinTime, At: at}, nil
+	default:
+		return nil, fmt.Errorf("unknown target_acquisition mode %q", c.Mode)
+	}
+}
+
+func buildCacheControl(c *CacheControlConfig) (*sender.CacheControl, error) {
+	switch c.Mode {
+	case "no_cache":
+		return &sender.CacheControl{Choice: sender.CacheNoCache}, nil
+	case "max_stale":
+		return &sender.CacheControl{Choice: sender.CacheMaxStale}, nil
+	case "expires":
+		return &sender.CacheControl{
+			Choice:   sender.CacheExpires,
+			Duration: time.Duration(c.DurationS) * time.Second,
+		}, nil
+	case "expire_at":
+		at, err := time.Parse(time.RFC3339, c.At)
+		if err != nil {
+			return nil, fmt.Errorf("cache_control at: %w", err)
+		}
+		return &sender.CacheControl{Choice: sender.CacheExpireAt, At: at}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache_control mode %q", c.Mode)
+	}
+}
+
+// buildObject 按文件配置创建 ObjectDesc
+func buildObject(f *FileConfig) (*sender.ObjectDesc, error) {
+	var cl *url.URL
+	if f.ContentLocation != "" {
+		u, err := url.Parse(f.ContentLocation)
+		if err != nil {
+			return nil, fmt.Errorf("content_location: %w", err)
+		}
+		cl = u
+	}
+	maxTransfer := f.MaxTransferCount
+	if maxTransfer == 0 {
+		maxTransfer = 1
+	}
+	var carousel *sender.CarouselRepeatMode
+	if f.Carousel != nil {
+		cm, err := buildCarousel(f.Carousel)
+		if err != nil {
+			return nil, err
+		}
+		carousel = cm
+	}
+	var ta *sender.TargetAcquisition
+	if f.TargetAcquisition != nil {
+		v, err := buildTargetAcquisition(f.TargetAcquisition)
+		if err != nil {
+			return nil, err
+		}
+		ta = v
+	}
+	var cc *sender.CacheControl
+	if f.CacheControl != nil {
+		v, err := buildCacheControl(f.CacheControl)
+		if err != nil {
+			return nil, err
+		}
+		cc = v
+	}
+	cenc, err := lct.CencFromContentEncoding(f.ContentEncoding)
+	if err != nil {
+		return nil, err
+	}
+	inband := true
+	if f.InbandCenc != nil {
+		inband = *f.InbandCenc
+	}
+	withMD5 := true
+	if f.MD5 != nil {
+		withMD5 = *f.MD5
+	}
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	obj, err := sender.CreateFromFile(
+		filepath.Clean(f.Path),
+		cl,
+		contentType,
+		f.CacheInRAM,
+		maxTransfer,
+		carousel,
+		ta,
+		cc,
+		f.Groups,
+		cenc,
+		inband,
+		nil,
+		withMD5,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if f.Version != 0 {
+		v := f.Version
+		obj.ContentVersion = &v
+	}
+	if f.Encryption != nil {
+		scheme, err := object.ParseEncryptionScheme(f.Encryption.Scheme)
+		if err == nil && scheme == object.EncryptionNone {
+			err = errors.New("encryption scheme is required")
+		}
+		if err != nil {
+			obj.Source.Close()
+			return nil, err
+		}
+		obj.SetEncryption(scheme, f.Encryption.KeyID)
+	}
+	if f.TraceParent != "" || f.TraceState != "" {
+		if err := obj.SetTraceContext(f.TraceParent, f.TraceState); err != nil {
+			obj.Source.Close()
+			return nil, err
+		}
+	}
+	return obj, nil
+}
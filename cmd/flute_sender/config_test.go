@@ -0,0 +1,165 @@
+package main
+
+import (
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/object"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/profile"
+	"Flute_go/pkg/sender"
+	"Flute_go/pkg/tesla"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExampleConfig(t *testing.T) {
+	cfg, err := loadConfig(filepath.Join("..", "..", "configs", "config.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	sconf, err := buildSenderConfig(&cfg.Sender)
+	if err != nil {
+		t.Fatalf("buildSenderConfig failed: %v", err)
+	}
+	if sconf.Profile != profile.RFC6726 || sconf.FDTDuration != time.Hour || len(sconf.PriorityQueues) != 2 {
+		t.Fatalf("unexpected sender config: %+v", sconf)
+	}
+	o, err := buildOtiFromConfig(&cfg.Sender.Fec, &sconf, 0, 0)
+	if err != nil {
+		t.Fatalf("buildOtiFromConfig failed: %v", err)
+	}
+	if err := sender.ValidateConfig(o, &sconf, sender.UDPPayloadForMTU(cfg.Sender.Network.MTU, false)); err != nil {
+		t.Fatalf("example config does not validate: %v", err)
+	}
+
+	// 示例中的路径相对仓库根目录
+	for i := range cfg.Sender.Files {
+		cfg.Sender.Files[i].Path = filepath.Join("..", "..", cfg.Sender.Files[i].Path)
+		obj, err := buildObject(&cfg.Sender.Files[i])
+		if err != nil {
+			t.Fatalf("buildObject(%s) failed: %v", cfg.Sender.Files[i].Path, err)
+		}
+		if err := sender.ValidateObject(o, obj); err != nil {
+			t.Fatalf("example file %d does not validate: %v", i, err)
+		}
+		if i > 0 {
+			continue
+		}
+		if obj.Cenc != lct.CencZstd || obj.MaxTransferCount != 3 || obj.CarouselMode == nil ||
+			obj.CarouselMode.Choice != sender.IntervalBetweenStartTimes || obj.CacheControl == nil ||
+			obj.ContentVersion == nil || obj.ContentLocation.String() != "file:///manifest.json" {
+			t.Fatalf("file options not mapped: %+v", obj)
+		}
+	}
+	if o.FecEncodingID != oti.ReedSolomonGF28 {
+		t.Fatalf("example must use RS28, got %s", o.FecEncodingID)
+	}
+	if _, err := buildScheduleRule(cfg.Sender.Files[1].Schedule); err != nil {
+		t.Fatalf("buildScheduleRule failed: %v", err)
+	}
+}
+
+func TestConfigRejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	os.WriteFile(path, []byte("sender:\n  flute:\n    tsii: 1\n"), 0o644)
+	if _, err := loadConfig(path); err == nil {
+		t.Fatalf("misspelled field must be rejected")
+	}
+}
+
+func TestConfigRejectsFdtEncodingWithoutCenc(t *testing.T) {
+	for _, enc := range []string{"zstd", "br"} {
+		var c SenderConfigSection
+		c.Flute.Fdt.ContentEncoding = enc
+		if _, err := buildSenderConfig(&c); err == nil {
+			t.Fatalf("fdt content_encoding %s has no EXT_CENC value and must be rejected", enc)
+		}
+	}
+}
+
+func TestConfigSecurity(t *testing.T) {
+	dir := t.TempDir()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "fdt.pem")
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	os.WriteFile(filepath.Join(dir, "paid.key"), bytes.Repeat([]byte{7}, 16), 0o600)
+	os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0o644)
+
+	var c SenderConfigSection
+	c.Security = &SecurityConfig{
+		FdtSigning: &FdtSigningConfig{KeyID: "k1", KeyFile: keyFile},
+		Tesla: &TeslaConfig{IntervalMs: 100, DisclosureDelay: 2, KeyChainLength: 100,
+			BootstrapFile: filepath.Join(dir, "tesla.bin"), SignKeyFile: keyFile},
+		ContentKeys: []ContentKeyConfig{{KeyID: "paid", KeyFile: filepath.Join(dir, "paid.key")}},
+	}
+	c.Files = []FileConfig{{
+		Path:        filepath.Join(dir, "hello.txt"),
+		Encryption:  &EncryptionConfig{Scheme: "AES-GCM", KeyID: "paid"},
+		TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}}
+	sconf, err := buildSenderConfig(&c)
+	if err != nil {
+		t.Fatalf("buildSenderConfig failed: %v", err)
+	}
+	if sconf.FDTSigner == nil || sconf.FDTSigner.KeyID != "k1" || sconf.KeyProvider == nil {
+		t.Fatalf("security options not mapped: %+v", sconf)
+	}
+	auth, ok := sconf.Authenticator.(*tesla.Sender)
+	if !ok {
+		t.Fatalf("TESLA authenticator not mapped: %T", sconf.Authenticator)
+	}
+	if err := writeTeslaBootstrap(c.Security, auth, time.Now()); err != nil {
+		t.Fatalf("writeTeslaBootstrap failed: %v", err)
+	}
+	b, _ := os.ReadFile(c.Security.Tesla.BootstrapFile)
+	if _, err := tesla.ParseBootstrap(b, pub); err != nil {
+		t.Fatalf("bootstrap does not verify: %v", err)
+	}
+
+	obj, err := buildObject(&c.Files[0])
+	if err != nil {
+		t.Fatalf("buildObject failed: %v", err)
+	}
+	defer obj.Source.Close()
+	if obj.Encryption != object.EncryptionAESGCM || obj.ContentKeyID == nil || *obj.ContentKeyID != "paid" ||
+		len(obj.OptelPropagator) == 0 {
+		t.Fatalf("file encryption/trace context not mapped: %+v", obj)
+	}
+
+	// 引用未配置的内容密钥在加载时拒绝
+	c.Files[0].Encryption.KeyID = "missing"
+	if _, err := buildSenderConfig(&c); err == nil {
+		t.Fatalf("unknown content key must be rejected")
+	}
+}
+
+func TestConfigRejectsUnsupportedFec(t *testing.T) {
+	for _, c := range []SenderFecConfig{
+		{Type: "reed_solomon_gf28", EncodingSymbolLength: 1400, MaximumSourceBlockLength: 64, SubBlocksLength: 8},
+		{Type: "reed_solomon_gf28", EncodingSymbolLength: 1401, MaximumSourceBlockLength: 64, SymbolAlignment: 4},
+	} {
+		sconf := sender.DefaultConfig()
+		if _, err := buildOtiFromConfig(&c, &sconf, 0, 0); err == nil {
+			t.Fatalf("FEC config %+v must be rejected", c)
+		}
+	}
+	c := SenderFecConfig{Type: "auto", SymbolAlignment: 7}
+	sconf := sender.DefaultConfig()
+	o, err := buildOtiFromConfig(&c, &sconf, 1400, 1<<20)
+	if err != nil {
+		t.Fatalf("buildOtiFromConfig failed: %v", err)
+	}
+	if o.EncodingSymbolLength%7 != 0 {
+		t.Fatalf("symbol_alignment ignored: %d", o.EncodingSymbolLength)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"Flute_go/pkg/control"
+	"Flute_go/pkg/sender"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// startControl 启动本地 REST 控制面；addr 为空时不启用
+// mu 与发送循环共享，保证 Sender 不被并发访问；allowedDirs 为空时只能上传，不能按路径添加
+func startControl(addr, token string, s *sender.Sender, mu *sync.Mutex, allowedDirs []string) (*http.Server, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	srv, err := control.Serve(addr, control.NewServer(s, mu, control.Options{
+		Token:       token,
+		AllowedDirs: allowedDirs,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("start control API: %w", err)
+	}
+	fmt.Printf("[flute-sender] control API on http://%s\n", addr)
+	return srv, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/sender"
+	"fmt"
+	"time"
+)
+
+// WatchConfig 监视目录（hot folder）模式
+type WatchConfig struct {
+	Dirs           []string          `yaml:"dirs"`
+	Recursive      bool              `yaml:"recursive"`
+	Rules          []WatchRuleConfig `yaml:"rules"`
+	DefaultPrio    uint32            `yaml:"default_priority"`
+	SkipUnmatched  bool              `yaml:"skip_unmatched"`
+	PollIntervalMs uint32            `yaml:"poll_interval_ms"` // 默认 1000
+	SettleDelayMs  uint32            `yaml:"settle_delay_ms"`  // 默认 2000
+	CarouselMs     uint32            `yaml:"carousel_interval_ms"`
+	CacheInRAM     bool              `yaml:"cache_in_ram"`
+	MD5            bool              `yaml:"md5"`
+	Encoding       string            `yaml:"content_encoding"`
+}
+
+type WatchRuleConfig struct {
+	Pattern  string `yaml:"pattern"`
+	Priority uint32 `yaml:"priority"`
+}
+
+// idleSleep 监视模式下没有待发包时的等待时长
+const idleSleep = 10 * time.Millisecond
+
+func buildHotFolder(s *sender.Sender, w *WatchConfig) (*sender.HotFolder, error) {
+	if w == nil || len(w.Dirs) == 0 {
+		return nil, nil
+	}
+	cenc, err := lct.CencFromContentEncoding(w.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("watch: %w", err)
+	}
+	poll := time.Duration(w.PollIntervalMs) * time.Millisecond
+	if poll == 0 {
+		poll = time.Second
+	}
+	settle := time.Duration(w.SettleDelayMs) * time.Millisecond
+	if settle == 0 {
+		settle = 2 * time.Second
+	}
+	rules := make([]sender.HotFolderRule, 0, len(w.Rules))
+	for _, r := range w.Rules {
+		rules = append(rules, sender.HotFolderRule{Pattern: r.Pattern, Priority: r.Priority})
+	}
+	var carousel *sender.CarouselRepeatMode
+	if w.CarouselMs > 0 {
+		carousel = &sender.CarouselRepeatMode{
+			Choice:   sender.IntervalBetweenStartTimes,
+			Interval: time.Duration(w.CarouselMs) * time.Millisecond,
+		}
+	}
+	return sender.NewHotFolder(s, sender.HotFolderConfig{
+		Dirs:             w.Dirs,
+		Recursive:        w.Recursive,
+		Rules:            rules,
+		DefaultPriority:  w.DefaultPrio,
+		SkipUnmatched:    w.SkipUnmatched,
+		PollInterval:     poll,
+		SettleDelay:      settle,
+		CacheInRAM:       w.CacheInRAM,
+		MaxTransferCount: 1,
+		CarouselMode:     carousel,
+		Cenc:             cenc,
+		InbandCenc:       true,
+		WithMD5:          w.MD5,
+	}), nil
+}
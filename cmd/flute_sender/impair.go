@@ -0,0 +1,69 @@
+package main
+
+import (
+	"Flute_go/pkg/clock"
+	"Flute_go/pkg/transport"
+	"fmt"
+	"time"
+)
+
+// ImpairmentConfig 发送侧网络损伤（测试 FEC 与接收端用），各概率取值 [0, 1]
+type ImpairmentConfig struct {
+	Seed       int64            `yaml:"seed"`
+	Loss       float64          `yaml:"loss"`
+	Burst      *BurstLossConfig `yaml:"burst,omitempty"`
+	Duplicate  float64          `yaml:"duplicate"`
+	Reorder    float64          `yaml:"reorder"`
+	ReorderGap int              `yaml:"reorder_gap"`
+	DelayMs    uint32           `yaml:"delay_ms"`
+	JitterMs   uint32           `yaml:"jitter_ms"`
+	Truncate   float64          `yaml:"truncate"`
+}
+
+// BurstLossConfig Gilbert-Elliott 突发丢包
+type BurstLossConfig struct {
+	P        float64 `yaml:"p"` // 好 → 坏
+	R        float64 `yaml:"r"` // 坏 → 好
+	LossGood float64 `yaml:"loss_good"`
+	LossBad  float64 `yaml:"loss_bad"` // 0 = 1
+}
+
+func buildImpairedConn(conn transport.PacketConn, c *ImpairmentConfig, clk clock.Clock) (transport.PacketConn, error) {
+	if c == nil {
+		return conn, nil
+	}
+	for name, p := range map[string]float64{"loss": c.Loss, "duplicate": c.Duplicate, "reorder": c.Reorder, "truncate": c.Truncate} {
+		if p < 0 || p > 1 {
+			return nil, fmt.Errorf("impairment %s must be within [0, 1], got %g", name, p)
+		}
+	}
+	var stages []transport.Stage
+	if c.Loss > 0 {
+		stages = append(stages, transport.RandomLoss{P: c.Loss})
+	}
+	if b := c.Burst; b != nil {
+		ge := transport.NewGilbertElliott(b.P, b.R)
+		ge.LossGood = b.LossGood
+		if b.LossBad > 0 {
+			ge.LossBad = b.LossBad
+		}
+		stages = append(stages, ge)
+	}
+	if c.Duplicate > 0 {
+		stages = append(stages, transport.Duplicate{P: c.Duplicate})
+	}
+	if c.Reorder > 0 {
+		stages = append(stages, &transport.Reorder{P: c.Reorder, Gap: c.ReorderGap})
+	}
+	if c.DelayMs > 0 || c.JitterMs > 0 {
+		stages = append(stages, transport.Delay{
+			Base:   time.Duration(c.DelayMs) * time.Millisecond,
+			Jitter: time.Duration(c.JitterMs) * time.Millisecond,
+		})
+	}
+	if c.Truncate > 0 {
+		stages = append(stages, transport.Truncate{P: c.Truncate})
+	}
+	fmt.Printf("[flute-sender] network impairment enabled (seed %d, %d stages)\n", c.Seed, len(stages))
+	return transport.NewImpairedConn(conn, c.Seed, clk, stages...), nil
+}
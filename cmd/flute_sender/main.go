@@ -1,9 +1,9 @@
-package flute_sender
+package main
 
 import (
-	"Flute_go/pkg/lct"
 	"Flute_go/pkg/oti"
 	"Flute_go/pkg/sender"
+	"Flute_go/pkg/tesla"
 	"Flute_go/pkg/transport"
 	"context"
 	"errors"
@@ -12,91 +12,90 @@ import (
 	"io"
 	"net"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
-
-	"gopkg.in/yaml.v3"
 )
 
-type AppConfig struct {
-	Sender SenderConfigSection `yaml:"sender"`
-}
-
-type SenderConfigSection struct {
-	Network        SenderNetworkConfig `yaml:"network"`
-	Fec            SenderFecConfig     `yaml:"fec"`
-	Flute          SenderFluteConfig   `yaml:"flute"`
-	Logging        SenderLoggingConfig `yaml:"logging"`
-	Files          []FileConfig        `yaml:"files"`
-	MaxRateKbps    *uint32             `yaml:"max_rate_kbps,omitempty"`        // 额外限速
-	SendIntervalUs *uint64             `yaml:"send_interval_micros,omitempty"` // 兼容字段（若你想固定间隔发包）
-}
-
-type SenderNetworkConfig struct {
-	Destination string `yaml:"destination"`  // "224.0.0.1:3400" / "192.168.0.10:9000"
-	BindAddress string `yaml:"bind_address"` // "0.0.0.0"
-	BindPort    uint16 `yaml:"bind_port"`    // 0 = 任意
-}
-
-type SenderFecConfig struct {
-	Type                     string `yaml:"type"` // "no_code" | "reed_solomon_gf28" |
-	EncodingSymbolLength     uint16 `yaml:"encoding_symbol_length"`
-	MaxNumberOfParitySymbols uint32 `yaml:"max_number_of_parity_symbols"`
-	MaximumSourceBlockLength uint32 `yaml:"maximum_source_block_length"`
-	SymbolAlignment          uint8  `yaml:"symbol_alignment"`
-	SubBlocksLength          uint16 `yaml:"sub_blocks_length"`
-}
-
-type SenderFluteConfig struct {
-	TSI              uint32 `yaml:"tsi"`
-	InterleaveBlocks uint32 `yaml:"interleave_blocks"`
-}
-
-type SenderLoggingConfig struct {
-	ProgressInterval uint32 `yaml:"progress_interval"`
-}
+// 主程序
 
-type FileConfig struct {
-	Path        string `yaml:"path"`
-	ContentType string `yaml:"content_type"`
-	Priority    uint8  `yaml:"priority"`
-	Version     uint32 `yaml:"version"`
-}
+func main() {
+	var f flags
+	flag.StringVar(&f.configPath, "config", "config.yaml", "path to YAML config")
+	flag.StringVar(&f.metricsAddr, "metrics-addr", "", "serve Prometheus metrics on this address (e.g. :9100)")
+	flag.StringVar(&f.controlAddr, "control-addr", "", "serve the REST control API on this address (e.g. 127.0.0.1:8080)")
+	flag.StringVar(&f.controlToken, "control-token", "", "bearer token required by the control API (mandatory unless -control-addr is a loopback address)")
+	flag.BoolVar(&f.planOnly, "plan", false, "print packet counts, bytes on the wire and duration for the configured files, then exit without sending")
+	flag.StringVar(&f.capturePath, "capture", "", "record every packet written to the socket as pcapng to this file")
+	flag.Parse()
 
-func loadConfig(path string) (*AppConfig, error) {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("read config: %w", err)
-	}
-	var cfg AppConfig
-	if err := yaml.Unmarshal(b, &cfg); err != nil {
-		return nil, fmt.Errorf("parse yaml: %w", err)
+	if err := run(f); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
 	}
-	return &cfg, nil
 }
 
-// 主程序
+// flags 命令行参数
+type flags struct {
+	configPath   string
+	metricsAddr  string
+	controlAddr  string
+	controlToken string
+	planOnly     bool
+	capturePath  string
+}
 
-func main() {
-	configPath := flag.String("config", "config.yaml", "path to YAML config")
-	flag.Parse()
+// run 返回时按 defer 逆序关闭各资源（状态文件、控制面、metrics、损伤延迟队列、抓包、MPEG-TS 输出）；
+// SIGINT/SIGTERM 取消发送循环，同样走这条路径
+func run(f flags) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	fmt.Printf("[flute-sender] loading config: %s\n", *configPath)
-	cfg, err := loadConfig(*configPath)
+	fmt.Printf("[flute-sender] loading config: %s\n", f.configPath)
+	cfg, err := loadConfig(f.configPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	var totalFileSize uint64
+	var totalFileSize, maxFileSize uint64
 	for _, f := range cfg.Sender.Files {
 		if st, err := os.Stat(f.Path); err == nil {
 			totalFileSize += uint64(st.Size())
+			maxFileSize = max(maxFileSize, uint64(st.Size()))
 		}
 	}
 	fmt.Printf("[flute-sender] total file size: %d bytes (%.2f MB)\n",
 		totalFileSize, float64(totalFileSize)/(1024*1024))
 
+	// Sender 配置
+	sconf, err := buildSenderConfig(&cfg.Sender)
+	if err != nil {
+		return fmt.Errorf("invalid sender config: %w", err)
+	}
+
+	// 构建 OTI（按配置选择，auto 时按 MTU 与最大文件推导），并在创建 Sender 前校验
+	maxPayload := 0
+	if cfg.Sender.Network.MTU > 0 {
+		maxPayload = sender.UDPPayloadForMTU(cfg.Sender.Network.MTU, cfg.Sender.Network.IPv6)
+	}
+	otiConf, err := buildOtiFromConfig(&cfg.Sender.Fec, &sconf, maxPayload, maxFileSize)
+	if err != nil {
+		return fmt.Errorf("invalid FEC/OTI config: %w", err)
+	}
+	printOti(otiConf)
+	if err := sender.ValidateConfig(otiConf, &sconf, maxPayload); err != nil {
+		return err
+	}
+
+	// 只估算发送量，不打开 socket
+	if f.planOnly {
+		if err := runPlan(os.Stdout, cfg, &sconf, otiConf); err != nil {
+			return fmt.Errorf("plan failed: %w", err)
+		}
+		return nil
+	}
+
 	// 构建 UDP endpoint（仅用于 Sender 内部保存 TSI/TSI/目的信息等）
 	endpoint := transport.NewUDPEndpoint(
 		nil,
@@ -104,39 +103,78 @@ func main() {
 		cfg.Sender.Network.BindPort,
 	)
 
-	// 绑定 UDP socket
-	bindAddr := fmt.Sprintf("%s:%d", cfg.Sender.Network.BindAddress, cfg.Sender.Network.BindPort)
-	fmt.Printf("[flute-sender] bind UDP socket on %s\n", bindAddr)
-	udpConn, err := net.ListenPacket("udp", bindAddr)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "bind udp failed: %v\n", err)
-		os.Exit(1)
-	}
-	defer udpConn.Close()
-
 	// 解析目的地址
 	raddr, err := net.ResolveUDPAddr("udp", cfg.Sender.Network.Destination)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "resolve dest failed: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("resolve dest failed: %w", err)
 	}
 	fmt.Printf("[flute-sender] destination: %s\n", raddr.String())
 
-	// 构建 OTI（按配置选择）
-	otiConf, err := buildOtiFromConfig(&cfg.Sender.Fec)
+	// 绑定 UDP socket，或输出 MPEG-2 TS
+	var out transport.PacketConn
+	if ts := cfg.Sender.Network.MpegTS; ts != nil {
+		if out, err = openMpegTS(ts, cfg.Sender.Network, raddr); err != nil {
+			return fmt.Errorf("open mpegts output failed: %w", err)
+		}
+	} else {
+		bindAddr := fmt.Sprintf("%s:%d", cfg.Sender.Network.BindAddress, cfg.Sender.Network.BindPort)
+		fmt.Printf("[flute-sender] bind UDP socket on %s\n", bindAddr)
+		if out, err = net.ListenPacket("udp", bindAddr); err != nil {
+			return fmt.Errorf("bind udp failed: %w", err)
+		}
+	}
+
+	// 可选：抓包，记录经过损伤后实际写出的包
+	captured, err := openCapture(f.capturePath, out, raddr, sconf.Clock)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "invalid FEC/OTI config: %v\n", err)
-		os.Exit(1)
+		out.Close()
+		return fmt.Errorf("open capture failed: %w", err)
+	}
+	if f.capturePath != "" {
+		fmt.Printf("[flute-sender] capturing to %s\n", f.capturePath)
+	}
+	// 可选：网络损伤，Close 时写出延迟中的包
+	conn, err := buildImpairedConn(captured, cfg.Sender.Network.Impairment, sconf.Clock)
+	if err != nil {
+		captured.Close()
+		return fmt.Errorf("invalid network impairment: %w", err)
+	}
+	defer conn.Close()
+
+	// 可选：Prometheus /metrics 导出
+	reg, metricsSrv, err := startMetrics(f.metricsAddr)
+	if err != nil {
+		return err
+	}
+	if reg != nil {
+		sconf.Metrics = reg
+		defer metricsSrv.Close()
 	}
-	printOti(otiConf)
 
-	// Sender 配置（interleave 等）
-	sconf := sender.Config{
-		InterleaveBlocks: uint32(cfg.Sender.Flute.InterleaveBlocks),
+	// 可选：持久化状态
+	if cfg.Sender.StateFile != "" {
+		store, err := sender.OpenFileStateStore(cfg.Sender.StateFile)
+		if err != nil {
+			return fmt.Errorf("open state file failed: %w", err)
+		}
+		defer store.Close()
+		sconf.StateStore = store
 	}
+
 	// 创建 Sender
 	s := sender.NewSender(endpoint, uint64(cfg.Sender.Flute.TSI), otiConf, &sconf)
 
+	// TESLA Bootstrap 交由带外通道分发
+	if auth, ok := sconf.Authenticator.(*tesla.Sender); ok {
+		if err := writeTeslaBootstrap(cfg.Sender.Security, auth, s.Now()); err != nil {
+			return err
+		}
+		fmt.Printf("[flute-sender] TESLA bootstrap written to %s\n", cfg.Sender.Security.Tesla.BootstrapFile)
+	}
+
+	// 计划传输
+	sched := sender.NewScheduler(s, nil)
+
 	// 装载文件
 	for _, f := range cfg.Sender.Files {
 		if !isFile(f.Path) {
@@ -145,43 +183,65 @@ func main() {
 		}
 		fmt.Printf("[flute-sender] add file: %s\n", f.Path)
 
-		obj, err := senderpkg.CreateObjectDescFromFile(
-			filepath.Clean(f.Path),
-			nil, // base url
-			f.ContentType,
-			true, // md5?
-			f.Version,
-			nil, nil, nil, nil, // groups/optel等（按需）
-			lct.CencNull, // 和你工程保持一致（也可从配置扩展）
-			true,         // inband_cenc?
-			nil,          // target acquisition
-			true,         // sender_current_time?
-		)
+		obj, err := buildObject(&f)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "create object from file failed: %v\n", err)
 			continue
 		}
-		if err := s.AddObject(uint32(f.Priority), obj); err != nil {
+		if f.Schedule != nil {
+			rule, err := buildScheduleRule(f.Schedule)
+			if err == nil {
+				_, err = sched.Add(f.Priority, obj, rule, s.Now())
+			}
+			if err != nil {
+				obj.Source.Close()
+				fmt.Fprintf(os.Stderr, "schedule object failed: %v\n", err)
+			}
+			continue
+		}
+		if _, err := s.AddObject(f.Priority, obj); err != nil {
+			obj.Source.Close()
 			fmt.Fprintf(os.Stderr, "add object failed: %v\n", err)
 			continue
 		}
 	}
 
 	// 发布 FDT
-	if err := s.Publish(time.Now()); err != nil {
-		fmt.Fprintf(os.Stderr, "publish FDT failed: %v\n", err)
-		os.Exit(1)
+	if err := s.Publish(s.Now()); err != nil {
+		return fmt.Errorf("publish FDT failed: %w", err)
+	}
+
+	// 监视目录（可选）
+	hf, err := buildHotFolder(s, cfg.Sender.Watch)
+	if err != nil {
+		return err
 	}
 
-	// 发送循环（带限速 + 进度日志）
-	runSendLoop(context.Background(), udpConn, raddr, s, cfg)
+	// 控制面（可选），与发送循环共用一把锁
+	var mu sync.Mutex
+	var allowedDirs []string
+	if cfg.Sender.Watch != nil {
+		allowedDirs = cfg.Sender.Watch.Dirs
+	}
+	controlSrv, err := startControl(f.controlAddr, f.controlToken, s, &mu, allowedDirs)
+	if err != nil {
+		return err
+	}
+	if controlSrv != nil {
+		defer controlSrv.Close()
+	}
 
+	// 发送循环（带限速 + 进度日志）；监视目录或控制面启用时空闲也不退出
+	keepAlive := hf != nil || controlSrv != nil || hasSchedule(cfg)
+	runSendLoop(ctx, conn, raddr, s, hf, sched, &mu, keepAlive, cfg)
+	return nil
 }
 
 // 发送循环
 
-func runSendLoop(ctx context.Context, conn net.PacketConn, raddr net.Addr, s *senderpkg.Sender, cfg *AppConfig) {
-	start := time.Now()
+func runSendLoop(ctx context.Context, conn transport.PacketConn, raddr net.Addr, s *sender.Sender, hf *sender.HotFolder, sched *sender.Scheduler, mu *sync.Mutex, keepAlive bool, cfg *AppConfig) {
+	clk := s.Clock()
+	start := clk.Now()
 	var totalBytes uint64
 	var pkts uint64
 
@@ -195,35 +255,63 @@ func runSendLoop(ctx context.Context, conn net.PacketConn, raddr net.Addr, s *se
 		bytesPerSec = float64(maxRateKbps) * 1000.0 / 8.0 // kbps → B/s
 	}
 	fmt.Printf("[flute-sender] rate limit: %d kbps (%d B/s)\n", maxRateKbps, int(bytesPerSec))
+	var fixedInterval time.Duration
+	if cfg.Sender.SendIntervalUs != nil {
+		fixedInterval = time.Duration(*cfg.Sender.SendIntervalUs) * time.Microsecond
+		fmt.Printf("[flute-sender] send interval: %s\n", fixedInterval)
+	}
 
-	nextSendAt := time.Now()
+	nextSendAt := clk.Now()
 
 	// 日志节流
 	logEvery := uint64(1000)
 	if cfg.Sender.Logging.ProgressInterval > 0 {
 		logEvery = uint64(cfg.Sender.Logging.ProgressInterval)
 	}
-	lastLogAt := time.Now()
+	lastLogAt := clk.Now()
 	var bytesSinceLog uint64
 
-	for {
-		pktb := s.Read(time.Now())
+	for ctx.Err() == nil {
+		mu.Lock()
+		if hf != nil {
+			if _, err := hf.Scan(clk.Now()); err != nil {
+				fmt.Fprintf(os.Stderr, "hot folder scan failed: %v\n", err)
+			}
+		}
+		if _, err := sched.Tick(clk.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "scheduler tick failed: %v\n", err)
+		}
+		pktb := s.Read(clk.Now())
+		mu.Unlock()
 		if pktb == nil {
-			break
+			if !keepAlive {
+				break
+			}
+			// 长驻模式：没有待发包时等待新对象
+			transport.Flush(conn)
+			select {
+			case <-ctx.Done():
+				return
+			case <-clk.After(idleSleep):
+			}
+			continue
 		}
 
-		// 可选：kbps 限速（逐包节拍）
-		if bytesPerSec > 0 {
-			interval := time.Duration(float64(len(pktb)) / bytesPerSec * float64(time.Second))
-			now := time.Now()
+		// 可选：kbps 限速 / 固定间隔（逐包节拍，取较慢者）
+		if bytesPerSec > 0 || fixedInterval > 0 {
+			interval := fixedInterval
+			if bytesPerSec > 0 {
+				interval = max(interval, time.Duration(float64(len(pktb))/bytesPerSec*float64(time.Second)))
+			}
+			now := clk.Now()
 			if now.Before(nextSendAt) {
-				time.Sleep(nextSendAt.Sub(now))
+				clk.Sleep(nextSendAt.Sub(now))
 			}
 			nextSendAt = nextSendAt.Add(interval)
 
 			// 漂移校准（避免累计误差）
-			if drift := time.Since(nextSendAt); drift > 200*time.Millisecond {
-				nextSendAt = time.Now().Add(interval)
+			if drift := clk.Now().Sub(nextSendAt); drift > 200*time.Millisecond {
+				nextSendAt = clk.Now().Add(interval)
 			}
 		}
 
@@ -243,7 +331,7 @@ func runSendLoop(ctx context.Context, conn net.PacketConn, raddr net.Addr, s *se
 
 		// 进度日志
 		if pkts%logEvery == 0 {
-			now := time.Now()
+			now := clk.Now()
 			dt := now.Sub(lastLogAt).Seconds()
 			if dt > 0 {
 				instMbps := (float64(bytesSinceLog) * 8.0) / dt / 1_000_000.0
@@ -257,7 +345,7 @@ func runSendLoop(ctx context.Context, conn net.PacketConn, raddr net.Addr, s *se
 	}
 
 	// 收尾统计
-	elapsed := time.Since(start)
+	elapsed := clk.Now().Sub(start)
 	avgMbps := (float64(totalBytes) * 8.0) / elapsed.Seconds() / 1_000_000.0
 	fmt.Println("============================================")
 	fmt.Println("FILE TRANSFER COMPLETED")
@@ -269,25 +357,48 @@ func runSendLoop(ctx context.Context, conn net.PacketConn, raddr net.Addr, s *se
 	fmt.Println("============================================")
 }
 
-func buildOtiFromConfig(c *SenderFecConfig) (*oti.Oti, error) {
+func buildOtiFromConfig(c *SenderFecConfig, sconf *sender.Config, maxPayload int, maxObjectSize uint64) (*oti.Oti, error) {
+	if c.SubBlocksLength != 0 {
+		return nil, fmt.Errorf("sub_blocks_length %d: sub-blocking is not supported", c.SubBlocksLength)
+	}
+	if c.Type != "auto" && c.SymbolAlignment > 0 && c.EncodingSymbolLength%uint16(c.SymbolAlignment) != 0 {
+		return nil, fmt.Errorf("encoding_symbol_length %d is not a multiple of symbol_alignment %d",
+			c.EncodingSymbolLength, c.SymbolAlignment)
+	}
 	switch c.Type {
-	case "no_code":
-		o := oti.NewNoCode(c.EncodingSymbolLength, c.MaximumSourceBlockLength)
-		return &o, nil
+	case "auto":
+		if maxPayload <= 0 {
+			return nil, fmt.Errorf("fec type auto requires network.mtu")
+		}
+		p := sender.AutoOtiParams{
+			MaxPayload:      maxPayload,
+			ObjectSize:      maxObjectSize,
+			ParityPercent:   c.ParityPercent,
+			SymbolAlignment: uint16(c.SymbolAlignment),
+		}
+		switch c.AutoScheme {
+		case "", "no_code":
+			p.FEC = oti.NoCode
+		case "reed_solomon_gf28":
+			p.FEC = oti.ReedSolomonGF28
+		case "reed_solomon_gf28_under_specified":
+			p.FEC = oti.ReedSolomonGF28UnderSpecified
+		default:
+			return nil, fmt.Errorf("unsupported auto FEC scheme: %s", c.AutoScheme)
+		}
+		return sender.AutoOti(p, sconf)
 
-	case "reed_solomon_gf28":
-		o, err := oti.NewReedSolomonRS28(c.EncodingSymbolLength, c.MaximumSourceBlockLength, uint8(c.MaxNumberOfParitySymbols))
-		if err != nil {
-			return nil, err
+	case "", "no_code":
+		if c.EncodingSymbolLength == 0 && c.MaximumSourceBlockLength == 0 {
+			return oti.NewOti(), nil
 		}
-		return &o, nil
+		return oti.NewNoCode(c.EncodingSymbolLength, c.MaximumSourceBlockLength), nil
+
+	case "reed_solomon_gf28":
+		return oti.NewReedSolomonRS28(c.EncodingSymbolLength, c.MaximumSourceBlockLength, uint8(c.MaxNumberOfParitySymbols))
 
 	case "reed_solomon_gf28_under_specified":
-		o, err := oti.NewReedSolomonRs28UnderSpecified(c.EncodingSymbolLength, c.MaximumSourceBlockLength, uint16(c.MaxNumberOfParitySymbols))
-		if err != nil {
-			return nil, err
-		}
-		return &o, nil
+		return oti.NewReedSolomonRs28UnderSpecified(c.EncodingSymbolLength, c.MaximumSourceBlockLength, uint16(c.MaxNumberOfParitySymbols))
 
 	default:
 		return nil, fmt.Errorf("unsupported FEC type: %s", c.Type)
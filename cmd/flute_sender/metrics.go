@@ -0,0 +1,21 @@
+package main
+
+import (
+	"Flute_go/pkg/metrics"
+	"fmt"
+	"net/http"
+)
+
+// startMetrics 启动 /metrics 导出；addr 为空时不启用，返回 nil
+func startMetrics(addr string) (*metrics.Registry, *http.Server, error) {
+	if addr == "" {
+		return nil, nil, nil
+	}
+	reg := metrics.NewRegistry()
+	srv, err := metrics.Serve(addr, reg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("start metrics exporter: %w", err)
+	}
+	fmt.Printf("[flute-sender] metrics exporter on http://%s/metrics\n", addr)
+	return reg, srv, nil
+}
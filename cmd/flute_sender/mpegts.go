@@ -0,0 +1,78 @@
+package main
+
+import (
+	"Flute_go/pkg/mpegts"
+	"Flute_go/pkg/transport"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+)
+
+// MpegTSConfig 输出 MPEG-2 TS 而不是 UDP（卫星播出链路），IP/UDP 头按 bind/destination 合成
+type MpegTSConfig struct {
+	Output  string `yaml:"output"`  // .ts 文件或命名管道（mkfifo）
+	PID     uint16 `yaml:"pid"`     // 0x0010–0x1FFE
+	Mode    string `yaml:"mode"`    // mpe | ule，默认 mpe
+	Packing bool   `yaml:"packing"` // 段/SNDU 紧接打包，节省带宽
+	NPA     bool   `yaml:"npa"`     // ULE 携带目的 NPA 地址
+}
+
+// tsConn 关闭时先写出未填满的 TS 包，再关闭输出文件
+type tsConn struct {
+	*mpegts.Conn
+	f *os.File
+}
+
+func (c *tsConn) Close() error {
+	err := c.Conn.Close()
+	if ferr := c.f.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func openMpegTS(c *MpegTSConfig, n SenderNetworkConfig, raddr *net.UDPAddr) (transport.PacketConn, error) {
+	if c.Output == "" {
+		return nil, fmt.Errorf("mpegts output is required")
+	}
+	mode := mpegts.MPE
+	if c.Mode != "" {
+		var err error
+		if mode, err = mpegts.ParseMode(c.Mode); err != nil {
+			return nil, err
+		}
+	}
+	dst := raddr.AddrPort()
+	dst = netip.AddrPortFrom(dst.Addr().Unmap(), dst.Port())
+	srcAddr := netip.IPv4Unspecified()
+	if dst.Addr().Is6() {
+		srcAddr = netip.IPv6Unspecified()
+	}
+	if n.BindAddress != "" {
+		a, err := netip.ParseAddr(n.BindAddress)
+		if err != nil {
+			return nil, fmt.Errorf("bind_address %q: %w", n.BindAddress, err)
+		}
+		if a = a.Unmap(); a.Is4() == dst.Addr().Is4() {
+			srcAddr = a
+		}
+	}
+
+	f, err := os.OpenFile(c.Output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := mpegts.NewEncapsulator(f, mpegts.Config{PID: c.PID, Mode: mode, Packing: c.Packing, NPA: c.NPA})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	conn, err := mpegts.NewConn(enc, netip.AddrPortFrom(srcAddr, n.BindPort), dst)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	fmt.Printf("[flute-sender] writing MPEG-2 TS (%s, PID %#x) to %s\n", mode, c.PID, c.Output)
+	return &tsConn{Conn: conn, f: f}, nil
+}
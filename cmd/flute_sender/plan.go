@@ -0,0 +1,71 @@
+package main
+
+import (
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/sender"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// runPlan 估算配置中全部文件的发送量与时长并打印，不打开 socket、不发送
+func runPlan(w io.Writer, cfg *AppConfig, sconf *sender.Config, o *oti.Oti) error {
+	items := make([]sender.PlanItem, 0, len(cfg.Sender.Files))
+	for _, f := range cfg.Sender.Files {
+		if !isFile(f.Path) {
+			fmt.Fprintf(os.Stderr, "file not found, not planned: %s\n", f.Path)
+			continue
+		}
+		obj, err := buildObject(&f)
+		if err != nil {
+			return fmt.Errorf("create object from %s failed: %w", f.Path, err)
+		}
+		items = append(items, sender.PlanItem{Priority: f.Priority, Object: obj})
+	}
+
+	opts := sender.PlanOptions{PacketOverhead: sender.IPv4UDPOverhead}
+	if cfg.Sender.Network.IPv6 {
+		opts.PacketOverhead = sender.IPv6UDPOverhead
+	}
+	if cfg.Sender.MaxRateKbps != nil {
+		opts.RateBps = uint64(*cfg.Sender.MaxRateKbps) * 1000
+	}
+	if cfg.Sender.SendIntervalUs != nil {
+		opts.PacketInterval = time.Duration(*cfg.Sender.SendIntervalUs) * time.Microsecond
+	}
+	plan, err := sender.PlanTransfer(uint64(cfg.Sender.Flute.TSI), o, sconf, items, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "============================================")
+	fmt.Fprintln(w, "TRANSFER PLAN")
+	fmt.Fprintln(w, "============================================")
+	for _, f := range plan.Files {
+		transfers := fmt.Sprintf("x%d", f.Transfers)
+		if f.Endless {
+			transfers = "carousel (1 cycle)"
+		}
+		fmt.Fprintf(w, "%s\n", f.ContentLocation)
+		fmt.Fprintf(w, "  priority %d, %d bytes, %d blocks, %d source + %d parity pkts, %s\n",
+			f.Priority, f.TransferLength, f.Blocks, f.SourcePackets, f.ParityPackets, transfers)
+		fmt.Fprintf(w, "  %d pkts, %d bytes on the wire, %s\n",
+			f.Packets, f.WireBytes, formatPlanDuration(f.Duration))
+	}
+	fmt.Fprintf(w, "FDT: %d bytes, %d pkts per instance, %d transfers, %d bytes on the wire\n",
+		plan.FdtTransferLength, plan.FdtPackets, plan.FdtTransfers, plan.FdtWireBytes)
+	fmt.Fprintln(w, "--------------------------------------------")
+	fmt.Fprintf(w, "Total packets:   %d\n", plan.Packets)
+	fmt.Fprintf(w, "Total on wire:   %.2f MB (%d bytes)\n", float64(plan.WireBytes)/(1024*1024), plan.WireBytes)
+	fmt.Fprintf(w, "Duration:        %s\n", formatPlanDuration(plan.Duration))
+	fmt.Fprintln(w, "============================================")
+	return nil
+}
+
+func formatPlanDuration(d time.Duration) string {
+	if d == 0 {
+		return "unlimited rate"
+	}
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"Flute_go/pkg/sender"
+	"fmt"
+	"time"
+)
+
+// ScheduleConfig 文件的传输计划，cron 与 every_s 二选一
+type ScheduleConfig struct {
+	Cron     string `yaml:"cron"`     // "0 2 * * *"
+	TimeZone string `yaml:"timezone"` // IANA 名称，默认本地时区
+	EverySec uint32 `yaml:"every_s"`
+	WindowS  uint32 `yaml:"window_s"` // 0 = 窗口开始时只发一轮
+	Until    string `yaml:"until"`    // RFC3339，之后移出 FDT
+}
+
+func buildScheduleRule(c *ScheduleConfig) (sender.ScheduleRule, error) {
+	rule := sender.ScheduleRule{
+		Cron:   c.Cron,
+		Every:  time.Duration(c.EverySec) * time.Second,
+		Window: time.Duration(c.WindowS) * time.Second,
+	}
+	if c.TimeZone != "" {
+		loc, err := time.LoadLocation(c.TimeZone)
+		if err != nil {
+			return rule, fmt.Errorf("schedule timezone: %w", err)
+		}
+		rule.Location = loc
+	}
+	if c.Until != "" {
+		until, err := time.Parse(time.RFC3339, c.Until)
+		if err != nil {
+			return rule, fmt.Errorf("schedule until: %w", err)
+		}
+		rule.Until = until
+	}
+	return rule, nil
+}
+
+// hasSchedule 有计划传输的文件时发送循环空闲也不退出
+func hasSchedule(cfg *AppConfig) bool {
+	for _, f := range cfg.Sender.Files {
+		if f.Schedule != nil {
+			return true
+		}
+	}
+	return false
+}
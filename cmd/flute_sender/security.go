@@ -0,0 +1,174 @@
+package main
+
+import (
+	"Flute_go/pkg/object"
+	"Flute_go/pkg/sender"
+	"Flute_go/pkg/tesla"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// SecurityConfig 源认证与内容加密，均为可选
+type SecurityConfig struct {
+	FdtSigning  *FdtSigningConfig  `yaml:"fdt_signing,omitempty"`
+	Tesla       *TeslaConfig       `yaml:"tesla,omitempty"`
+	ContentKeys []ContentKeyConfig `yaml:"content_keys"`
+}
+
+// FdtSigningConfig FDT-Instance 签名私钥（PEM 编码的 PKCS#8，Ed25519 或 ECDSA）
+type FdtSigningConfig struct {
+	KeyID   string `yaml:"key_id"`
+	KeyFile string `yaml:"key_file"`
+}
+
+// TeslaConfig TESLA 源认证（EXT_AUTH），T0 为启动时间
+// Bootstrap 写入 bootstrap_file，由带外通道（SDP、HTTP 等）分发给接收端
+type TeslaConfig struct {
+	ASID            uint8  `yaml:"asid"`
+	IntervalMs      uint32 `yaml:"interval_ms"`
+	DisclosureDelay uint32 `yaml:"disclosure_delay"` // 单位：区间
+	KeyChainLength  uint32 `yaml:"key_chain_length"` // 可认证的区间数
+	MACLength       int    `yaml:"mac_length"`       // 0 = 默认
+	SeedFile        string `yaml:"seed_file"`        // 省略时随机生成密钥链
+	BootstrapFile   string `yaml:"bootstrap_file"`
+	SignKeyFile     string `yaml:"sign_key_file"` // 可选：Bootstrap 签名私钥（PEM 编码的 PKCS#8 Ed25519）
+}
+
+// ContentKeyConfig 内容密钥，文件内容为原始 AES 密钥（16/24/32 字节）
+type ContentKeyConfig struct {
+	KeyID   string `yaml:"key_id"`
+	KeyFile string `yaml:"key_file"`
+}
+
+// EncryptionConfig 对象加密，key_id 须在 security.content_keys 中
+type EncryptionConfig struct {
+	Scheme string `yaml:"scheme"` // AES-GCM | AES-CTR
+	KeyID  string `yaml:"key_id"`
+}
+
+// buildSecurity 设置 FDTSigner、Authenticator 与 KeyProvider，并检查各文件引用的密钥均已配置
+func buildSecurity(c *SenderConfigSection, sconf *sender.Config, now time.Time) error {
+	sec := c.Security
+	if sec == nil {
+		sec = &SecurityConfig{}
+	}
+	if s := sec.FdtSigning; s != nil {
+		if s.KeyID == "" {
+			return errors.New("fdt_signing: key_id is required")
+		}
+		key, err := loadPrivateKey(s.KeyFile)
+		if err != nil {
+			return fmt.Errorf("fdt_signing: %w", err)
+		}
+		sconf.FDTSigner = &object.FdtSigner{KeyID: s.KeyID, Key: key}
+	}
+	if tc := sec.Tesla; tc != nil {
+		auth, err := buildTesla(tc, now)
+		if err != nil {
+			return fmt.Errorf("tesla: %w", err)
+		}
+		auth.SetLogger(sconf.Logger)
+		sconf.Authenticator = auth
+	}
+	keys := make(object.StaticKeyProvider, len(sec.ContentKeys))
+	for _, k := range sec.ContentKeys {
+		if _, dup := keys[k.KeyID]; dup || k.KeyID == "" {
+			return fmt.Errorf("content_keys: missing or duplicate key_id %q", k.KeyID)
+		}
+		b, err := os.ReadFile(k.KeyFile)
+		if err != nil {
+			return fmt.Errorf("content_keys: %w", err)
+		}
+		switch len(b) {
+		case 16, 24, 32:
+		default:
+			return fmt.Errorf("content_keys: %s: AES key must be 16, 24 or 32 bytes, got %d", k.KeyFile, len(b))
+		}
+		keys[k.KeyID] = b
+	}
+	if len(keys) > 0 {
+		sconf.KeyProvider = keys
+	}
+	for _, f := range c.Files {
+		if f.Encryption == nil {
+			continue
+		}
+		if _, ok := keys[f.Encryption.KeyID]; !ok {
+			return fmt.Errorf("file %s: content key %q not in security.content_keys", f.Path, f.Encryption.KeyID)
+		}
+	}
+	return nil
+}
+
+func buildTesla(c *TeslaConfig, now time.Time) (*tesla.Sender, error) {
+	if c.BootstrapFile == "" {
+		return nil, errors.New("bootstrap_file is required, receivers can not verify without it")
+	}
+	var seed []byte
+	if c.SeedFile != "" {
+		b, err := os.ReadFile(c.SeedFile)
+		if err != nil {
+			return nil, err
+		}
+		seed = b
+	}
+	return tesla.NewSender(tesla.Params{
+		ASID:            c.ASID,
+		T0:              now,
+		Interval:        time.Duration(c.IntervalMs) * time.Millisecond,
+		DisclosureDelay: c.DisclosureDelay,
+		KeyChainLength:  c.KeyChainLength,
+		MACLength:       c.MACLength,
+	}, seed)
+}
+
+// writeTeslaBootstrap 把 TESLA Bootstrap（含发送端当前时间）写入 bootstrap_file；未启用 TESLA 时不做任何事
+func writeTeslaBootstrap(c *SecurityConfig, auth *tesla.Sender, now time.Time) error {
+	if c == nil || c.Tesla == nil || auth == nil {
+		return nil
+	}
+	var signKey ed25519.PrivateKey
+	if c.Tesla.SignKeyFile != "" {
+		key, err := loadPrivateKey(c.Tesla.SignKeyFile)
+		if err != nil {
+			return fmt.Errorf("tesla: %w", err)
+		}
+		k, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return fmt.Errorf("tesla: %s: bootstrap signing key must be Ed25519", c.Tesla.SignKeyFile)
+		}
+		signKey = k
+	}
+	b, err := auth.Bootstrap(now, signKey)
+	if err != nil {
+		return fmt.Errorf("tesla: %w", err)
+	}
+	return os.WriteFile(c.Tesla.BootstrapFile, b, 0o644)
+}
+
+// loadPrivateKey 读取 PEM 编码的 PKCS#8 私钥
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key %T", path, key)
+	}
+	return signer, nil
+}
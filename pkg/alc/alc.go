@@ -60,6 +60,16 @@ type AlcCodec interface {
 	FecPayloadIdBlockLength() uint
 }
 
+// Authenticator 为 ALC 包生成 EXT_AUTH 扩展（如 TESLA，RFC 5776）
+// AuthExt 返回 MAC 字段置 0 的扩展头（长度为 4 字节的整数倍）以及 MAC 在扩展内的偏移；
+// 返回 nil 表示本包不做认证。Sign 对 MAC 字段置 0 的完整包计算 MAC。
+// 同一个包的两次调用使用同一个 now。MaxExtLength 为 AuthExt 可能返回的最长扩展，用于 MTU/符号长度计算
+type Authenticator interface {
+	AuthExt(now time.Time) (ext []byte, macOffset int)
+	Sign(pkt []byte, now time.Time) []byte
+	MaxExtLength() int
+}
+
 // noOpCodec 是一个空实现，用来占位，保证系统可跑
 type noOpCodec struct{}
 
@@ -145,6 +155,19 @@ func NewAlcPkt(
 	p *object.Pkt,
 	prof profile.Profile,
 	now time.Time,
+) []byte {
+	return NewAlcPktAuth(o, cci, tsi, p, prof, now, nil)
+}
+
+// NewAlcPktAuth 同 NewAlcPkt，auth 非 nil 时额外携带 EXT_AUTH 并在封包完成后写入 MAC
+func NewAlcPktAuth(
+	o *oti.Oti,
+	cci t.Uint128,
+	tsi uint64,
+	p *object.Pkt,
+	prof profile.Profile,
+	now time.Time,
+	auth Authenticator,
 ) []byte {
 	buf := make([]byte, 0, len(p.Payload)+64)
 
@@ -167,10 +190,9 @@ func NewAlcPkt(
 		}
 	}
 
-	// 3) CENC 扩展（FDT 且非 Null，或者 inband_cenc）
-	if (p.Toi == lct.TOI_FDT && p.Cenc != lct.CencNull) || p.InbandCenc {
-		c := uint8(lct.CencNull)
-		pushCenc(&buf, c)
+	// 3) CENC 扩展（FDT 且非 Null，或者 inband_cenc）；没有标准取值的编码只在 FDT 中通告
+	if ((p.Toi == lct.TOI_FDT && p.Cenc != lct.CencNull) || p.InbandCenc) && p.Cenc.InbandCapable() {
+		pushCenc(&buf, uint8(p.Cenc))
 	}
 
 	// 4) Sender Current Time
@@ -178,6 +200,17 @@ func NewAlcPkt(
 		pushSCT(&buf, now)
 	}
 
+	// 4.1) EXT_AUTH（MAC 先置 0，封包完成后回填）
+	macPos := -1
+	if auth != nil {
+		ext, macOffset := auth.AuthExt(now)
+		if ext != nil {
+			macPos = len(buf) + macOffset
+			buf = append(buf, ext...)
+			lct.IncHdrLen(buf, uint8(len(ext)/4))
+		}
+	}
+
 	// 5) FTI + FEC Payload ID
 	codec := Instance(o.FecEncodingID)
 	if p.Toi == lct.TOI_FDT || o.InBandFti {
@@ -192,6 +225,11 @@ func NewAlcPkt(
 	// 6) Payload
 	pushPayload(&buf, p)
 
+	// 7) MAC 覆盖整个包（MAC 字段为 0 时计算）
+	if macPos >= 0 {
+		copy(buf[macPos:], auth.Sign(buf, now))
+	}
+
 	return buf
 }
 
@@ -316,13 +354,11 @@ func parseCenc(ext []byte) (lct.Cenc, error) {
 	if len(ext) != 4 {
 		return lct.CencNull, fmt.Errorf("wrong CENC ext len")
 	}
-	val := ext[1]
-	switch lct.Cenc(val) {
-	case lct.CencNull, lct.CencZlib, lct.CencDeflate, lct.CencGzip:
-		return lct.Cenc(val), nil
-	default:
+	val := lct.Cenc(ext[1])
+	if !val.InbandCapable() {
 		return lct.CencNull, fmt.Errorf("unsupported Cenc=%d", val)
 	}
+	return val, nil
 }
 
 func pushSCT(buf *[]byte, tm time.Time) {
@@ -0,0 +1,114 @@
+// Package clock 可注入的时间源：生产环境使用系统时间，测试与仿真使用虚拟时间，
+// 数小时的轮播、过期与 TargetAcquisition 场景可以在毫秒内确定性地跑完
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock 时间源
+type Clock interface {
+	Now() time.Time
+	// Sleep 阻塞 d（虚拟时钟：直接把时间推进 d）
+	Sleep(d time.Duration)
+	// After d 之后向返回的通道发送当时的时间
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real 系统时钟
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// OrReal c 为 nil 时返回 Real
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+// Virtual 手动推进的时钟，并发安全
+// 时间只在 Advance/Set/Sleep 时前进；到期的 After 定时器在推进时按到期顺序触发
+type Virtual struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func NewVirtual(start time.Time) *Virtual {
+	return &Virtual{now: start}
+}
+
+func (v *Virtual) Now() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.now
+}
+
+// Sleep 不阻塞，把时间推进 d；适合单协程仿真（如发送循环的限速等待）
+func (v *Virtual) Sleep(d time.Duration) {
+	v.Advance(d)
+}
+
+func (v *Virtual) After(d time.Duration) <-chan time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	at := v.now.Add(d)
+	if d <= 0 {
+		ch <- v.now
+		return ch
+	}
+	v.waiters = append(v.waiters, waiter{at: at, ch: ch})
+	return ch
+}
+
+// Advance 推进 d（负值忽略），返回推进后的时间
+func (v *Virtual) Advance(d time.Duration) time.Time {
+	if d < 0 {
+		d = 0
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.setLocked(v.now.Add(d))
+	return v.now
+}
+
+// Set 跳到 t；早于当前时间时忽略（时间不倒流）
+func (v *Virtual) Set(t time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if t.After(v.now) {
+		v.setLocked(t)
+	}
+}
+
+// Pending 尚未触发的定时器数量
+func (v *Virtual) Pending() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.waiters)
+}
+
+func (v *Virtual) setLocked(t time.Time) {
+	v.now = t
+	sort.SliceStable(v.waiters, func(i, j int) bool { return v.waiters[i].at.Before(v.waiters[j].at) })
+	n := 0
+	for n < len(v.waiters) && !v.waiters[n].at.After(t) {
+		v.waiters[n].ch <- v.waiters[n].at
+		n++
+	}
+	v.waiters = v.waiters[n:]
+}
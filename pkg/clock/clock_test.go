@@ -0,0 +1,44 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVirtual(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	v := NewVirtual(start)
+
+	late := v.After(2 * time.Hour)
+	early := v.After(time.Minute)
+	if v.Pending() != 2 {
+		t.Fatalf("expected 2 pending timers, got %d", v.Pending())
+	}
+
+	v.Advance(30 * time.Second)
+	select {
+	case <-early:
+		t.Fatalf("timer fired too early")
+	default:
+	}
+
+	v.Sleep(time.Minute)
+	if got := <-early; !got.Equal(start.Add(time.Minute)) {
+		t.Fatalf("timer fired with %s", got)
+	}
+	if !v.Now().Equal(start.Add(90 * time.Second)) {
+		t.Fatalf("unexpected now %s", v.Now())
+	}
+
+	v.Set(start) // 不倒流
+	if !v.Now().Equal(start.Add(90 * time.Second)) {
+		t.Fatalf("virtual time must not go backwards")
+	}
+	v.Set(start.Add(3 * time.Hour))
+	if got := <-late; !got.Equal(start.Add(2 * time.Hour)) {
+		t.Fatalf("timer fired with %s", got)
+	}
+	if v.Pending() != 0 {
+		t.Fatalf("expected no pending timers")
+	}
+}
@@ -0,0 +1,467 @@
+package control
+
+import (
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/sender"
+	t "Flute_go/pkg/type"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options 控制面参数
+type Options struct {
+	// 非空时要求 "Authorization: Bearer <Token>"；只监听回环地址时才允许为空
+	Token string
+	// 按路径添加的文件必须位于这些目录之下（解析符号链接后）；为空时禁止按路径添加
+	AllowedDirs []string
+	// 上传大小上限（字节），0 = 64 MiB
+	MaxUploadSize int64
+}
+
+// Server 运行中 Sender 的本地 REST 控制面
+// Sender 本身不是并发安全的：所有调用都持有 mu，发送循环读包时也必须持有同一把锁
+type Server struct {
+	sender *sender.Sender
+	mu     sync.Locker
+	opts   Options
+	mux    *http.ServeMux
+}
+
+func NewServer(s *sender.Sender, mu sync.Locker, opts Options) *Server {
+	if opts.MaxUploadSize == 0 {
+		opts.MaxUploadSize = 64 << 20
+	}
+	srv := &Server{sender: s, mu: mu, opts: opts, mux: http.NewServeMux()}
+	srv.mux.HandleFunc("GET /objects", srv.listObjects)
+	srv.mux.HandleFunc("POST /objects", srv.addObject)
+	srv.mux.HandleFunc("PUT /objects/upload", srv.uploadObject)
+	srv.mux.HandleFunc("GET /objects/{toi}", srv.getObject)
+	srv.mux.HandleFunc("DELETE /objects/{toi}", srv.removeObject)
+	srv.mux.HandleFunc("POST /objects/{toi}/trigger", srv.triggerTransfer)
+	srv.mux.HandleFunc("POST /publish", srv.publish)
+	srv.mux.HandleFunc("GET /stats", srv.stats)
+	return srv
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Token != "" {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.opts.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve 在 addr 上启动控制面，返回的 Server 由调用方关闭
+// 未设置 Token 时只允许监听回环地址
+func Serve(addr string, h *Server) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if h.opts.Token == "" && !isLoopback(ln.Addr()) {
+		ln.Close()
+		return nil, fmt.Errorf("control API on %s requires a token (only loopback addresses may run without one)", ln.Addr())
+	}
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = srv.Serve(ln) }()
+	return srv, nil
+}
+
+// ---- 数据结构 ----
+
+// ObjectInfo 对象列表项
+type ObjectInfo struct {
+	Toi             string  `json:"toi"`
+	ContentLocation string  `json:"content_location"`
+	ContentType     string  `json:"content_type"`
+	ContentLength   uint64  `json:"content_length"`
+	TransferLength  uint64  `json:"transfer_length"`
+	ContentVersion  *uint32 `json:"content_version,omitempty"`
+	ETag            *string `json:"etag,omitempty"`
+	NbTransfers     uint64  `json:"nb_transfers"`
+}
+
+// AddRequest 按路径添加对象
+type AddRequest struct {
+	Path               string `json:"path"`
+	ContentLocation    string `json:"content_location,omitempty"` // 默认 file:///<文件名>
+	ContentType        string `json:"content_type,omitempty"`
+	Priority           uint32 `json:"priority"`
+	MaxTransferCount   uint32 `json:"max_transfer_count,omitempty"` // 默认 1
+	CarouselIntervalMs uint32 `json:"carousel_interval_ms,omitempty"`
+	CacheInRAM         bool   `json:"cache_in_ram,omitempty"`
+	MD5                bool   `json:"md5,omitempty"`
+	// 同 Content-Location 已存在时以新 TOI 替换（UpdateObject）
+	Update bool `json:"update,omitempty"`
+	// 添加后立即 Publish
+	Publish bool `json:"publish,omitempty"`
+}
+
+// TriggerRequest 指定传输时间，为空表示尽快
+type TriggerRequest struct {
+	At *time.Time `json:"at,omitempty"`
+}
+
+type StatsResponse struct {
+	TSI       uint64       `json:"tsi"`
+	NbObjects int          `json:"nb_objects"`
+	Objects   []ObjectInfo `json:"objects"`
+}
+
+// ---- handlers ----
+
+func (s *Server) listObjects(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	list := s.objectsLocked()
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request) {
+	toi := r.PathValue("toi")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range s.objectsLocked() {
+		if o.Toi == toi {
+			writeJSON(w, http.StatusOK, o)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("object %s not found", toi))
+}
+
+func (s *Server) addObject(w http.ResponseWriter, r *http.Request) {
+	var req AddRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Path == "" {
+		writeError(w, http.StatusBadRequest, errors.New("path is required"))
+		return
+	}
+	path, err := s.checkPath(req.Path)
+	if err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	cl, err := contentLocation(req.ContentLocation, filepath.Base(req.Path))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	obj, err := sender.CreateFromFile(
+		path,
+		cl,
+		contentTypeOr(req.ContentType),
+		req.CacheInRAM,
+		maxTransferCount(req.MaxTransferCount),
+		carouselMode(req.CarouselIntervalMs),
+		nil,
+		nil,
+		nil,
+		lct.CencNull,
+		true,
+		nil,
+		req.MD5,
+	)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.insert(w, req.Priority, obj, req.Update, req.Publish)
+}
+
+// uploadObject 请求体即对象内容；参数：content_location（必填）、content_type、priority、
+// max_transfer_count、carousel_interval_ms、md5、update、publish
+func (s *Server) uploadObject(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("content_location") == "" {
+		writeError(w, http.StatusBadRequest, errors.New("content_location is required"))
+		return
+	}
+	cl, err := contentLocation(q.Get("content_location"), "")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	priority, err1 := queryUint(q, "priority")
+	mtc, err2 := queryUint(q, "max_transfer_count")
+	carousel, err3 := queryUint(q, "carousel_interval_ms")
+	if err := errors.Join(err1, err2, err3); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.opts.MaxUploadSize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	obj, err := sender.CreateFromBuffer(
+		body,
+		contentTypeOr(q.Get("content_type")),
+		cl,
+		maxTransferCount(uint32(mtc)),
+		carouselMode(uint32(carousel)),
+		nil,
+		nil,
+		nil,
+		lct.CencNull,
+		true,
+		nil,
+		queryBool(q, "md5"),
+	)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.insert(w, uint32(priority), obj, queryBool(q, "update"), queryBool(q, "publish"))
+}
+
+func (s *Server) insert(w http.ResponseWriter, priority uint32, obj *sender.ObjectDesc, update, publish bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var toi t.Uint128
+	var err error
+	if update {
+		toi, err = s.sender.UpdateObject(priority, obj)
+	} else {
+		toi, err = s.sender.AddObject(priority, obj)
+	}
+	if err != nil {
+		// 未加入的对象不会由 FDT 释放，在此关闭文件/临时文件
+		obj.Source.Close()
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if publish {
+		if err := s.sender.Publish(s.sender.Now()); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	for _, o := range s.objectsLocked() {
+		if o.Toi == toi.String() {
+			writeJSON(w, http.StatusCreated, o)
+			return
+		}
+	}
+	writeJSON(w, http.StatusCreated, ObjectInfo{Toi: toi.String()})
+}
+
+func (s *Server) removeObject(w http.ResponseWriter, r *http.Request) {
+	toi, err := parseToi(r.PathValue("toi"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.sender.RemoveObject(toi) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("object %s not found", toi))
+		return
+	}
+	if queryBool(r.URL.Query(), "publish") {
+		if err := s.sender.Publish(s.sender.Now()); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) triggerTransfer(w http.ResponseWriter, r *http.Request) {
+	toi, err := parseToi(r.PathValue("toi"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var req TriggerRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	s.mu.Lock()
+	ok := s.sender.TriggerTransferAt(toi, req.At)
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("object %s not found", toi))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) publish(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	err := s.sender.Publish(s.sender.Now())
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) stats(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	resp := StatsResponse{
+		TSI:       s.sender.GetTSI(),
+		NbObjects: s.sender.NbObjects(),
+		Objects:   s.objectsLocked(),
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// ---- 小工具 ----
+
+// objectsLocked 调用方持锁
+func (s *Server) objectsLocked() []ObjectInfo {
+	objs := s.sender.GetObjectsInFDT()
+	out := make([]ObjectInfo, 0, len(objs))
+	for toi, o := range objs {
+		info := ObjectInfo{
+			Toi:            toi,
+			ContentType:    o.ContentType,
+			ContentLength:  o.ContentLength,
+			TransferLength: o.TransferLength,
+			ContentVersion: o.ContentVersion,
+			ETag:           o.ETag,
+		}
+		if o.ContentLocation != nil {
+			info.ContentLocation = o.ContentLocation.String()
+		}
+		if n := s.sender.NbTransfers(t.StringToUint128(toi)); n != nil {
+			info.NbTransfers = *n
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Toi < out[j].Toi })
+	return out
+}
+
+func isLoopback(a net.Addr) bool {
+	tcp, ok := a.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+// checkPath 解析符号链接后判断是否位于允许的目录之下，返回解析后的路径
+func (s *Server) checkPath(p string) (string, error) {
+	if len(s.opts.AllowedDirs) == 0 {
+		return "", errors.New("adding objects by path is disabled: no allowed directories configured")
+	}
+	resolved, err := resolvePath(p)
+	if err != nil {
+		return "", fmt.Errorf("path %s: %w", p, err)
+	}
+	for _, dir := range s.opts.AllowedDirs {
+		d, err := resolvePath(dir)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(d, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("path %s is outside the allowed directories", p)
+}
+
+func resolvePath(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// parseToi 接受 32 位十六进制（与列表输出一致）或十进制
+func parseToi(s string) (t.Uint128, error) {
+	if len(s) == 32 {
+		if _, err := strconv.ParseUint(s[:16], 16, 64); err == nil {
+			if _, err := strconv.ParseUint(s[16:], 16, 64); err == nil {
+				return t.StringToUint128(s), nil
+			}
+		}
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return t.Uint128{}, fmt.Errorf("invalid TOI %q", s)
+	}
+	return t.FromUint64(v), nil
+}
+
+func contentLocation(s, fileName string) (*url.URL, error) {
+	if s == "" {
+		return url.Parse("file:///" + url.PathEscape(fileName))
+	}
+	return url.Parse(s)
+}
+
+func contentTypeOr(s string) string {
+	if s == "" {
+		return "application/octet-stream"
+	}
+	return s
+}
+
+func maxTransferCount(v uint32) uint32 {
+	if v == 0 {
+		return 1
+	}
+	return v
+}
+
+func carouselMode(ms uint32) *sender.CarouselRepeatMode {
+	if ms == 0 {
+		return nil
+	}
+	return &sender.CarouselRepeatMode{
+		Choice:   sender.IntervalBetweenStartTimes,
+		Interval: time.Duration(ms) * time.Millisecond,
+	}
+}
+
+func queryUint(q url.Values, key string) (uint64, error) {
+	v := q.Get(key)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func queryBool(q url.Values, key string) bool {
+	b, _ := strconv.ParseBool(q.Get(key))
+	return b
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
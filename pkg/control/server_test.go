@@ -0,0 +1,162 @@
+package control
+
+import (
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/sender"
+	"Flute_go/pkg/transport"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestControlServer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.bin")
+	if err := os.WriteFile(path, []byte("payload"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	endpoint := transport.NewUDPEndpoint(nil, "224.0.0.1", 1234)
+	s := sender.NewSender(endpoint, 1, oti.NewOti(), nil)
+	srv := httptest.NewServer(NewServer(s, &sync.Mutex{}, Options{Token: "secret", AllowedDirs: []string{dir}}))
+	defer srv.Close()
+
+	do := func(method, url string, body []byte) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+url, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// 未授权
+	if resp, _ := http.Get(srv.URL + "/objects"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", resp.StatusCode)
+	}
+
+	// 目录外的路径被拒绝
+	body, _ := json.Marshal(AddRequest{Path: "/etc/passwd"})
+	if resp := do("POST", "/objects", body); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", resp.StatusCode)
+	}
+
+	body, _ = json.Marshal(AddRequest{Path: path, CacheInRAM: true, Publish: true})
+	resp := do("POST", "/objects", body)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("add by path: status %d", resp.StatusCode)
+	}
+	var added ObjectInfo
+	json.NewDecoder(resp.Body).Decode(&added)
+	if added.ContentLocation != "file:///a.bin" || added.ContentLength != 7 {
+		t.Fatalf("unexpected object: %+v", added)
+	}
+
+	resp = do("PUT", "/objects/upload?content_location=file:///b.txt&content_type=text/plain", []byte("hello"))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload: status %d", resp.StatusCode)
+	}
+
+	var list []ObjectInfo
+	json.NewDecoder(do("GET", "/objects", nil).Body).Decode(&list)
+	if len(list) != 2 {
+		t.Fatalf("expected 2 objects, got %+v", list)
+	}
+
+	if resp := do("POST", "/objects/"+added.Toi+"/trigger", []byte(`{}`)); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("trigger: status %d", resp.StatusCode)
+	}
+	if resp := do("DELETE", "/objects/"+added.Toi+"?publish=true", nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	if resp := do("GET", "/objects/"+added.Toi, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("deleted object still listed: status %d", resp.StatusCode)
+	}
+
+	var stats StatsResponse
+	json.NewDecoder(do("GET", "/stats", nil).Body).Decode(&stats)
+	if stats.TSI != 1 || stats.NbObjects != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+// 无 Bearer 前缀、目录外的符号链接、未配置目录与无令牌的非回环监听都被拒绝
+func TestControlServerAccess(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	secret := filepath.Join(outside, "secret")
+	if err := os.WriteFile(secret, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(secret, link); err != nil {
+		t.Fatal(err)
+	}
+
+	s := sender.NewSender(transport.NewUDPEndpoint(nil, "224.0.0.1", 1234), 1, oti.NewOti(), nil)
+	post := func(h http.Handler, auth, path string) int {
+		body, _ := json.Marshal(AddRequest{Path: path})
+		req := httptest.NewRequest("POST", "/objects", bytes.NewReader(body))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	h := NewServer(s, &sync.Mutex{}, Options{Token: "secret", AllowedDirs: []string{dir}})
+	if code := post(h, "secret", link); code != http.StatusUnauthorized {
+		t.Fatalf("token without Bearer prefix: status %d", code)
+	}
+	if code := post(h, "Bearer secret", link); code != http.StatusForbidden {
+		t.Fatalf("symlink out of allowed dir: status %d", code)
+	}
+	if code := post(NewServer(s, &sync.Mutex{}, Options{}), "", secret); code != http.StatusForbidden {
+		t.Fatalf("no allowed dirs: status %d", code)
+	}
+
+	if srv, err := Serve("0.0.0.0:0", NewServer(s, &sync.Mutex{}, Options{})); err == nil {
+		srv.Close()
+		t.Fatal("non-loopback listener without token accepted")
+	}
+	srv, err := Serve("127.0.0.1:0", NewServer(s, &sync.Mutex{}, Options{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+}
+
+// 被 Sender 拒绝的对象不能留下打开的文件
+func TestControlServerRejectCloses(t *testing.T) {
+	dir := t.TempDir()
+	o, _ := oti.NewReedSolomonRS28(4, 4, 2)
+	big := filepath.Join(dir, "big.bin")
+	if err := os.WriteFile(big, make([]byte, o.MaxTransferLength()+1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := sender.NewSender(transport.NewUDPEndpoint(nil, "224.0.0.1", 1234), 1, o, nil)
+	h := NewServer(s, &sync.Mutex{}, Options{AllowedDirs: []string{dir}})
+
+	fds := func() int {
+		entries, _ := os.ReadDir("/proc/self/fd")
+		return len(entries)
+	}
+	before := fds()
+	for i := 0; i < 3; i++ {
+		body, _ := json.Marshal(AddRequest{Path: big})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/objects", bytes.NewReader(body)))
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("oversized object: status %d", rec.Code)
+		}
+	}
+	if after := fds(); after > before {
+		t.Fatalf("rejected objects leak files: %d -> %d", before, after)
+	}
+}
@@ -1,8 +1,9 @@
 package fec
 
 import (
+	"Flute_go/pkg/tools"
 	"fmt"
-	"log"
+	"log/slog"
 
 	rs "github.com/klauspost/reedsolomon"
 )
@@ -20,11 +21,22 @@ type RSGalois8Codec struct {
 	DecodeBlock               []byte
 	NbSourceSymbolsReceived   uint
 	NbEncodingSymbolsReceived uint
+	// 所在源块号，随 Warn 日志输出
+	Sbn uint32
+	// nil 时使用 slog.Default()；逐符号日志仅在 Debug 级别输出
+	Logger *slog.Logger
 }
 
-func (param *RSCodecParam) createShards(data []byte) ([][]byte, error) {
-	log.Printf("Creating shards with nb_source_symbols=%d, nb_parity_symbols=%d, encoding_symbol_length=%d",
-		param.NbSourceSymbols, param.NbParitySymbols, param.EncodingSymbolLength)
+// SetLogger 注入 logger（一般已带 tsi/toi 字段，sbn 由 Sbn 字段补充）
+func (codec *RSGalois8Codec) SetLogger(l *slog.Logger) { codec.Logger = l }
+
+func (codec *RSGalois8Codec) log() *slog.Logger { return tools.LoggerOrDefault(codec.Logger) }
+
+func (param *RSCodecParam) createShards(data []byte, l *slog.Logger) ([][]byte, error) {
+	if tools.DebugEnabled(l) {
+		l.Debug("creating shards", "nb_source_symbols", param.NbSourceSymbols,
+			"nb_parity_symbols", param.NbParitySymbols, "encoding_symbol_length", param.EncodingSymbolLength)
+	}
 
 	var shards [][]byte
 	for i := uint(0); i < uint(len(data)); i += param.EncodingSymbolLength {
@@ -40,7 +52,9 @@ func (param *RSCodecParam) createShards(data []byte) ([][]byte, error) {
 	// 填充最后一个分片
 	last := shards[len(shards)-1]
 	if uint(len(last)) < param.EncodingSymbolLength {
-		log.Printf("Padding last shard from %d to %d bytes", len(last), param.EncodingSymbolLength)
+		if tools.DebugEnabled(l) {
+			l.Debug("padding last shard", "from", len(last), "to", param.EncodingSymbolLength)
+		}
 		padded := make([]byte, param.EncodingSymbolLength)
 		copy(padded, last)
 		shards[len(shards)-1] = padded
@@ -61,11 +75,6 @@ func (param *RSCodecParam) createShards(data []byte) ([][]byte, error) {
 
 // NewRSGalois8Codec 创建新的 RS 编码器
 func NewRSGalois8Codec(nbSourceSymbols, nbParitySymbols, encodingSymbolLength uint) (*RSGalois8Codec, error) {
-	log.Printf(
-		"Creating new RS codec with: source_symbols=%d, parity_symbols=%d, symbol_length=%d",
-		nbSourceSymbols, nbParitySymbols, encodingSymbolLength,
-	)
-
 	// 创建 reedsolomon 编码器
 	enc, err := rs.New(int(nbSourceSymbols), int(nbParitySymbols))
 	if err != nil {
@@ -89,18 +98,23 @@ func NewRSGalois8Codec(nbSourceSymbols, nbParitySymbols, encodingSymbolLength ui
 }
 
 func (codec *RSGalois8Codec) PushSymbol(encodingSymbol []byte, esi uint32) {
+	l := codec.log()
+	debug := tools.DebugEnabled(l)
 	if codec.DecodeBlock != nil {
-		log.Printf("Block already decoded, ignoring new symbol")
+		if debug {
+			l.Debug("block already decoded, ignoring symbol", "esi", esi)
+		}
 		return
 	}
-	log.Printf("Receive ESI %d (length: %d bytes)", esi, len(encodingSymbol))
 	if int(esi) >= len(codec.DecodeShards) {
-		log.Printf("ESI %d out of range (max %d)", esi, len(codec.DecodeShards))
+		l.Warn("ESI out of range", "sbn", codec.Sbn, "esi", esi, "max", len(codec.DecodeShards))
 		return
 	}
 
 	if codec.DecodeShards[esi] != nil {
-		log.Printf("Already received symbol for ESI %d", esi)
+		if debug {
+			l.Debug("duplicate symbol", "esi", esi)
+		}
 		return
 	}
 
@@ -114,76 +128,68 @@ func (codec *RSGalois8Codec) PushSymbol(encodingSymbol []byte, esi uint32) {
 	}
 	codec.NbEncodingSymbolsReceived++
 
-	log.Printf(
-		"Received symbols: %d/%d source, %d/%d total",
-		codec.NbSourceSymbolsReceived, codec.Params.NbSourceSymbols,
-		codec.NbEncodingSymbolsReceived, codec.Params.NbSourceSymbols+codec.Params.NbParitySymbols,
-	)
+	if debug {
+		l.Debug("symbol received", "esi", esi, "length", len(encodingSymbol),
+			"source_received", codec.NbSourceSymbolsReceived,
+			"total_received", codec.NbEncodingSymbolsReceived)
+	}
 }
 
 func (codec *RSGalois8Codec) CanDecode() bool {
-	canDecode := codec.NbEncodingSymbolsReceived >= uint(codec.Params.NbSourceSymbols)
-	log.Printf(
-		"Can decode: %t (have %d symbols, need %d)",
-		canDecode,
-		codec.NbEncodingSymbolsReceived,
-		codec.Params.NbSourceSymbols,
-	)
-	return canDecode
+	return codec.NbEncodingSymbolsReceived >= uint(codec.Params.NbSourceSymbols)
 }
 
 // Decode 尝试进行解码
 func (codec *RSGalois8Codec) Decode() bool {
 	if codec.DecodeBlock != nil {
-		log.Printf("Block already decoded")
 		return true
 	}
 
+	l := codec.log()
 	if codec.NbSourceSymbolsReceived < uint(codec.Params.NbSourceSymbols) {
-		log.Printf(
-			"Attempting reconstruction (have %d/%d source symbols)",
-			codec.NbSourceSymbolsReceived, codec.Params.NbSourceSymbols,
-		)
 		err := codec.Rs.Reconstruct(codec.DecodeShards)
 		if err != nil {
-			log.Printf("Reconstruction failed: %v", err)
+			l.Warn("reconstruction failed", "sbn", codec.Sbn, "source_received", codec.NbSourceSymbolsReceived,
+				"nb_source_symbols", codec.Params.NbSourceSymbols, "err", err)
 			return false
 		}
-		log.Printf("Reconstruct with success !")
+		if tools.DebugEnabled(l) {
+			l.Debug("block reconstructed", "source_received", codec.NbSourceSymbolsReceived,
+				"nb_source_symbols", codec.Params.NbSourceSymbols)
+		}
 	}
 
 	// 拼接 source block
 	var output []byte
 	for i := uint(0); i < codec.Params.NbSourceSymbols; i++ {
 		if codec.DecodeShards[i] == nil {
-			log.Printf("Missing shard at index %d", i)
+			l.Warn("missing shard", "sbn", codec.Sbn, "index", i)
 			return false
 		}
 		output = append(output, codec.DecodeShards[i]...)
 	}
 
-	log.Printf("Successfully decoded block (%d bytes)", len(output))
+	if tools.DebugEnabled(l) {
+		l.Debug("block decoded", "bytes", len(output))
+	}
 	codec.DecodeBlock = output
 	return true
 }
 
 func (codec *RSGalois8Codec) SourceBlock() ([]byte, error) {
 	if codec.DecodeBlock == nil {
-		log.Printf("Block not decoded yet")
 		return nil, fmt.Errorf("block not decoded")
 	}
 	return codec.DecodeBlock, nil
 }
 
 func (codec *RSGalois8Codec) Encode(data []byte) ([]FecShard, error) {
-	log.Printf("Encoding data (%d bytes)", len(data))
-
-	shards, err := codec.Params.createShards(data)
+	l := codec.log()
+	shards, err := codec.Params.createShards(data, l)
 	if err != nil {
 		return nil, fmt.Errorf("fail to create shards: %w", err)
 	}
 
-	log.Printf("Encoding shards with Reed-Solomon")
 	if err := codec.Rs.Encode(shards); err != nil {
 		return nil, fmt.Errorf("fail to encode shards: %w", err)
 	}
@@ -196,6 +202,8 @@ func (codec *RSGalois8Codec) Encode(data []byte) ([]FecShard, error) {
 		})
 	}
 
-	log.Printf("Successfully encode %d shards with Reed-Solomon", len(result))
+	if tools.DebugEnabled(l) {
+		l.Debug("block encoded", "bytes", len(data), "shards", len(result))
+	}
 	return result, nil
 }
@@ -1,6 +1,9 @@
 package fec
 
 import (
+	"bytes"
+	"log/slog"
+	"strings"
 	"testing"
 )
 
@@ -24,3 +27,24 @@ func TestEncoder(t *testing.T) {
 		t.Errorf("expected non-empty shards, got %d", len(shards))
 	}
 }
+
+func TestDecodeWarnCarriesSbn(t *testing.T) {
+	codec, err := NewRSGalois8Codec(2, 1, 4)
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+	var buf bytes.Buffer
+	codec.Sbn = 7
+	codec.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	codec.PushSymbol([]byte{1, 2, 3, 4}, 0)
+	codec.PushSymbol([]byte{1, 2, 3, 4}, 9)
+	if codec.Decode() {
+		t.Fatalf("decode must fail with a single symbol")
+	}
+	for _, msg := range []string{"ESI out of range", "reconstruction failed"} {
+		if !strings.Contains(buf.String(), `msg="`+msg+`" sbn=7`) {
+			t.Fatalf("missing sbn on %q: %s", msg, buf.String())
+		}
+	}
+}
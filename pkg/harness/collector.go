@@ -0,0 +1,243 @@
+package harness
+
+import (
+	"Flute_go/pkg/alc"
+	"Flute_go/pkg/fec"
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/object"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/receiver"
+	"Flute_go/pkg/tools"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// collector 最小接收端：解析 ALC、按块收集符号（NoCode 直接拼接，RS28 用 fec.RSGalois8Codec 恢复），
+// 从 FDT 取得 Content-Location/编码/MD5 后解压并校验
+// 依赖包内 FTI（Sender 默认开启）；没有 FTI 的包计入 Result.PacketsUndecodable
+// 注意：alc 目前没有注册 NoCode 编解码器（alcnocode.go 为空），NoCode 包不带 FTI/FEC Payload ID，无法接收
+type collector struct {
+	start   time.Time
+	files   map[string]object.FdtFile // TOI -> FDT 中的文件项
+	objects map[string]*objectRx      // TOI 或 "fdt/<id>" -> 接收状态
+	results map[string]*FileResult    // Content-Location -> 结果
+
+	received, corrupt, undecodable uint64
+}
+
+type objectRx struct {
+	toi      string
+	o        oti.Oti
+	length   uint64
+	cenc     lct.Cenc // 仅 FDT：来自 EXT_CENC
+	blocks   []*blockRx
+	pending  int
+	complete bool
+	data     []byte
+	doneAt   time.Time
+}
+
+type blockRx struct {
+	size     uint64 // 字节
+	nbSource uint64
+	symbols  map[uint32][]byte // NoCode
+	rs       *fec.RSGalois8Codec
+	data     []byte
+}
+
+func newCollector(start time.Time) *collector {
+	return &collector{
+		start:   start,
+		files:   make(map[string]object.FdtFile),
+		objects: make(map[string]*objectRx),
+		results: make(map[string]*FileResult),
+	}
+}
+
+func (c *collector) push(data []byte, now time.Time) {
+	c.received++
+	pkt, err := alc.ParseAlcPkt(data)
+	if err != nil {
+		c.corrupt++
+		return
+	}
+	key := pkt.Lct.Toi.String()
+	isFdt := pkt.Lct.Toi == lct.TOI_FDT
+	if isFdt {
+		if pkt.FdtInfo == nil {
+			c.corrupt++
+			return
+		}
+		key = "fdt/" + strconv.FormatUint(uint64(pkt.FdtInfo.FdtInstanceID), 10)
+	}
+
+	obj := c.objects[key]
+	if obj == nil {
+		if pkt.Oti == nil || pkt.TransferLength == nil || *pkt.TransferLength == 0 {
+			c.undecodable++
+			return
+		}
+		obj, err = newObjectRx(pkt.Lct.Toi.String(), *pkt.Oti, *pkt.TransferLength)
+		if err != nil {
+			c.undecodable++
+			return
+		}
+		if pkt.Cenc != nil {
+			obj.cenc = *pkt.Cenc
+		}
+		c.objects[key] = obj
+	}
+	if obj.complete {
+		return
+	}
+
+	pid, err := alc.ParsePayloadID(pkt, &obj.o)
+	if err != nil || int(pid.Sbn) >= len(obj.blocks) || pkt.DataPayloadOffset > len(pkt.Data) {
+		c.corrupt++
+		return
+	}
+	if !obj.pushSymbol(pid.Sbn, pid.Esi, pkt.Data[pkt.DataPayloadOffset:]) {
+		c.corrupt++
+		return
+	}
+	if obj.pending > 0 {
+		return
+	}
+
+	obj.complete = true
+	obj.doneAt = now
+	obj.data = obj.data[:0]
+	for _, b := range obj.blocks {
+		obj.data = append(obj.data, b.data...)
+		b.data, b.symbols, b.rs = nil, nil, nil
+	}
+	if isFdt {
+		c.onFdt(obj)
+		return
+	}
+	c.finalize(obj)
+}
+
+func newObjectRx(toi string, o oti.Oti, length uint64) (*objectRx, error) {
+	if o.EncodingSymbolLength == 0 || o.MaximumSourceBlockLength == 0 {
+		return nil, fmt.Errorf("invalid OTI")
+	}
+	esl := uint64(o.EncodingSymbolLength)
+	aLarge, aSmall, nbALarge, nbBlocks := object.BlockPartitioning(uint64(o.MaximumSourceBlockLength), length, esl)
+	obj := &objectRx{toi: toi, o: o, length: length, blocks: make([]*blockRx, 0, nbBlocks)}
+	remaining := length
+	for sbn := uint64(0); sbn < nbBlocks; sbn++ {
+		blockLen := aSmall
+		if sbn < nbALarge {
+			blockLen = aLarge
+		}
+		size := min(blockLen*esl, remaining)
+		remaining -= size
+		b := &blockRx{size: size, nbSource: tools.DivCeil(size, esl)}
+		switch o.FecEncodingID {
+		case oti.NoCode:
+			b.symbols = make(map[uint32][]byte)
+		case oti.ReedSolomonGF28, oti.ReedSolomonGF28UnderSpecified:
+			rs, err := fec.NewRSGalois8Codec(uint(b.nbSource), uint(o.MaxNumberOfParitySymbols), uint(esl))
+			if err != nil {
+				return nil, err
+			}
+			rs.Sbn = uint32(sbn)
+			b.rs = rs
+		default:
+			return nil, fmt.Errorf("FEC %s not supported by the harness", o.FecEncodingID)
+		}
+		obj.blocks = append(obj.blocks, b)
+	}
+	obj.pending = len(obj.blocks)
+	return obj, nil
+}
+
+// pushSymbol 长度不符（如被截断）的符号返回 false
+func (o *objectRx) pushSymbol(sbn, esi uint32, payload []byte) bool {
+	b := o.blocks[sbn]
+	if b.data != nil {
+		return true
+	}
+	esl := uint64(o.o.EncodingSymbolLength)
+	if b.rs != nil {
+		if uint64(len(payload)) != esl || esi >= uint32(len(b.rs.DecodeShards)) {
+			return false
+		}
+		b.rs.PushSymbol(payload, esi)
+		if b.rs.CanDecode() && b.rs.Decode() {
+			block, _ := b.rs.SourceBlock()
+			b.data = block[:b.size]
+			o.pending--
+		}
+		return true
+	}
+	if uint64(esi) >= b.nbSource || uint64(len(payload)) != min(esl, b.size-uint64(esi)*esl) {
+		return false
+	}
+	b.symbols[esi] = append([]byte(nil), payload...)
+	if uint64(len(b.symbols)) == b.nbSource {
+		b.data = make([]byte, 0, b.size)
+		for i := uint32(0); uint64(i) < b.nbSource; i++ {
+			b.data = append(b.data, b.symbols[i]...)
+		}
+		o.pending--
+	}
+	return true
+}
+
+func (c *collector) onFdt(obj *objectRx) {
+	raw, err := receiver.Decompress(obj.data, obj.cenc)
+	if err != nil {
+		return
+	}
+	var inst object.FdtInstance
+	if err := xml.Unmarshal(raw, &inst); err != nil {
+		return
+	}
+	for _, f := range inst.Files {
+		c.files[f.TOI] = f
+	}
+	// 先于 FDT 收齐的对象
+	for key, o := range c.objects {
+		if o.complete && !strings.HasPrefix(key, "fdt/") {
+			c.finalize(o)
+		}
+	}
+}
+
+func (c *collector) finalize(obj *objectRx) {
+	f, ok := c.files[obj.toi]
+	if !ok {
+		return
+	}
+	if r := c.results[f.ContentLocation]; r != nil && r.Toi == obj.toi {
+		return
+	}
+	r := &FileResult{
+		ContentLocation: f.ContentLocation,
+		Toi:             obj.toi,
+		CompletedAfter:  obj.doneAt.Sub(c.start),
+	}
+	cenc := lct.CencNull
+	if f.ContentEncoding != nil {
+		cenc, _ = lct.CencFromContentEncoding(*f.ContentEncoding)
+	}
+	data, err := receiver.Decompress(obj.data, cenc)
+	if err != nil {
+		r.Err = err
+	} else {
+		r.Data = data
+	}
+	if f.ContentMD5 != nil && r.Err == nil {
+		sum := md5.Sum(r.Data)
+		ok := base64.StdEncoding.EncodeToString(sum[:]) == *f.ContentMD5
+		r.MD5OK = &ok
+	}
+	c.results[f.ContentLocation] = r
+}
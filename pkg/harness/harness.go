@@ -0,0 +1,196 @@
+// Package harness 端到端仿真：Sender 经模拟信道（transport.Loopback + ImpairedConn）发给最小接收端，
+// 全程使用虚拟时钟，数小时的场景在毫秒内确定性地跑完；作为轮播、交织与 FEC 改动的回归测试
+package harness
+
+import (
+	"Flute_go/pkg/clock"
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/sender"
+	"Flute_go/pkg/transport"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Scenario 一个端到端场景
+type Scenario struct {
+	// 默认 OTI（nil = RS28，1400 字节符号、块长 64、8 个修复符号）
+	// NoCode 包不带 FTI，最小接收端无法接收，会返回错误
+	OTI *oti.Oti
+	// 发送端配置，nil = sender.DefaultConfig()；Clock 由 harness 设置
+	Config *sender.Config
+	TSI    uint64
+
+	Files []File
+
+	// 信道损伤，按顺序执行，Seed 相同则结果相同
+	Channel []transport.Stage
+	Seed    int64
+
+	// 发送速率（bit/s，按 UDP 载荷计），0 = 1 Mbit/s；虚拟时间按此推进
+	RateBps uint64
+	// 接收端在开始后多久加入，之前的包全部丢失
+	JoinAfter time.Duration
+	// 虚拟时间上限，0 = 1 小时
+	Timeout time.Duration
+	// 虚拟时间起点，零值 = 2024-01-01 UTC
+	Start time.Time
+}
+
+// File 场景中的一个文件
+type File struct {
+	// Content-Location，空 = file:///file<i>
+	ContentLocation string
+	Data            []byte
+	Priority        uint32
+	Cenc            lct.Cenc
+	// 在 FDT 中携带 Content-MD5，接收端据此校验
+	MD5 bool
+	// 轮播；nil 时只发 MaxTransferCount 轮
+	Carousel         *sender.CarouselRepeatMode
+	MaxTransferCount uint32
+}
+
+// Result 场景运行结果
+type Result struct {
+	// Content-Location -> 接收结果，未收齐的文件不在其中
+	Files map[string]*FileResult
+	// 全部文件收齐（或发送端已无对象可发）时的虚拟时长
+	Elapsed time.Duration
+
+	PacketsSent        uint64
+	PacketsReceived    uint64 // 接收端加入后收到的包
+	PacketsCorrupt     uint64 // 解析失败或长度不符（如被截断）
+	PacketsUndecodable uint64 // 缺少 FTI 等无法处理的包
+	PacketsBeforeJoin  uint64 // 接收端加入前到达、被丢弃的包
+}
+
+// FileResult 单个文件的接收结果
+type FileResult struct {
+	ContentLocation string
+	Toi             string
+	Data            []byte // 已解压
+	// FDT 携带 Content-MD5 时的校验结果，否则为 nil
+	MD5OK *bool
+	// 从场景开始到最后一个所需符号到达的虚拟时长
+	CompletedAfter time.Duration
+	Err            error
+}
+
+// Complete 收齐且解压、MD5 校验均通过
+func (r *FileResult) Complete() bool {
+	return r != nil && r.Err == nil && (r.MD5OK == nil || *r.MD5OK)
+}
+
+// idleStep 发送端无包可发时虚拟时间的步进
+const idleStep = 10 * time.Millisecond
+
+// Run 运行场景，直到全部文件收齐、发送端无对象可发或超时
+func Run(sc Scenario) (*Result, error) {
+	if len(sc.Files) == 0 {
+		return nil, errors.New("harness: scenario has no files")
+	}
+	o := sc.OTI
+	if o == nil {
+		var err error
+		if o, err = oti.NewReedSolomonRS28(1400, 64, 8); err != nil {
+			return nil, err
+		}
+	}
+	if o.FecEncodingID == oti.NoCode {
+		return nil, errors.New("harness: NoCode packets carry no FTI and cannot be received, use Reed-Solomon")
+	}
+	cfg := sender.DefaultConfig()
+	if sc.Config != nil {
+		cfg = *sc.Config
+	}
+	start := sc.Start
+	if start.IsZero() {
+		start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	rate := sc.RateBps
+	if rate == 0 {
+		rate = 1_000_000
+	}
+	timeout := sc.Timeout
+	if timeout == 0 {
+		timeout = time.Hour
+	}
+
+	clk := clock.NewVirtual(start)
+	cfg.Clock = clk
+	if err := sender.ValidateConfig(o, &cfg, 0); err != nil {
+		return nil, err
+	}
+	s := sender.NewSender(transport.NewUDPEndpoint(nil, "224.0.0.1", 3400), sc.TSI, o, &cfg)
+	for i, f := range sc.Files {
+		cl := f.ContentLocation
+		if cl == "" {
+			cl = fmt.Sprintf("file:///file%d", i)
+		}
+		u, err := url.Parse(cl)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := sender.CreateFromBuffer(f.Data, "application/octet-stream", u, f.MaxTransferCount,
+			f.Carousel, nil, nil, nil, f.Cenc, f.Cenc.InbandCapable(), nil, f.MD5)
+		if err != nil {
+			return nil, fmt.Errorf("harness: %s: %w", cl, err)
+		}
+		if _, err := s.AddObject(f.Priority, obj); err != nil {
+			return nil, fmt.Errorf("harness: %s: %w", cl, err)
+		}
+	}
+	if err := s.Publish(clk.Now()); err != nil {
+		return nil, err
+	}
+
+	lb := transport.NewLoopback(1 << 16)
+	defer lb.Close()
+	tx := transport.NewImpairedConn(lb.Join("sender"), sc.Seed, clk, sc.Channel...)
+	rx := lb.Join("receiver")
+	col := newCollector(start)
+	res := &Result{}
+	joinAt := start.Add(sc.JoinAfter)
+	deadline := start.Add(timeout)
+	buf := make([]byte, 65536)
+
+	for clk.Now().Before(deadline) && len(col.results) < len(sc.Files) {
+		data := s.Read(clk.Now())
+		if data == nil {
+			if len(s.GetObjectsInFDT()) == 0 && tx.Pending() == 0 && rx.Queued() == 0 {
+				break
+			}
+			clk.Advance(idleStep)
+			if err := tx.Flush(); err != nil {
+				return nil, err
+			}
+		} else {
+			res.PacketsSent++
+			if _, err := tx.WriteTo(data, nil); err != nil {
+				return nil, err
+			}
+			clk.Advance(time.Duration(float64(len(data)) * 8 / float64(rate) * float64(time.Second)))
+		}
+		for rx.Queued() > 0 {
+			n, _, err := rx.ReadFrom(buf)
+			if err != nil {
+				return nil, err
+			}
+			if clk.Now().Before(joinAt) {
+				res.PacketsBeforeJoin++
+				continue
+			}
+			col.push(buf[:n], clk.Now())
+		}
+	}
+
+	res.Files = col.results
+	res.Elapsed = clk.Now().Sub(start)
+	res.PacketsReceived = col.received
+	res.PacketsCorrupt = col.corrupt
+	res.PacketsUndecodable = col.undecodable
+	return res, nil
+}
@@ -0,0 +1,138 @@
+package harness
+
+import (
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/sender"
+	"Flute_go/pkg/transport"
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func randomData(seed int64, n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+func checkFiles(t *testing.T, sc Scenario, res *Result) {
+	t.Helper()
+	for i, f := range sc.Files {
+		cl := f.ContentLocation
+		r := res.Files[cl]
+		if !r.Complete() {
+			t.Fatalf("file %d (%s) not delivered: %+v", i, cl, r)
+		}
+		if !bytes.Equal(r.Data, f.Data) {
+			t.Fatalf("file %d (%s): delivered bytes differ", i, cl)
+		}
+		if f.MD5 && (r.MD5OK == nil || !*r.MD5OK) {
+			t.Fatalf("file %d (%s): MD5 not verified", i, cl)
+		}
+	}
+}
+
+// 三个文件，RS28 20% 校验，10% 突发丢包，接收端晚 5 s 加入
+func TestBurstLossLateJoin(t *testing.T) {
+	o, _ := oti.NewReedSolomonRS28(1024, 50, 10)
+	carousel := &sender.CarouselRepeatMode{Choice: sender.DelayBetweenTransfers, Interval: 500 * time.Millisecond}
+	sc := Scenario{
+		OTI: o,
+		Files: []File{
+			{ContentLocation: "file:///a.bin", Data: randomData(1, 300_000), MD5: true, Carousel: carousel},
+			{ContentLocation: "file:///b.txt", Data: bytes.Repeat([]byte("flute "), 40_000), Cenc: lct.CencGzip, MD5: true, Carousel: carousel},
+			{ContentLocation: "file:///c.bin", Data: randomData(3, 120_000), MD5: true, Carousel: carousel},
+		},
+		// 稳态丢包率 p/(p+r) = 10%，平均突发长度 1/r ≈ 3 包
+		Channel:   []transport.Stage{transport.NewGilbertElliott(0.1/0.9*0.3, 0.3)},
+		Seed:      7,
+		RateBps:   8_000_000,
+		JoinAfter: 5 * time.Second,
+	}
+	res, err := Run(sc)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkFiles(t, sc, res)
+	if res.PacketsBeforeJoin == 0 {
+		t.Fatalf("packets before the join must be dropped")
+	}
+	for cl, r := range res.Files {
+		if r.CompletedAfter < 5*time.Second {
+			t.Fatalf("%s completed before the receiver joined: %s", cl, r.CompletedAfter)
+		}
+	}
+	if res.Elapsed > time.Minute {
+		t.Fatalf("scenario took too long: %s", res.Elapsed)
+	}
+
+	// 相同种子可复现
+	again, err := Run(sc)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if again.Elapsed != res.Elapsed || again.PacketsSent != res.PacketsSent {
+		t.Fatalf("same seed must reproduce the run: %s/%d vs %s/%d",
+			again.Elapsed, again.PacketsSent, res.Elapsed, res.PacketsSent)
+	}
+}
+
+// 无损信道：一轮传输即可收齐，时长由速率决定
+func TestLosslessTiming(t *testing.T) {
+	o, _ := oti.NewReedSolomonRS28(1400, 60, 4)
+	sc := Scenario{
+		OTI:     o,
+		Files:   []File{{ContentLocation: "file:///x.bin", Data: randomData(2, 1_000_000), MD5: true, MaxTransferCount: 1}},
+		RateBps: 10_000_000,
+	}
+	res, err := Run(sc)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkFiles(t, sc, res)
+	// 1 MB 载荷 @ 10 Mbit/s ≈ 0.8 s，另含 RS 校验、头部与 FDT
+	if d := res.Files["file:///x.bin"].CompletedAfter; d < 800*time.Millisecond || d > time.Second {
+		t.Fatalf("unexpected completion time %s", d)
+	}
+	if res.PacketsCorrupt != 0 || res.PacketsUndecodable != 0 {
+		t.Fatalf("unexpected bad packets: %+v", res)
+	}
+}
+
+// 校验符号不足、只发一轮时，丢包导致文件缺失
+func TestLossWithoutCarousel(t *testing.T) {
+	o, _ := oti.NewReedSolomonRS28(1400, 60, 2)
+	sc := Scenario{
+		OTI:     o,
+		Files:   []File{{ContentLocation: "file:///x.bin", Data: randomData(4, 200_000), MaxTransferCount: 1}},
+		Channel: []transport.Stage{transport.RandomLoss{P: 0.2}, transport.Truncate{P: 0.05}},
+		Seed:    1,
+	}
+	res, err := Run(sc)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if res.Files["file:///x.bin"] != nil {
+		t.Fatalf("file must not be delivered over a lossy channel without FEC or carousel")
+	}
+	if res.PacketsCorrupt == 0 {
+		t.Fatalf("truncated packets must be counted as corrupt")
+	}
+}
+
+// 未指定 OTI 时使用可接收的 RS28；NoCode 直接报错而不是静默收不到
+func TestDefaultOti(t *testing.T) {
+	sc := Scenario{Files: []File{{ContentLocation: "file:///d.bin", Data: randomData(4, 50_000), MD5: true}}}
+	res, err := Run(sc)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkFiles(t, sc, res)
+
+	sc.OTI = oti.NewOti()
+	if _, err := Run(sc); err == nil {
+		t.Fatalf("NoCode scenario must be rejected")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"strings"
 )
 
 type Cenc uint8
@@ -14,6 +15,9 @@ const (
 	CencZlib
 	CencDeflate
 	CencGzip
+	// 以下编码没有标准化的 EXT_CENC 取值，只在 FDT Content-Encoding 中通告
+	CencZstd
+	CencBrotli
 )
 
 type Ext uint8
@@ -23,6 +27,7 @@ const (
 	ExtFti  Ext = 64
 	ExtCenc Ext = 193
 	ExtTime     = 2
+	ExtAuth Ext = 1 // RFC 5651 EXT_AUTH（TESLA 等源认证方案使用）
 )
 
 var TOI_FDT = t.Uint128{}
@@ -49,6 +54,8 @@ func (e Ext) String() string {
 		return "Cenc"
 	case ExtTime:
 		return "Time"
+	case ExtAuth:
+		return "Auth"
 	default:
 		return "Unknown"
 	}
@@ -64,11 +71,58 @@ func (c Cenc) String() string {
 		return "Deflate"
 	case CencGzip:
 		return "Gzip"
+	case CencZstd:
+		return "Zstd"
+	case CencBrotli:
+		return "Brotli"
 	default:
 		return "Unknown"
 	}
 }
 
+// InbandCapable 是否可以通过 EXT_CENC 在带内通告（RFC 3926 只定义了 0~3）
+func (c Cenc) InbandCapable() bool {
+	return c <= CencGzip
+}
+
+// ContentEncoding FDT Content-Encoding 属性值（HTTP content-coding），Null 为空串
+func (c Cenc) ContentEncoding() string {
+	switch c {
+	case CencZlib:
+		return "zlib"
+	case CencDeflate:
+		return "deflate"
+	case CencGzip:
+		return "gzip"
+	case CencZstd:
+		return "zstd"
+	case CencBrotli:
+		return "br"
+	default:
+		return ""
+	}
+}
+
+// CencFromContentEncoding 解析 FDT Content-Encoding（不区分大小写，兼容 String() 的旧写法）
+func CencFromContentEncoding(s string) (Cenc, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "null", "identity":
+		return CencNull, nil
+	case "zlib":
+		return CencZlib, nil
+	case "deflate":
+		return CencDeflate, nil
+	case "gzip", "x-gzip":
+		return CencGzip, nil
+	case "zstd":
+		return CencZstd, nil
+	case "br", "brotli":
+		return CencBrotli, nil
+	default:
+		return CencNull, fmt.Errorf("unsupported Content-Encoding %q", s)
+	}
+}
+
 // nbBytes128 计算 u128 的最小字节数
 func nbBytes128(cci t.Uint128, min uint32) uint32 {
 	// 高 64 位和低 64 位分别判断
@@ -279,11 +333,21 @@ func ParseLCTHeader(data []byte) (*LCTHeader, error) {
 
 // 拓展头处理
 func GetExt(data []byte, lct *LCTHeader, ext uint8) ([]byte, error) {
+	offset, hel, err := FindExt(data, lct, ext)
+	if err != nil || hel == 0 {
+		return nil, err
+	}
+	return data[offset : offset+hel], nil
+}
+
+// FindExt 查找扩展头，返回其在 data 中的偏移与长度（字节）；未找到时长度为 0
+func FindExt(data []byte, lct *LCTHeader, ext uint8) (int, int, error) {
 	if uint64(lct.HeaderExtOffset) >= lct.Len {
-		return nil, fmt.Errorf("invalid header_ext_offset=%d len=%d",
+		return 0, 0, fmt.Errorf("invalid header_ext_offset=%d len=%d",
 			lct.HeaderExtOffset, lct.Len)
 	}
 
+	offset := int(lct.HeaderExtOffset)
 	lctExt := data[lct.HeaderExtOffset:lct.Len]
 
 	for len(lctExt) >= 4 {
@@ -297,21 +361,22 @@ func GetExt(data []byte, lct *LCTHeader, ext uint8) ([]byte, error) {
 		}
 
 		if hel == 0 || hel > len(lctExt) {
-			return nil, fmt.Errorf(
+			return 0, 0, fmt.Errorf(
 				"fail, LCT EXT size is %d/%d het=%d offset=%d",
 				hel, len(lctExt), het, lct.HeaderExtOffset,
 			)
 		}
 
 		if het == ext {
-			// 找到目标扩展头，返回切片
-			return lctExt[:hel], nil
+			// 找到目标扩展头
+			return offset, hel, nil
 		}
 
 		// 跳过当前扩展头，继续解析下一个
 		lctExt = lctExt[hel:]
+		offset += hel
 	}
 
-	// 没找到，返回 nil
-	return nil, nil
+	// 没找到
+	return 0, 0, nil
 }
@@ -0,0 +1,44 @@
+package metrics
+
+// SenderMetrics 发送端指标
+// queue 为优先级队列编号的十进制字符串，FDT 会话使用 QueueFDT
+type SenderMetrics interface {
+	PacketSent(tsi uint64, queue string, bytes int)
+	FDTPublished(tsi uint64, fdtInstanceID uint32)
+	CarouselCycleCompleted(tsi uint64, toi string)
+	ObjectTransferStarted(tsi uint64, toi string)
+	ObjectTransferStopped(tsi uint64, toi string)
+}
+
+// ReceiverMetrics 接收端指标
+// 注意：本仓库尚无 ALC/FEC 接收流水线（pkg/receiver 只有 FDT 解析与事件类型，cmd/flute_receiver 为空壳），
+// 目前没有任何代码调用这些方法；接口与 Registry 中的 flute_receiver_* 指标族留给流水线接入，
+// /metrics 导出目前只在 flute_sender（-metrics-addr）中提供
+type ReceiverMetrics interface {
+	PacketReceived(tsi uint64, bytes int)
+	PacketsLost(tsi uint64, count uint64)
+	FECRecovered(tsi uint64, toi string, sbn uint32)
+	DecodeFailed(tsi uint64, toi string)
+}
+
+// Metrics 同时覆盖发送端与接收端
+type Metrics interface {
+	SenderMetrics
+	ReceiverMetrics
+}
+
+// QueueFDT FDT 会话的队列标签
+const QueueFDT = "fdt"
+
+// Nop 不做任何事的实现
+type Nop struct{}
+
+func (Nop) PacketSent(uint64, string, int)        {}
+func (Nop) FDTPublished(uint64, uint32)           {}
+func (Nop) CarouselCycleCompleted(uint64, string) {}
+func (Nop) ObjectTransferStarted(uint64, string)  {}
+func (Nop) ObjectTransferStopped(uint64, string)  {}
+func (Nop) PacketReceived(uint64, int)            {}
+func (Nop) PacketsLost(uint64, uint64)            {}
+func (Nop) FECRecovered(uint64, string, uint32)   {}
+func (Nop) DecodeFailed(uint64, string)           {}
@@ -0,0 +1,232 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type metricType string
+
+const (
+	typeCounter metricType = "counter"
+	typeGauge   metricType = "gauge"
+)
+
+type family struct {
+	name    string
+	help    string
+	typ     metricType
+	labels  []string
+	samples map[string]*sample
+}
+
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+// Registry 内存中的指标集合，实现 Metrics，按 Prometheus 文本格式导出
+// 为控制基数，TOI/SBN 不作为标签，只按 TSI（及发送队列）聚合
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	r := &Registry{families: make(map[string]*family)}
+	r.register("flute_sender_packets_total", "ALC packets sent.", typeCounter, "tsi", "queue")
+	r.register("flute_sender_bytes_total", "ALC bytes sent.", typeCounter, "tsi", "queue")
+	r.register("flute_sender_fdt_published_total", "FDT-Instances published.", typeCounter, "tsi")
+	r.register("flute_sender_fdt_instance_id", "Last published FDT-Instance ID.", typeGauge, "tsi")
+	r.register("flute_sender_carousel_cycles_total", "Completed carousel cycles over all objects.", typeCounter, "tsi")
+	r.register("flute_sender_object_transfers_started_total", "Object transfers started.", typeCounter, "tsi")
+	r.register("flute_sender_object_transfers_stopped_total", "Object transfers stopped.", typeCounter, "tsi")
+	r.register("flute_sender_object_transfers_active", "Object transfers in progress.", typeGauge, "tsi")
+	// 接收端指标族尚无调用方，见 ReceiverMetrics
+	r.register("flute_receiver_packets_total", "ALC packets received.", typeCounter, "tsi")
+	r.register("flute_receiver_bytes_total", "ALC bytes received.", typeCounter, "tsi")
+	r.register("flute_receiver_packets_lost_total", "ALC packets detected as lost.", typeCounter, "tsi")
+	r.register("flute_receiver_fec_recovered_blocks_total", "Source blocks recovered with FEC repair symbols.", typeCounter, "tsi")
+	r.register("flute_receiver_decode_failures_total", "Objects that failed to decode.", typeCounter, "tsi")
+	return r
+}
+
+func (r *Registry) register(name, help string, typ metricType, labels ...string) {
+	r.families[name] = &family{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		samples: make(map[string]*sample),
+	}
+}
+
+func (r *Registry) add(name string, delta float64, labelValues ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.sample(name, labelValues)
+	s.value += delta
+}
+
+func (r *Registry) set(name string, value float64, labelValues ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.sample(name, labelValues)
+	s.value = value
+}
+
+// sample 调用方持锁
+func (r *Registry) sample(name string, labelValues []string) *sample {
+	f := r.families[name]
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.samples[key]
+	if !ok {
+		s = &sample{labelValues: append([]string(nil), labelValues...)}
+		f.samples[key] = s
+	}
+	return s
+}
+
+// Value 返回某个样本当前值（便于测试/本地展示），不存在返回 0
+func (r *Registry) Value(name string, labelValues ...string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.families[name]
+	if !ok {
+		return 0
+	}
+	if s, ok := f.samples[strings.Join(labelValues, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func tsiLabel(tsi uint64) string {
+	return strconv.FormatUint(tsi, 10)
+}
+
+// ---- SenderMetrics ----
+
+func (r *Registry) PacketSent(tsi uint64, queue string, bytes int) {
+	r.add("flute_sender_packets_total", 1, tsiLabel(tsi), queue)
+	r.add("flute_sender_bytes_total", float64(bytes), tsiLabel(tsi), queue)
+}
+
+func (r *Registry) FDTPublished(tsi uint64, fdtInstanceID uint32) {
+	r.add("flute_sender_fdt_published_total", 1, tsiLabel(tsi))
+	r.set("flute_sender_fdt_instance_id", float64(fdtInstanceID), tsiLabel(tsi))
+}
+
+func (r *Registry) CarouselCycleCompleted(tsi uint64, _ string) {
+	r.add("flute_sender_carousel_cycles_total", 1, tsiLabel(tsi))
+}
+
+func (r *Registry) ObjectTransferStarted(tsi uint64, _ string) {
+	r.add("flute_sender_object_transfers_started_total", 1, tsiLabel(tsi))
+	r.add("flute_sender_object_transfers_active", 1, tsiLabel(tsi))
+}
+
+func (r *Registry) ObjectTransferStopped(tsi uint64, _ string) {
+	r.add("flute_sender_object_transfers_stopped_total", 1, tsiLabel(tsi))
+	r.add("flute_sender_object_transfers_active", -1, tsiLabel(tsi))
+}
+
+// ---- ReceiverMetrics ----
+
+func (r *Registry) PacketReceived(tsi uint64, bytes int) {
+	r.add("flute_receiver_packets_total", 1, tsiLabel(tsi))
+	r.add("flute_receiver_bytes_total", float64(bytes), tsiLabel(tsi))
+}
+
+func (r *Registry) PacketsLost(tsi uint64, count uint64) {
+	r.add("flute_receiver_packets_lost_total", float64(count), tsiLabel(tsi))
+}
+
+func (r *Registry) FECRecovered(tsi uint64, _ string, _ uint32) {
+	r.add("flute_receiver_fec_recovered_blocks_total", 1, tsiLabel(tsi))
+}
+
+func (r *Registry) DecodeFailed(tsi uint64, _ string) {
+	r.add("flute_receiver_decode_failures_total", 1, tsiLabel(tsi))
+}
+
+// ---- 导出 ----
+
+// WriteTo 以 Prometheus 文本格式（version 0.0.4）输出全部指标
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for n := range r.families {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, n := range names {
+		f := r.families[n]
+		fmt.Fprintf(cw, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(cw, "# TYPE %s %s\n", f.name, f.typ)
+
+		keys := make([]string, 0, len(f.samples))
+		for k := range f.samples {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s := f.samples[k]
+			pairs := make([]string, len(f.labels))
+			for i, l := range f.labels {
+				pairs[i] = fmt.Sprintf("%s=%q", l, s.labelValues[i])
+			}
+			fmt.Fprintf(cw, "%s{%s} %s\n", f.name, strings.Join(pairs, ","),
+				strconv.FormatFloat(s.value, 'g', -1, 64))
+		}
+	}
+	if err := cw.w.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP 实现 /metrics
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+// Serve 在 addr 上启动 HTTP 导出（路径 /metrics），返回的 Server 由调用方关闭
+func Serve(addr string, r *Registry) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(ln) }()
+	return srv, nil
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
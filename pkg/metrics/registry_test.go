@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	r := NewRegistry()
+	r.PacketSent(1, QueueFDT, 100)
+	r.PacketSent(1, "0", 1000)
+	r.PacketSent(1, "0", 500)
+	r.ObjectTransferStarted(1, "1")
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE flute_sender_bytes_total counter\n",
+		`flute_sender_bytes_total{tsi="1",queue="0"} 1500` + "\n",
+		`flute_sender_packets_total{tsi="1",queue="fdt"} 1` + "\n",
+		`flute_sender_object_transfers_active{tsi="1"} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in exposition:\n%s", want, out)
+		}
+	}
+}
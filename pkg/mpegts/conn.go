@@ -0,0 +1,51 @@
+package mpegts
+
+import (
+	"Flute_go/pkg/pcap"
+	"errors"
+	"net"
+	"net/netip"
+)
+
+// Conn 只写的 transport.PacketConn：每个 UDP 载荷加上合成的 IP/UDP 头后封装进 TS，
+// 可替代 UDP 套接字放在 Sender 的发送循环中；打包模式下空闲时调用 Flush
+type Conn struct {
+	enc  *Encapsulator
+	src  netip.AddrPort
+	dst  netip.AddrPort
+	ipID uint16
+}
+
+// NewConn src/dst 为合成头部使用的地址，必须同为 IPv4 或 IPv6
+func NewConn(enc *Encapsulator, src, dst netip.AddrPort) (*Conn, error) {
+	if src.Addr().Is4() != dst.Addr().Is4() {
+		return nil, errors.New("mpegts: source and destination address families differ")
+	}
+	return &Conn{enc: enc, src: src, dst: dst}, nil
+}
+
+// WriteTo addr 被忽略，目的地址固定为 NewConn 的 dst
+func (c *Conn) WriteTo(p []byte, _ net.Addr) (int, error) {
+	ip, err := pcap.BuildIPUDP(c.src, c.dst, p, c.ipID)
+	if err != nil {
+		return 0, err
+	}
+	c.ipID++
+	if err := c.enc.WriteDatagram(ip); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ReadFrom TS 输出是单向的
+func (c *Conn) ReadFrom([]byte) (int, net.Addr, error) {
+	return 0, nil, errors.New("mpegts: Conn is write-only")
+}
+
+// Flush 写出未填满的 TS 包
+func (c *Conn) Flush() error { return c.enc.Flush() }
+
+// Close 写出未填满的 TS 包；底层 io.Writer 由调用方关闭
+func (c *Conn) Close() error { return c.enc.Flush() }
+
+func (c *Conn) LocalAddr() net.Addr { return net.UDPAddrFromAddrPort(c.src) }
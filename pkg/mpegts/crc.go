@@ -0,0 +1,25 @@
+package mpegts
+
+// crcTable MPEG-2 CRC-32（多项式 0x04C11DB7，初值 0xFFFFFFFF，不反射，不取反），MPE 段与 ULE SNDU 共用
+var crcTable = func() (t [256]uint32) {
+	for i := range t {
+		c := uint32(i) << 24
+		for range 8 {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04C11DB7
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return t
+}()
+
+func crc32MPEG2(b []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, v := range b {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^v]
+	}
+	return crc
+}
@@ -0,0 +1,268 @@
+package mpegts
+
+import (
+	"Flute_go/pkg/pcap"
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Stats 解封装统计
+type Stats struct {
+	TSPackets uint64 // 读到的 TS 包（所有 PID）
+	Resyncs   uint64 // 丢失同步字节后重新对齐的次数
+	CCErrors  uint64 // 连续计数器不连续，丢弃未完成的段/SNDU
+	CRCErrors uint64
+	Units     uint64 // 完整的段/SNDU
+	Datagrams uint64 // 交付的 UDP 数据报
+	Skipped   uint64 // 非 IP、非 UDP、IP 分片或不支持的扩展头
+	Malformed uint64 // 长度或指针字段非法
+}
+
+// Reader 从 TS 中取出某个 PID 上 MPE/ULE 封装的 UDP 数据报，实现 pcap.Source，
+// 可交给 pcap.Replay/ReplayTo 送入接收端；TS 不带抓包时间戳，Packet.Timestamp 为零值
+type Reader struct {
+	r    *bufio.Reader
+	pid  uint16
+	mode Mode
+
+	cc     int // 上一个包的连续计数器，-1 = 未知
+	active bool
+	unit   []byte
+	total  int // 当前单元总长，0 = 头部尚未收齐
+
+	queue []*pcap.Packet
+	stats Stats
+}
+
+// NewReader pid 与 mode 须与封装端一致
+func NewReader(r io.Reader, pid uint16, mode Mode) (*Reader, error) {
+	cfg := Config{PID: pid, Mode: mode}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &Reader{r: bufio.NewReaderSize(r, 64*PacketSize), pid: pid, mode: mode, cc: -1}, nil
+}
+
+// Stats 当前统计
+func (d *Reader) Stats() Stats { return d.stats }
+
+// Next 返回下一个 UDP 数据报，TS 结束时返回 io.EOF（末尾不完整的单元被丢弃）
+func (d *Reader) Next() (*pcap.Packet, error) {
+	var pkt [PacketSize]byte
+	for len(d.queue) == 0 {
+		if err := d.readPacket(pkt[:]); err != nil {
+			return nil, err
+		}
+		d.stats.TSPackets++
+		d.handle(pkt[:])
+	}
+	p := d.queue[0]
+	d.queue = d.queue[1:]
+	return p, nil
+}
+
+// readPacket 读一个 TS 包；同步字节不对时逐字节前移重新对齐
+func (d *Reader) readPacket(pkt []byte) error {
+	for {
+		b, err := d.r.Peek(1)
+		if err != nil {
+			return err
+		}
+		if b[0] == syncByte {
+			break
+		}
+		d.r.Discard(1)
+		d.stats.Resyncs++
+	}
+	if _, err := io.ReadFull(d.r, pkt); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return io.EOF
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *Reader) handle(pkt []byte) {
+	if pkt[1]&0x80 != 0 { // transport_error_indicator
+		d.drop()
+		return
+	}
+	if binary.BigEndian.Uint16(pkt[1:])&0x1FFF != d.pid {
+		return
+	}
+	pusi := pkt[1]&0x40 != 0
+	afc := pkt[3] >> 4 & 0x3
+	cc := int(pkt[3] & 0x0F)
+	if afc&0x1 == 0 {
+		return // 没有载荷，计数器不变
+	}
+	payload := pkt[4:]
+	if afc == 0x3 {
+		afl := int(pkt[4])
+		if 1+afl > len(payload) {
+			d.stats.Malformed++
+			d.drop()
+			return
+		}
+		payload = payload[1+afl:]
+	}
+	switch {
+	case d.cc < 0 || cc == (d.cc+1)&0x0F:
+	case cc == d.cc:
+		return // 重复包
+	default:
+		d.stats.CCErrors++
+		d.drop()
+	}
+	d.cc = cc
+
+	if !pusi {
+		if d.active {
+			d.feed(payload) // 单元结束后的剩余字节是填充
+		}
+		return
+	}
+	if len(payload) == 0 {
+		return
+	}
+	ptr := int(payload[0])
+	payload = payload[1:]
+	if ptr > len(payload) {
+		d.stats.Malformed++
+		d.drop()
+		return
+	}
+	if d.active {
+		d.feed(payload[:ptr])
+		if d.active {
+			// pointer_field 指向的位置之前应恰好结束上一个单元
+			d.stats.Malformed++
+			d.drop()
+		}
+	}
+	payload = payload[ptr:]
+	for len(payload) > 0 && !d.stuffing(payload) {
+		d.active, d.unit, d.total = true, d.unit[:0], 0
+		payload = d.feed(payload)
+		if d.active {
+			break // 延续到下一个 TS 包
+		}
+	}
+}
+
+func (d *Reader) drop() {
+	d.active, d.unit, d.total = false, d.unit[:0], 0
+}
+
+// stuffing MPE：table_id 0xFF 表示包内其余为填充；ULE：不足 2 字节或 End Indicator 0xFFFF
+func (d *Reader) stuffing(b []byte) bool {
+	if d.mode == MPE {
+		return b[0] == 0xFF
+	}
+	return len(b) < 2 || b[0] == 0xFF && b[1] == 0xFF
+}
+
+// feed 追加当前单元的字节，单元完整时处理并返回剩余字节
+func (d *Reader) feed(b []byte) []byte {
+	hdr := 3
+	if d.mode == ULE {
+		hdr = 2
+	}
+	if d.total == 0 {
+		need := min(hdr-len(d.unit), len(b))
+		d.unit = append(d.unit, b[:need]...)
+		b = b[need:]
+		if len(d.unit) < hdr {
+			return b
+		}
+		if d.mode == MPE {
+			d.total = 3 + int(binary.BigEndian.Uint16(d.unit[1:])&0x0FFF)
+		} else {
+			d.total = 4 + int(binary.BigEndian.Uint16(d.unit)&0x7FFF)
+		}
+	}
+	need := min(d.total-len(d.unit), len(b))
+	d.unit = append(d.unit, b[:need]...)
+	b = b[need:]
+	if len(d.unit) == d.total {
+		d.stats.Units++
+		var ip []byte
+		var err error
+		if d.mode == MPE {
+			ip, err = mpeDatagram(d.unit)
+		} else {
+			ip, err = uleDatagram(d.unit)
+		}
+		switch {
+		case errors.Is(err, errCRC):
+			d.stats.CRCErrors++
+		case err != nil || !d.deliver(ip):
+			d.stats.Skipped++
+		}
+		d.drop()
+	}
+	return b
+}
+
+var (
+	errCRC  = errors.New("mpegts: CRC mismatch")
+	errSkip = errors.New("mpegts: unsupported unit")
+)
+
+// mpeDatagram 从 datagram_section 取出 IP 数据报
+func mpeDatagram(s []byte) ([]byte, error) {
+	if s[0] != mpeTableID || len(s) < 12+4 {
+		return nil, errSkip
+	}
+	if s[1]&0x80 != 0 && crc32MPEG2(s) != 0 {
+		return nil, errCRC
+	}
+	if s[5]&0x3C != 0 {
+		return nil, errSkip // 加扰
+	}
+	ip := s[12 : len(s)-4]
+	if s[5]&0x02 != 0 {
+		// LLC/SNAP：AA AA 03 + OUI + EtherType
+		if len(ip) < 8 || ip[0] != 0xAA || ip[1] != 0xAA || ip[2] != 0x03 {
+			return nil, errSkip
+		}
+		ip = ip[8:]
+	}
+	return ip, nil
+}
+
+// uleDatagram 从 SNDU 取出 IP 数据报；Type < 1536 的扩展头不支持
+func uleDatagram(s []byte) ([]byte, error) {
+	if len(s) < 8 {
+		return nil, errSkip
+	}
+	if crc32MPEG2(s) != 0 {
+		return nil, errCRC
+	}
+	body := s[4 : len(s)-4]
+	if s[0]&0x80 == 0 {
+		if len(body) < 6 {
+			return nil, errSkip
+		}
+		body = body[6:]
+	}
+	switch binary.BigEndian.Uint16(s[2:]) {
+	case uleTypeIPv4, uleTypeIPv6:
+		return body, nil
+	}
+	return nil, errSkip
+}
+
+// deliver 非 UDP 或 IP 分片返回 false
+func (d *Reader) deliver(ip []byte) bool {
+	src, dst, payload, err := pcap.ParseIPUDP(ip)
+	if err != nil {
+		return false
+	}
+	d.stats.Datagrams++
+	d.queue = append(d.queue, &pcap.Packet{Src: src, Dst: dst, Payload: append([]byte(nil), payload...)})
+	return true
+}
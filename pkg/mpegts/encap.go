@@ -0,0 +1,279 @@
+// Package mpegts 把 IP 数据报封装进 MPEG-2 TS（DVB MPE 段或 ULE/RFC 4326），以及对应的解封装，
+// 用于只接受 TS 的卫星播出链路
+// 只生成数据 PID 上的 TS 包，PAT/PMT/INT 等 PSI/SI 由播出复用器提供
+package mpegts
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+const (
+	PacketSize = 188
+	syncByte   = 0x47
+
+	// PID 0x0000–0x000F 保留给 PSI，0x1FFF 为空包
+	MinPID = 0x0010
+	MaxPID = 0x1FFE
+
+	mpeTableID = 0x3E
+	// MPE 段长度字段 12 位，最大 4093；扣除 9 字节头部与 4 字节 CRC
+	maxMPEDatagram = 4093 - 9 - 4
+	// ULE Length 字段 15 位，计入 NPA 与 CRC；D=1 时 0x7FFF 会与 End Indicator（0xFFFF）冲突
+	maxULELength = 0x7FFE
+
+	uleTypeIPv4 = 0x0800
+	uleTypeIPv6 = 0x86DD
+)
+
+// Mode 封装方式
+type Mode uint8
+
+const (
+	// MPE DVB 多协议封装（EN 301 192 datagram_section，table_id 0x3E）
+	MPE Mode = iota
+	// ULE 单向轻量封装（RFC 4326）
+	ULE
+)
+
+func (m Mode) String() string {
+	switch m {
+	case MPE:
+		return "mpe"
+	case ULE:
+		return "ule"
+	default:
+		return fmt.Sprintf("Mode(%d)", uint8(m))
+	}
+}
+
+// ParseMode "mpe" 或 "ule"（不区分大小写）
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(s) {
+	case "mpe":
+		return MPE, nil
+	case "ule":
+		return ULE, nil
+	}
+	return 0, fmt.Errorf("unknown TS encapsulation %q (want mpe or ule)", s)
+}
+
+// Config 封装参数
+type Config struct {
+	PID  uint16
+	Mode Mode
+	// 打包：下一个段/SNDU 紧接在上一个之后开始，而不是总从新的 TS 包开始
+	Packing bool
+	// ULE：携带 6 字节目的 NPA 地址（D=0）；MPE 总是携带 MAC 地址
+	NPA bool
+	// 单播目的的 MAC/NPA 地址；组播地址按 RFC 1112/2464 映射，nil = 全 0
+	MAC net.HardwareAddr
+}
+
+func (c *Config) validate() error {
+	if c.PID < MinPID || c.PID > MaxPID {
+		return fmt.Errorf("mpegts: PID %#x outside %#x–%#x", c.PID, MinPID, MaxPID)
+	}
+	if c.Mode != MPE && c.Mode != ULE {
+		return fmt.Errorf("mpegts: unknown mode %v", c.Mode)
+	}
+	if c.MAC != nil && len(c.MAC) != 6 {
+		return errors.New("mpegts: MAC address must be 6 bytes")
+	}
+	return nil
+}
+
+// Encapsulator 把 IP 数据报写成 188 字节的 TS 包；非并发安全
+type Encapsulator struct {
+	w   io.Writer
+	cfg Config
+	cc  uint8
+
+	pkt  [PacketSize]byte
+	n    int  // pkt 中已用的字节数（含 4 字节头）
+	open bool // pkt 正在填充
+	pusi bool // pkt 中已有段/SNDU 起始（有 pointer_field）
+
+	packets   uint64
+	datagrams uint64
+}
+
+// NewEncapsulator w 可以是 .ts 文件、管道或标准输出
+func NewEncapsulator(w io.Writer, cfg Config) (*Encapsulator, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &Encapsulator{w: w, cfg: cfg}, nil
+}
+
+// WriteDatagram 封装一个完整的 IPv4/IPv6 数据报
+func (e *Encapsulator) WriteDatagram(ip []byte) error {
+	var unit []byte
+	var err error
+	if e.cfg.Mode == MPE {
+		unit, err = e.mpeSection(ip)
+	} else {
+		unit, err = e.uleSNDU(ip)
+	}
+	if err != nil {
+		return err
+	}
+	if err := e.writeUnit(unit); err != nil {
+		return err
+	}
+	e.datagrams++
+	if !e.cfg.Packing {
+		return e.Flush()
+	}
+	return nil
+}
+
+// Flush 用 0xFF 填满当前 TS 包并写出；打包模式下应在空闲时调用，以免最后一个数据报滞留
+func (e *Encapsulator) Flush() error {
+	if !e.open {
+		return nil
+	}
+	for i := e.n; i < PacketSize; i++ {
+		e.pkt[i] = 0xFF
+	}
+	e.open = false
+	e.packets++
+	_, err := e.w.Write(e.pkt[:])
+	return err
+}
+
+// Packets 已写出的 TS 包数
+func (e *Encapsulator) Packets() uint64 { return e.packets }
+
+// Datagrams 已封装的数据报数
+func (e *Encapsulator) Datagrams() uint64 { return e.datagrams }
+
+// startPacket 开始一个新的 TS 包；withPointer 时设置 PUSI 并写 pointer_field = 0
+func (e *Encapsulator) startPacket(withPointer bool) {
+	e.pkt[0] = syncByte
+	e.pkt[1] = byte(e.cfg.PID >> 8 & 0x1F)
+	e.pkt[2] = byte(e.cfg.PID)
+	e.pkt[3] = 0x10 | e.cc // 仅载荷
+	e.cc = (e.cc + 1) & 0x0F
+	e.n = 4
+	e.open = true
+	e.pusi = false
+	if withPointer {
+		e.pkt[1] |= 0x40
+		e.pkt[4] = 0
+		e.n = 5
+		e.pusi = true
+	}
+}
+
+// writeUnit 写入一个完整的段/SNDU，必要时跨多个 TS 包
+func (e *Encapsulator) writeUnit(unit []byte) error {
+	// 段/SNDU 至少要有 2 字节落在起始包中（长度字段不能只剩 1 字节）
+	const minStart = 2
+	switch {
+	case !e.open:
+		e.startPacket(true)
+	case e.pusi && PacketSize-e.n >= minStart:
+		// 包内已有 pointer_field，指向更早的起始位置
+	case !e.pusi && PacketSize-e.n >= 1+minStart:
+		// 包以上一个单元的续传字节开头：插入 pointer_field 指向新单元
+		cont := e.n - 4
+		copy(e.pkt[5:e.n+1], e.pkt[4:e.n])
+		e.pkt[1] |= 0x40
+		e.pkt[4] = byte(cont)
+		e.n++
+		e.pusi = true
+	default:
+		if err := e.Flush(); err != nil {
+			return err
+		}
+		e.startPacket(true)
+	}
+	for len(unit) > 0 {
+		if e.n == PacketSize {
+			if err := e.Flush(); err != nil {
+				return err
+			}
+			e.startPacket(false)
+		}
+		c := copy(e.pkt[e.n:], unit)
+		e.n += c
+		unit = unit[c:]
+	}
+	if e.n == PacketSize {
+		return e.Flush()
+	}
+	return nil
+}
+
+// destMAC 组播映射为 01:00:5e（IPv4）或 33:33（IPv6），其余使用配置的 MAC
+func (e *Encapsulator) destMAC(ip []byte) [6]byte {
+	var mac [6]byte
+	switch {
+	case len(ip) >= 20 && ip[0]>>4 == 4 && ip[16]&0xF0 == 0xE0:
+		mac = [6]byte{0x01, 0x00, 0x5E, ip[17] & 0x7F, ip[18], ip[19]}
+	case len(ip) >= 40 && ip[0]>>4 == 6 && ip[24] == 0xFF:
+		mac = [6]byte{0x33, 0x33, ip[36], ip[37], ip[38], ip[39]}
+	default:
+		copy(mac[:], e.cfg.MAC)
+	}
+	return mac
+}
+
+// mpeSection datagram_section：MAC_address_6/5 在前，MAC_address_4..1 在后（1 为最高字节），末尾 CRC_32
+func (e *Encapsulator) mpeSection(ip []byte) ([]byte, error) {
+	if len(ip) > maxMPEDatagram {
+		return nil, fmt.Errorf("mpegts: datagram of %d bytes exceeds MPE section limit %d", len(ip), maxMPEDatagram)
+	}
+	mac := e.destMAC(ip)
+	sectionLength := 9 + len(ip) + 4
+	s := make([]byte, 0, 3+sectionLength)
+	s = append(s,
+		mpeTableID,
+		0xB0|byte(sectionLength>>8), // section_syntax_indicator=1, private_indicator=0, reserved=11
+		byte(sectionLength),
+		mac[5], mac[4],
+		0xC1, // reserved=11, 不加扰, LLC_SNAP_flag=0, current_next_indicator=1
+		0, 0, // section_number, last_section_number
+		mac[3], mac[2], mac[1], mac[0],
+	)
+	s = append(s, ip...)
+	return binary.BigEndian.AppendUint32(s, crc32MPEG2(s)), nil
+}
+
+// uleSNDU D|Length、Type、可选 NPA、PDU、CRC-32（覆盖从 D 位到 PDU 末尾）
+func (e *Encapsulator) uleSNDU(ip []byte) ([]byte, error) {
+	var typ uint16
+	switch {
+	case len(ip) > 0 && ip[0]>>4 == 4:
+		typ = uleTypeIPv4
+	case len(ip) > 0 && ip[0]>>4 == 6:
+		typ = uleTypeIPv6
+	default:
+		return nil, errors.New("mpegts: not an IPv4/IPv6 datagram")
+	}
+	length := len(ip) + 4
+	if e.cfg.NPA {
+		length += 6
+	}
+	if length > maxULELength {
+		return nil, fmt.Errorf("mpegts: datagram of %d bytes exceeds ULE SNDU limit", len(ip))
+	}
+	d := uint16(0x8000)
+	if e.cfg.NPA {
+		d = 0
+	}
+	s := make([]byte, 0, 4+length)
+	s = binary.BigEndian.AppendUint16(s, d|uint16(length))
+	s = binary.BigEndian.AppendUint16(s, typ)
+	if e.cfg.NPA {
+		mac := e.destMAC(ip)
+		s = append(s, mac[:]...)
+	}
+	s = append(s, ip...)
+	return binary.BigEndian.AppendUint32(s, crc32MPEG2(s)), nil
+}
@@ -0,0 +1,165 @@
+package mpegts
+
+import (
+	"Flute_go/pkg/pcap"
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+func TestCRC32MPEG2(t *testing.T) {
+	if got := crc32MPEG2([]byte("123456789")); got != 0x0376E6E7 {
+		t.Fatalf("crc = %#x, want 0x0376e6e7", got)
+	}
+}
+
+// payloads 覆盖单元恰好填满 TS 包、只剩 1 字节等边界的载荷长度
+func payloads() [][]byte {
+	rng := rand.New(rand.NewSource(1))
+	var out [][]byte
+	for _, n := range []int{0, 1, 100, 139, 140, 141, 150, 155, 156, 157, 183, 184, 1400, 1472, 3000} {
+		p := make([]byte, n)
+		rng.Read(p)
+		out = append(out, p)
+	}
+	return out
+}
+
+func readAll(t *testing.T, ts []byte, pid uint16, mode Mode) ([]*pcap.Packet, Stats) {
+	t.Helper()
+	r, err := NewReader(bytes.NewReader(ts), pid, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkts []*pcap.Packet
+	for {
+		p, err := r.Next()
+		if err == io.EOF {
+			return pkts, r.Stats()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkts = append(pkts, p)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, mode := range []Mode{MPE, ULE} {
+		for _, packing := range []bool{false, true} {
+			for _, dst := range []string{"239.1.2.3:3400", "[ff0e::1]:3400"} {
+				name := fmt.Sprintf("%v/packing=%v/%s", mode, packing, dst)
+				t.Run(name, func(t *testing.T) {
+					d := netip.MustParseAddrPort(dst)
+					s := netip.AddrPortFrom(netip.IPv4Unspecified(), 5000)
+					if d.Addr().Is6() {
+						s = netip.AddrPortFrom(netip.IPv6Unspecified(), 5000)
+					}
+					var ts bytes.Buffer
+					enc, err := NewEncapsulator(&ts, Config{PID: 0x100, Mode: mode, Packing: packing, NPA: true})
+					if err != nil {
+						t.Fatal(err)
+					}
+					conn, err := NewConn(enc, s, d)
+					if err != nil {
+						t.Fatal(err)
+					}
+					sent := payloads()
+					for _, p := range sent {
+						if _, err := conn.WriteTo(p, nil); err != nil {
+							t.Fatal(err)
+						}
+					}
+					conn.Close()
+					if ts.Len()%PacketSize != 0 || uint64(ts.Len()/PacketSize) != enc.Packets() {
+						t.Fatalf("TS length %d not a whole number of packets (%d)", ts.Len(), enc.Packets())
+					}
+
+					got, st := readAll(t, ts.Bytes(), 0x100, mode)
+					if len(got) != len(sent) || st.CCErrors != 0 || st.CRCErrors != 0 || st.Malformed != 0 {
+						t.Fatalf("got %d datagrams, want %d; stats %+v", len(got), len(sent), st)
+					}
+					for i, p := range got {
+						if !bytes.Equal(p.Payload, sent[i]) || p.Dst != d || p.Src != s {
+							t.Fatalf("datagram %d differs (%v -> %v, %d bytes)", i, p.Src, p.Dst, len(p.Payload))
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// 打包模式明显减少 TS 包数
+func TestPackingSavesPackets(t *testing.T) {
+	count := func(packing bool) uint64 {
+		enc, _ := NewEncapsulator(io.Discard, Config{PID: 0x100, Mode: ULE, Packing: packing})
+		conn, _ := NewConn(enc, netip.MustParseAddrPort("10.0.0.1:1"), netip.MustParseAddrPort("239.0.0.1:2"))
+		for range 100 {
+			conn.WriteTo(make([]byte, 40), nil)
+		}
+		conn.Close()
+		return enc.Packets()
+	}
+	if loose, packed := count(false), count(true); packed > loose/2 {
+		t.Fatalf("packing used %d packets, without packing %d", packed, loose)
+	}
+}
+
+// 丢一个 TS 包只影响跨过它的数据报；其他 PID 与错位的字节被忽略
+func TestLossAndResync(t *testing.T) {
+	for _, mode := range []Mode{MPE, ULE} {
+		t.Run(mode.String(), func(t *testing.T) {
+			var ts bytes.Buffer
+			enc, _ := NewEncapsulator(&ts, Config{PID: 0x200, Mode: mode, Packing: true})
+			conn, _ := NewConn(enc, netip.MustParseAddrPort("10.0.0.1:1"), netip.MustParseAddrPort("239.0.0.1:2"))
+			for i := range 20 {
+				conn.WriteTo(bytes.Repeat([]byte{byte(i)}, 300), nil)
+			}
+			conn.Close()
+			raw := ts.Bytes()
+
+			var damaged bytes.Buffer
+			other := make([]byte, PacketSize)
+			other[0], other[1], other[2], other[3] = syncByte, 0x00, 0x11, 0x10
+			for i := 0; i < len(raw); i += PacketSize {
+				switch i / PacketSize {
+				case 10:
+					continue // 丢包
+				case 3:
+					damaged.Write([]byte{0x00, 0x12}) // 错位
+				case 5:
+					damaged.Write(other)
+				}
+				damaged.Write(raw[i : i+PacketSize])
+			}
+
+			got, st := readAll(t, damaged.Bytes(), 0x200, mode)
+			if st.CCErrors != 1 || st.Resyncs != 2 {
+				t.Fatalf("stats %+v", st)
+			}
+			if len(got) < 17 || len(got) >= 20 {
+				t.Fatalf("recovered %d of 20 datagrams", len(got))
+			}
+			for _, p := range got {
+				if len(p.Payload) != 300 || !bytes.Equal(p.Payload, bytes.Repeat(p.Payload[:1], 300)) {
+					t.Fatalf("corrupt datagram delivered")
+				}
+			}
+		})
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	for _, cfg := range []Config{{PID: 0x0001}, {PID: 0x1FFF}, {PID: 0x100, Mode: 7}, {PID: 0x100, MAC: []byte{1}}} {
+		if _, err := NewEncapsulator(io.Discard, cfg); err == nil {
+			t.Errorf("config %+v accepted", cfg)
+		}
+	}
+	if m, err := ParseMode("ULE"); err != nil || m != ULE {
+		t.Fatalf("ParseMode(ULE) = %v, %v", m, err)
+	}
+}
@@ -0,0 +1,229 @@
+package object
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// EncryptionScheme 对象内容加密方案（FDT File 的 Content-Encryption 属性）
+type EncryptionScheme int
+
+const (
+	EncryptionNone EncryptionScheme = iota
+	EncryptionAESGCM
+	EncryptionAESCTR
+)
+
+func (e EncryptionScheme) String() string {
+	switch e {
+	case EncryptionNone:
+		return ""
+	case EncryptionAESGCM:
+		return "AES-GCM"
+	case EncryptionAESCTR:
+		return "AES-CTR"
+	default:
+		return "Unknown"
+	}
+}
+
+// ParseEncryptionScheme 解析 Content-Encryption 属性
+func ParseEncryptionScheme(s string) (EncryptionScheme, error) {
+	switch s {
+	case "":
+		return EncryptionNone, nil
+	case "AES-GCM":
+		return EncryptionAESGCM, nil
+	case "AES-CTR":
+		return EncryptionAESCTR, nil
+	default:
+		return EncryptionNone, fmt.Errorf("unsupported content encryption %q", s)
+	}
+}
+
+// IVLength 返回方案要求的 IV 长度（GCM 为 12 字节 nonce，CTR 为 16 字节初始计数器）
+func (e EncryptionScheme) IVLength() int {
+	switch e {
+	case EncryptionAESGCM:
+		return 12
+	case EncryptionAESCTR:
+		return aes.BlockSize
+	default:
+		return 0
+	}
+}
+
+// Overhead 返回加密后长度的增量（GCM 认证标签）
+func (e EncryptionScheme) Overhead() int {
+	if e == EncryptionAESGCM {
+		return 16
+	}
+	return 0
+}
+
+// KeyProvider 按 Key-ID 查找内容密钥（带外分发，如 DRM/CAS 系统）
+type KeyProvider interface {
+	ContentKey(keyID string) ([]byte, error)
+}
+
+// StaticKeyProvider 固定的 Key-ID -> 密钥表
+type StaticKeyProvider map[string][]byte
+
+func (p StaticKeyProvider) ContentKey(keyID string) ([]byte, error) {
+	k, ok := p[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown content key %q", keyID)
+	}
+	return k, nil
+}
+
+// NewIV 为方案生成随机 IV
+func NewIV(scheme EncryptionScheme) ([]byte, error) {
+	iv := make([]byte, scheme.IVLength())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	return iv, nil
+}
+
+// EncryptContent 加密传输表示（已完成 Content-Encoding 的数据）
+func EncryptContent(scheme EncryptionScheme, key, iv, plain []byte) ([]byte, error) {
+	return cryptContent(scheme, key, iv, plain, true)
+}
+
+// DecryptContent 解密传输表示，GCM 认证失败返回错误
+func DecryptContent(scheme EncryptionScheme, key, iv, data []byte) ([]byte, error) {
+	return cryptContent(scheme, key, iv, data, false)
+}
+
+func cryptContent(scheme EncryptionScheme, key, iv, data []byte, encrypt bool) ([]byte, error) {
+	if len(iv) != scheme.IVLength() {
+		return nil, fmt.Errorf("wrong IV length %d for %s", len(iv), scheme)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	switch scheme {
+	case EncryptionAESGCM:
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		if encrypt {
+			return gcm.Seal(nil, iv, data, nil), nil
+		}
+		return gcm.Open(nil, iv, data, nil)
+	case EncryptionAESCTR:
+		out := make([]byte, len(data))
+		cipher.NewCTR(block, iv).XORKeyStream(out, data)
+		return out, nil
+	default:
+		return nil, errors.New("no content encryption")
+	}
+}
+
+// CTRReadSeeker 以 AES-CTR 对底层流做随机访问加/解密，适用于不缓存到内存的大文件
+type CTRReadSeeker struct {
+	block cipher.Block
+	iv    []byte
+	r     io.ReadSeeker
+	pos   int64
+}
+
+func NewCTRReadSeeker(key, iv []byte, r io.ReadSeeker) (*CTRReadSeeker, error) {
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("wrong IV length %d for AES-CTR", len(iv))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	return &CTRReadSeeker{block: block, iv: append([]byte(nil), iv...), r: r, pos: pos}, nil
+}
+
+func (c *CTRReadSeeker) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.xor(p[:n], c.pos)
+		c.pos += int64(n)
+	}
+	return n, err
+}
+
+func (c *CTRReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := c.r.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	c.pos = pos
+	return pos, nil
+}
+
+// xor 从偏移 off 开始的密钥流：计数器 = IV + off/16，再跳过 off%16 字节
+func (c *CTRReadSeeker) xor(p []byte, off int64) {
+	ctr := append([]byte(nil), c.iv...)
+	add := uint64(off / aes.BlockSize)
+	for i := len(ctr) - 1; i >= 0 && add > 0; i-- {
+		sum := uint64(ctr[i]) + (add & 0xFF)
+		ctr[i] = byte(sum)
+		add = (add >> 8) + (sum >> 8)
+	}
+	stream := cipher.NewCTR(c.block, ctr)
+	skip := int(off % aes.BlockSize)
+	if skip > 0 {
+		var tmp [aes.BlockSize]byte
+		stream.XORKeyStream(tmp[:skip], tmp[:skip])
+	}
+	stream.XORKeyStream(p, p)
+}
+
+// GetEncryption 解析 FDT File 的加密属性
+func (f *FdtFile) GetEncryption() (EncryptionScheme, string, []byte, error) {
+	if f.ContentEncryption == nil {
+		return EncryptionNone, "", nil, nil
+	}
+	scheme, err := ParseEncryptionScheme(*f.ContentEncryption)
+	if err != nil {
+		return EncryptionNone, "", nil, err
+	}
+	if scheme == EncryptionNone {
+		return EncryptionNone, "", nil, nil
+	}
+	if f.ContentKeyID == nil || f.ContentIV == nil {
+		return EncryptionNone, "", nil, errors.New("encrypted file without Content-Key-ID or Content-IV")
+	}
+	iv, err := base64.StdEncoding.DecodeString(*f.ContentIV)
+	if err != nil {
+		return EncryptionNone, "", nil, err
+	}
+	if len(iv) != scheme.IVLength() {
+		return EncryptionNone, "", nil, fmt.Errorf("wrong IV length %d for %s", len(iv), scheme)
+	}
+	return scheme, *f.ContentKeyID, iv, nil
+}
+
+// DecryptFile 接收端在交给 ObjectWriter 之前，用 KeyProvider 解密对象的传输表示
+func (f *FdtFile) DecryptFile(data []byte, provider KeyProvider) ([]byte, error) {
+	scheme, keyID, iv, err := f.GetEncryption()
+	if err != nil || scheme == EncryptionNone {
+		return data, err
+	}
+	if provider == nil {
+		return nil, errors.New("encrypted file but no key provider")
+	}
+	key, err := provider.ContentKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptContent(scheme, key, iv, data)
+}
@@ -0,0 +1,40 @@
+package object
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCTRReadSeekerMatchesBufferEncryption(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 16)
+	iv := bytes.Repeat([]byte{0xFF}, 16) // 计数器进位
+	plain := make([]byte, 1000)
+	for i := range plain {
+		plain[i] = byte(i)
+	}
+	want, err := EncryptContent(EncryptionAESCTR, key, iv, plain)
+	if err != nil {
+		t.Fatalf("EncryptContent failed: %v", err)
+	}
+
+	rs, err := NewCTRReadSeeker(key, iv, bytes.NewReader(plain))
+	if err != nil {
+		t.Fatalf("NewCTRReadSeeker failed: %v", err)
+	}
+	if _, err := rs.Seek(37, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	got, err := io.ReadAll(rs)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(got, want[37:]) {
+		t.Fatalf("CTR stream differs from buffer encryption")
+	}
+
+	back, err := DecryptContent(EncryptionAESCTR, key, iv, want)
+	if err != nil || !bytes.Equal(back, plain) {
+		t.Fatalf("DecryptContent round trip failed: %v", err)
+	}
+}
@@ -52,12 +52,19 @@ type FdtInstance struct {
 	BaseURL1      []string `xml:"mbms2012:Base-URL-1,omitempty"`
 	BaseURL2      []string `xml:"mbms2012:Base-URL-2,omitempty"`
 	Group         []string `xml:"mbms2005:Group,omitempty"`
+
+	// 已验证的嵌入签名（见 SignFdtInstance），由 ParseSignedFdtInstance 填充
+	Signature *FdtSignature `xml:"Signature,omitempty"`
 }
 
 // 单个文件项
 type FdtFile struct {
 	// 子元素
 	CacheControl *CacheControl `xml:"mbms2007:Cache-Control"`
+	// W3C Trace Context（traceparent/tracestate 等），见 TraceContext
+	OptelPropagator []OptelPropagatorEntry `xml:"Optel-Propagator,omitempty"`
+	// 当前及下一个计划传输窗口（见 sender.Scheduler）
+	TransferWindows []FdtTransferWindow `xml:"Transfer-Window,omitempty"`
 
 	// 标识
 	ContentLocation string  `xml:"Content-Location,attr"`
@@ -70,6 +77,15 @@ type FdtFile struct {
 	ContentEncoding *string `xml:"Content-Encoding,attr,omitempty"`
 	ContentMD5      *string `xml:"Content-MD5,attr,omitempty"`
 
+	// 版本：同一 Content-Location 更新时递增，接收端据此淘汰旧副本（见 Supersedes）
+	ContentVersion *uint32 `xml:"Content-Version,attr,omitempty"`
+	ETag           *string `xml:"ETag,attr,omitempty"`
+
+	// 内容加密（密钥带外分发，见 KeyProvider）
+	ContentEncryption *string `xml:"Content-Encryption,attr,omitempty"`
+	ContentKeyID      *string `xml:"Content-Key-ID,attr,omitempty"`
+	ContentIV         *string `xml:"Content-IV,attr,omitempty"` // Base64
+
 	// 文件级 FEC OTI
 	FECEncID      *uint8  `xml:"FEC-OTI-FEC-Encoding-ID,attr,omitempty"`
 	FECInstanceID *uint64 `xml:"FEC-OTI-FEC-Instance-ID,attr,omitempty"`
@@ -471,3 +487,65 @@ func parseKVByte(s string, keys []string) uint8 {
 	}
 	return 0
 }
+
+// Supersedes 判断 f 是否是 old 的新版本：Content-Location 相同，
+// 且 Content-Version 更大，或版本缺失时 ETag 不同
+func (f *FdtFile) Supersedes(old *FdtFile) bool {
+	if old == nil || f.ContentLocation != old.ContentLocation || f.TOI == old.TOI {
+		return false
+	}
+	if f.ContentVersion != nil && old.ContentVersion != nil {
+		return *f.ContentVersion > *old.ContentVersion
+	}
+	if f.ContentVersion != nil && old.ContentVersion == nil {
+		return true
+	}
+	if f.ETag != nil && old.ETag != nil {
+		return *f.ETag != *old.ETag
+	}
+	return false
+}
+
+// FdtTransferWindow 计划传输窗口，Start/End 为 NTP 秒（与 Expires 相同，取 NTP 高 32 位）
+// End 缺省表示只在 Start 触发一次传输
+type FdtTransferWindow struct {
+	Start string  `xml:"Start,attr"`
+	End   *string `xml:"End,attr,omitempty"`
+}
+
+// NewFdtTransferWindow end 为 nil 表示无结束时间
+func NewFdtTransferWindow(start time.Time, end *time.Time) FdtTransferWindow {
+	ntp := func(t time.Time) string {
+		v, _ := tools.SystemTimeToNTP(t)
+		return strconv.FormatUint(v>>32, 10)
+	}
+	w := FdtTransferWindow{Start: ntp(start)}
+	if end != nil {
+		e := ntp(*end)
+		w.End = &e
+	}
+	return w
+}
+
+// Times 解析窗口起止时间，End 缺省时 end 为 nil
+func (w FdtTransferWindow) Times() (time.Time, *time.Time, error) {
+	parse := func(s string) (time.Time, error) {
+		v, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return tools.NTPToSystemTime(v << 32)
+	}
+	start, err := parse(w.Start)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	if w.End == nil {
+		return start, nil, nil
+	}
+	end, err := parse(*w.End)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	return start, &end, nil
+}
@@ -0,0 +1,237 @@
+package object
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// FDT 签名算法（Signature 元素的 Algorithm 属性）
+const (
+	FdtSigEd25519     = "Ed25519"
+	FdtSigECDSASHA256 = "ECDSA-SHA256"
+)
+
+var (
+	ErrFdtUnsigned         = errors.New("FDT-Instance is not signed")
+	ErrFdtUnknownKey       = errors.New("FDT-Instance signed with an unknown key")
+	ErrFdtSignatureInvalid = errors.New("FDT-Instance signature is invalid")
+)
+
+// FdtSignatureError 签名验证失败的原因，Reason 为上面的 ErrFdt* 之一，可用 errors.Is 判断
+type FdtSignatureError struct {
+	Reason    error
+	KeyID     string // 未签名时为空
+	Algorithm string
+	Detail    string // 可选，如 Base64 解码错误
+}
+
+func (e *FdtSignatureError) Error() string {
+	msg := e.Reason.Error()
+	if e.KeyID != "" {
+		msg += fmt.Sprintf(" (key %q, %s)", e.KeyID, e.Algorithm)
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+func (e *FdtSignatureError) Unwrap() error { return e.Reason }
+
+var (
+	fdtSigStart = []byte("<Signature")
+	fdtSigEnd   = []byte("</Signature>")
+	fdtEnd      = []byte("</FDT-Instance>")
+)
+
+// FdtSignature 嵌入在 FDT-Instance 末尾的签名元素
+// 这是本实现自定义的封装，不是 XML-DSig：没有 XML 规范化，签名直接覆盖去掉该元素（含所在行）之后的
+// 原始文档字节，只能与 SignFdtInstance/VerifyFdtInstance 互通
+type FdtSignature struct {
+	XMLName   xml.Name `xml:"Signature"`
+	Algorithm string   `xml:"Algorithm,attr"`
+	KeyID     string   `xml:"KeyId,attr"`
+	Value     string   `xml:",chardata"` // Base64
+}
+
+// FdtSigner 发送端签名配置，Key 为 ed25519.PrivateKey 或 *ecdsa.PrivateKey
+type FdtSigner struct {
+	KeyID string
+	Key   crypto.Signer
+}
+
+// SignFdtInstance 对 XML 文档签名，并把 Signature 元素插入到 </FDT-Instance> 之前
+func SignFdtInstance(doc []byte, signer *FdtSigner) ([]byte, error) {
+	end := bytes.LastIndex(doc, fdtEnd)
+	if end < 0 {
+		return nil, errors.New("not an FDT-Instance document")
+	}
+	lineStart := bytes.LastIndexByte(doc[:end], '\n') + 1
+	if len(bytes.TrimSpace(doc[lineStart:end])) != 0 {
+		// </FDT-Instance> 不在独立的行上：在它前面换行，签名覆盖换行后的文档
+		doc = append(append(append([]byte(nil), doc[:end]...), '\n'), doc[end:]...)
+		end++
+		lineStart = end
+	}
+
+	algo, sig, err := signFdt(signer, doc)
+	if err != nil {
+		return nil, err
+	}
+	elem, err := xml.Marshal(FdtSignature{
+		Algorithm: algo,
+		KeyID:     signer.KeyID,
+		Value:     base64.StdEncoding.EncodeToString(sig),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.Write(doc[:lineStart])
+	out.WriteString("  ")
+	out.Write(elem)
+	out.WriteByte('\n')
+	out.Write(doc[lineStart:])
+	return out.Bytes(), nil
+}
+
+func signFdt(signer *FdtSigner, doc []byte) (string, []byte, error) {
+	if signer == nil || signer.Key == nil {
+		return "", nil, errors.New("FDT signer has no key")
+	}
+	switch signer.Key.Public().(type) {
+	case ed25519.PublicKey:
+		sig, err := signer.Key.Sign(rand.Reader, doc, crypto.Hash(0))
+		return FdtSigEd25519, sig, err
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(doc)
+		sig, err := signer.Key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		return FdtSigECDSASHA256, sig, err
+	default:
+		return "", nil, fmt.Errorf("unsupported FDT signing key %T", signer.Key.Public())
+	}
+}
+
+// TrustStore 接收端信任的 FDT 签名公钥（KeyId -> 公钥）
+type TrustStore struct {
+	mu   sync.RWMutex
+	keys map[string]crypto.PublicKey
+}
+
+func NewTrustStore() *TrustStore {
+	return &TrustStore{keys: make(map[string]crypto.PublicKey)}
+}
+
+// Add 添加 ed25519.PublicKey 或 *ecdsa.PublicKey
+func (s *TrustStore) Add(keyID string, pub crypto.PublicKey) error {
+	switch pub.(type) {
+	case ed25519.PublicKey, *ecdsa.PublicKey:
+	default:
+		return fmt.Errorf("unsupported FDT public key %T", pub)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[keyID] = pub
+	return nil
+}
+
+func (s *TrustStore) Remove(keyID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, keyID)
+}
+
+func (s *TrustStore) get(keyID string) (crypto.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[keyID]
+	return k, ok
+}
+
+// VerifyFdtInstance 验证嵌入的签名，成功时返回去掉 Signature 元素后的文档（即被签名的字节）
+// 失败时返回 *FdtSignatureError：未签名为 ErrFdtUnsigned，KeyId 不在信任库为 ErrFdtUnknownKey，
+// 签名错误为 ErrFdtSignatureInvalid
+func VerifyFdtInstance(doc []byte, store *TrustStore) ([]byte, error) {
+	signed, _, err := verifyFdtInstance(doc, store)
+	return signed, err
+}
+
+func verifyFdtInstance(doc []byte, store *TrustStore) ([]byte, *FdtSignature, error) {
+	start := bytes.LastIndex(doc, fdtSigStart)
+	if start < 0 {
+		return nil, nil, &FdtSignatureError{Reason: ErrFdtUnsigned}
+	}
+	endRel := bytes.Index(doc[start:], fdtSigEnd)
+	if endRel < 0 {
+		return nil, nil, &FdtSignatureError{Reason: ErrFdtSignatureInvalid, Detail: "unterminated Signature element"}
+	}
+	end := start + endRel + len(fdtSigEnd)
+
+	var sig FdtSignature
+	if err := xml.Unmarshal(doc[start:end], &sig); err != nil {
+		return nil, nil, &FdtSignatureError{Reason: ErrFdtSignatureInvalid, Detail: err.Error()}
+	}
+	rejected := func(reason error, detail string) *FdtSignatureError {
+		return &FdtSignatureError{Reason: reason, KeyID: sig.KeyID, Algorithm: sig.Algorithm, Detail: detail}
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return nil, nil, rejected(ErrFdtSignatureInvalid, err.Error())
+	}
+
+	// 去掉签名元素所在的整行
+	lineStart := bytes.LastIndexByte(doc[:start], '\n') + 1
+	if len(bytes.TrimSpace(doc[lineStart:start])) != 0 {
+		lineStart = start
+	}
+	if end < len(doc) && doc[end] == '\n' {
+		end++
+	}
+	signed := append(append([]byte(nil), doc[:lineStart]...), doc[end:]...)
+
+	if store == nil {
+		return nil, nil, rejected(ErrFdtUnknownKey, "")
+	}
+	pub, ok := store.get(sig.KeyID)
+	if !ok {
+		return nil, nil, rejected(ErrFdtUnknownKey, "")
+	}
+
+	valid := false
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		valid = sig.Algorithm == FdtSigEd25519 && ed25519.Verify(k, signed, raw)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		valid = sig.Algorithm == FdtSigECDSASHA256 && ecdsa.VerifyASN1(k, digest[:], raw)
+	}
+	if !valid {
+		return nil, nil, rejected(ErrFdtSignatureInvalid, "")
+	}
+	return signed, &sig, nil
+}
+
+// ParseSignedFdtInstance 验证签名后只解析被签名的字节，Signature 字段填为已验证的签名
+// 验证失败返回 *FdtSignatureError
+func ParseSignedFdtInstance(doc []byte, store *TrustStore) (FdtInstance, error) {
+	signed, sig, err := verifyFdtInstance(doc, store)
+	if err != nil {
+		return FdtInstance{}, err
+	}
+	inst, err := ParseFdtInstance(signed)
+	if err != nil {
+		return FdtInstance{}, err
+	}
+	inst.Signature = sig
+	return inst, nil
+}
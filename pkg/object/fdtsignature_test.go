@@ -0,0 +1,84 @@
+package object
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"testing"
+)
+
+func testFdtDoc(t *testing.T) []byte {
+	length := uint64(1024)
+	inst := FdtInstance{
+		Expires: "3900000000",
+		Files: []FdtFile{
+			{ContentLocation: "file:///hello", TOI: "1", ContentLength: &length},
+		},
+	}
+	out, err := xml.MarshalIndent(inst, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	return append([]byte(xml.Header), out...)
+}
+
+func TestFdtSignatureEd25519(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	store := NewTrustStore()
+	if err := store.Add("head-end", pub); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	doc := testFdtDoc(t)
+	signed, err := SignFdtInstance(doc, &FdtSigner{KeyID: "head-end", Key: priv})
+	if err != nil {
+		t.Fatalf("SignFdtInstance failed: %v", err)
+	}
+
+	orig, err := VerifyFdtInstance(signed, store)
+	if err != nil {
+		t.Fatalf("VerifyFdtInstance failed: %v", err)
+	}
+	if !bytes.Equal(orig, doc) {
+		t.Fatalf("verified document differs from the original")
+	}
+
+	inst, err := ParseSignedFdtInstance(signed, store)
+	if err != nil {
+		t.Fatalf("ParseSignedFdtInstance failed: %v", err)
+	}
+	if inst.Signature == nil || inst.Signature.KeyID != "head-end" || len(inst.Files) != 1 {
+		t.Fatalf("unexpected parsed instance: %+v", inst)
+	}
+
+	tampered := bytes.Replace(signed, []byte("file:///hello"), []byte("file:///evil!"), 1)
+	if _, err := VerifyFdtInstance(tampered, store); !errors.Is(err, ErrFdtSignatureInvalid) {
+		t.Fatalf("expected ErrFdtSignatureInvalid, got %v", err)
+	}
+	if _, err := VerifyFdtInstance(doc, store); !errors.Is(err, ErrFdtUnsigned) {
+		t.Fatalf("expected ErrFdtUnsigned, got %v", err)
+	}
+	_, err = ParseSignedFdtInstance(signed, NewTrustStore())
+	var serr *FdtSignatureError
+	if !errors.As(err, &serr) || !errors.Is(err, ErrFdtUnknownKey) || serr.KeyID != "head-end" {
+		t.Fatalf("expected FdtSignatureError with ErrFdtUnknownKey, got %v", err)
+	}
+}
+
+func TestFdtSignatureECDSA(t *testing.T) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	store := NewTrustStore()
+	_ = store.Add("k1", &priv.PublicKey)
+
+	signed, err := SignFdtInstance(testFdtDoc(t), &FdtSigner{KeyID: "k1", Key: priv})
+	if err != nil {
+		t.Fatalf("SignFdtInstance failed: %v", err)
+	}
+	if _, err := VerifyFdtInstance(signed, store); err != nil {
+		t.Fatalf("VerifyFdtInstance failed: %v", err)
+	}
+}
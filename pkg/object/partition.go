@@ -2,7 +2,6 @@ package object
 
 import (
 	"Flute_go/pkg/tools"
-	"log"
 )
 
 ///
@@ -30,19 +29,14 @@ import (
 ///     * nb_blocks: The total number of blocks.
 ///
 
+// b 或 e 为 0 时返回全 0，由调用方（持有 logger）报告
 func BlockPartitioning(b, l, e uint64) (uint64, uint64, uint64, uint64) {
-	if b == 0 {
-		log.Println("Maximum Source Block Length can not be 0")
+	if b == 0 || e == 0 {
 		return 0, 0, 0, 0
 	}
 
-	if e == 0 {
-		log.Println("Encoding Symbol Length can not be 0")
-	}
-
 	t := tools.DivCeil(l, e)
 	n := tools.DivCeil(t, b)
-	log.Printf("t=%d n=%d b=%d l=%d e=%d\n", t, n, b, l, e)
 
 	if n == 0 {
 		return 0, 0, 0, 0
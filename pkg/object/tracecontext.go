@@ -0,0 +1,106 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// W3C Trace Context 头部名
+const (
+	TraceParentKey = "traceparent"
+	TraceStateKey  = "tracestate"
+)
+
+// OptelPropagatorEntry FDT File 中的一条传播字段：<Optel-Propagator Key="traceparent">...</Optel-Propagator>
+type OptelPropagatorEntry struct {
+	Key   string `xml:"Key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// OptelPropagatorEntries 把 map 按 key 排序转成 XML 子元素，保证 FDT 输出稳定
+func OptelPropagatorEntries(m map[string]string) []OptelPropagatorEntry {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]OptelPropagatorEntry, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, OptelPropagatorEntry{Key: k, Value: m[k]})
+	}
+	return out
+}
+
+// GetOptelPropagator 返回 File 携带的传播字段，没有则为 nil
+func (f *FdtFile) GetOptelPropagator() map[string]string {
+	if len(f.OptelPropagator) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(f.OptelPropagator))
+	for _, e := range f.OptelPropagator {
+		m[strings.ToLower(strings.TrimSpace(e.Key))] = strings.TrimSpace(e.Value)
+	}
+	return m
+}
+
+// ErrInvalidTraceParent traceparent 不符合 W3C Trace Context 格式（含全零 trace-id/span-id）
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// TracerName 接收端 span 的 instrumentation scope
+const TracerName = "Flute_go/pkg/object"
+
+// ExtractTraceContext 把传播字段中的 W3C trace context 作为远端父 span 放入 ctx
+// 没有携带或 traceparent 无效时返回 ctx 本身，可用 trace.SpanContextFromContext(...).IsValid() 判断
+func ExtractTraceContext(ctx context.Context, fields map[string]string) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier(fields))
+}
+
+// TraceContext 提取 File 携带的 trace context，见 ExtractTraceContext
+func (f *FdtFile) TraceContext(ctx context.Context) context.Context {
+	return ExtractTraceContext(ctx, f.GetOptelPropagator())
+}
+
+// StartReceptionSpan 以 File 携带的 trace context 为父开启子 span，覆盖接收、解码、写出，
+// 调用方在各阶段用 span.AddEvent 记录，结束时 span.End(trace.WithTimestamp(now))
+// tp 为 nil 时使用 otel.GetTracerProvider()；File 未携带有效 traceparent 时返回不记录的 span
+func StartReceptionSpan(ctx context.Context, tp trace.TracerProvider, f *FdtFile, now time.Time) (context.Context, trace.Span) {
+	ctx = f.TraceContext(ctx)
+	if !trace.SpanContextFromContext(ctx).IsRemote() {
+		return ctx, noop.Span{}
+	}
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(TracerName).Start(ctx, "flute.receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithTimestamp(now),
+		trace.WithAttributes(
+			attribute.String("flute.toi", f.TOI),
+			attribute.String("flute.content_location", f.ContentLocation),
+		))
+}
+
+// InjectTraceContext 返回 ctx 中当前 span 的传播字段（traceparent/tracestate），ctx 没有有效 span 时为 nil
+func InjectTraceContext(ctx context.Context) map[string]string {
+	m := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, m)
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,80 @@
+package object
+
+import (
+	"context"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceContextRoundTrip(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	inst := FdtInstance{
+		Expires: "3900000000",
+		Files: []FdtFile{{
+			ContentLocation: "file:///hello",
+			TOI:             "1",
+			OptelPropagator: OptelPropagatorEntries(map[string]string{
+				TraceParentKey: tp,
+				TraceStateKey:  "congo=t61rcWkgMzE",
+			}),
+		}},
+	}
+	out, err := xml.Marshal(inst)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	parsed, err := ParseFdtInstance(out)
+	if err != nil {
+		t.Fatalf("ParseFdtInstance failed: %v", err)
+	}
+
+	rec := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx, span := StartReceptionSpan(context.Background(), provider, &parsed.Files[0], now)
+	span.AddEvent("decoded", trace.WithTimestamp(now.Add(time.Second)))
+	span.End(trace.WithTimestamp(now.Add(2 * time.Second)))
+
+	ended := rec.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("expected one span, got %d", len(ended))
+	}
+	s := ended[0]
+	parent := s.Parent()
+	if parent.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || parent.SpanID().String() != "00f067aa0ba902b7" ||
+		!parent.IsRemote() {
+		t.Fatalf("span not parented to the FDT trace context: %+v", parent)
+	}
+	if s.SpanContext().TraceID() != parent.TraceID() || s.SpanContext().SpanID() == parent.SpanID() {
+		t.Fatalf("child span must share trace id with a new span id")
+	}
+	if s.SpanContext().TraceState().Get("congo") != "t61rcWkgMzE" || !s.SpanContext().IsSampled() {
+		t.Fatalf("tracestate/flags not propagated")
+	}
+	if s.Name() != "flute.receive" || !s.StartTime().Equal(now) || len(s.Events()) != 1 {
+		t.Fatalf("unexpected span %s start=%v events=%v", s.Name(), s.StartTime(), s.Events())
+	}
+
+	// 继续向下游传递时以接收 span 为父
+	if down := InjectTraceContext(ctx); down[TraceParentKey] != "00-"+s.SpanContext().TraceID().String()+"-"+
+		s.SpanContext().SpanID().String()+"-01" {
+		t.Fatalf("unexpected downstream context %v", down)
+	}
+
+	invalid := FdtFile{OptelPropagator: OptelPropagatorEntries(map[string]string{
+		TraceParentKey: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+	})}
+	for _, f := range []FdtFile{{}, invalid} {
+		if _, span := StartReceptionSpan(context.Background(), provider, &f, now); span.SpanContext().IsValid() {
+			t.Fatalf("file without a valid trace context must not start a span")
+		}
+	}
+	if len(rec.Started()) != 1 {
+		t.Fatalf("unexpected spans started: %d", len(rec.Started()))
+	}
+}
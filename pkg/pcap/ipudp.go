@@ -0,0 +1,228 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+)
+
+// 链路层类型（LINKTYPE_*）
+const (
+	LinkTypeNull     = 0
+	LinkTypeEthernet = 1
+	LinkTypeRaw      = 101
+	LinkTypeLinuxSLL = 113
+	LinkTypeIPv4     = 228
+	LinkTypeIPv6     = 229
+	LinkTypeLinuxSL2 = 276
+)
+
+const (
+	ipProtoUDP    = 17
+	etherTypeIP4  = 0x0800
+	etherTypeIP6  = 0x86DD
+	etherTypeVLN  = 0x8100
+	etherTypeQinQ = 0x88A8
+)
+
+var errNotUDP = errors.New("not a UDP datagram")
+
+// BuildIPUDP 合成 IPv4/IPv6 + UDP 头（含校验和），src 与 dst 必须同为 IPv4 或 IPv6；ipID 仅用于 IPv4
+func BuildIPUDP(src, dst netip.AddrPort, payload []byte, ipID uint16) ([]byte, error) {
+	udpLen := 8 + len(payload)
+	if udpLen > 0xFFFF {
+		return nil, errors.New("UDP payload too large")
+	}
+	var ip []byte
+	switch {
+	case src.Addr().Is4() && dst.Addr().Is4():
+		ip = make([]byte, 20, 20+udpLen)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+udpLen))
+		binary.BigEndian.PutUint16(ip[4:], ipID)
+		binary.BigEndian.PutUint16(ip[6:], 0x4000) // DF
+		ip[8] = 64
+		if dst.Addr().IsMulticast() {
+			ip[8] = 1
+		}
+		ip[9] = ipProtoUDP
+		s4, d4 := src.Addr().As4(), dst.Addr().As4()
+		copy(ip[12:], s4[:])
+		copy(ip[16:], d4[:])
+		binary.BigEndian.PutUint16(ip[10:], ^onesSum(0, ip))
+	case src.Addr().Is6() && dst.Addr().Is6():
+		ip = make([]byte, 40, 40+udpLen)
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:], uint16(udpLen))
+		ip[6] = ipProtoUDP
+		ip[7] = 64
+		s16, d16 := src.Addr().As16(), dst.Addr().As16()
+		copy(ip[8:], s16[:])
+		copy(ip[24:], d16[:])
+	default:
+		return nil, errors.New("source and destination address families differ")
+	}
+
+	udp := make([]byte, 8, udpLen)
+	binary.BigEndian.PutUint16(udp[0:], src.Port())
+	binary.BigEndian.PutUint16(udp[2:], dst.Port())
+	binary.BigEndian.PutUint16(udp[4:], uint16(udpLen))
+	udp = append(udp, payload...)
+	sum := ^onesSum(pseudoHeaderSum(src.Addr(), dst.Addr(), udpLen), udp)
+	if sum == 0 {
+		sum = 0xFFFF
+	}
+	binary.BigEndian.PutUint16(udp[6:], sum)
+	return append(ip, udp...), nil
+}
+
+func pseudoHeaderSum(src, dst netip.Addr, udpLen int) uint32 {
+	var sum uint32
+	s, d := src.AsSlice(), dst.AsSlice()
+	sum = onesAdd(sum, s)
+	sum = onesAdd(sum, d)
+	sum += ipProtoUDP
+	sum += uint32(udpLen)
+	return sum
+}
+
+func onesAdd(sum uint32, b []byte) uint32 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	return sum
+}
+
+// onesSum 16 位反码和（未取反）
+func onesSum(initial uint32, b []byte) uint16 {
+	sum := onesAdd(initial, b)
+	for sum>>16 != 0 {
+		sum = sum&0xFFFF + sum>>16
+	}
+	return uint16(sum)
+}
+
+// ParseIPUDP 从 IPv4/IPv6 数据报中取出 UDP 载荷；非 UDP 或 IP 分片返回错误
+func ParseIPUDP(b []byte) (src, dst netip.AddrPort, payload []byte, err error) {
+	return parseFrame(LinkTypeRaw, b, nil)
+}
+
+// parseFrame 按链路层类型取出 UDP 数据报；IP 分片不重组，直接跳过
+func parseFrame(linkType uint16, frame []byte, order binary.ByteOrder) (src, dst netip.AddrPort, payload []byte, err error) {
+	switch linkType {
+	case LinkTypeEthernet:
+		if len(frame) < 14 {
+			return src, dst, nil, errNotUDP
+		}
+		etherType := binary.BigEndian.Uint16(frame[12:])
+		frame = frame[14:]
+		for (etherType == etherTypeVLN || etherType == etherTypeQinQ) && len(frame) >= 4 {
+			etherType = binary.BigEndian.Uint16(frame[2:])
+			frame = frame[4:]
+		}
+		return parseEtherType(etherType, frame)
+	case LinkTypeLinuxSLL:
+		if len(frame) < 16 {
+			return src, dst, nil, errNotUDP
+		}
+		return parseEtherType(binary.BigEndian.Uint16(frame[14:]), frame[16:])
+	case LinkTypeLinuxSL2:
+		if len(frame) < 20 {
+			return src, dst, nil, errNotUDP
+		}
+		return parseEtherType(binary.BigEndian.Uint16(frame[0:]), frame[20:])
+	case LinkTypeNull:
+		// 4 字节地址族，按抓包主机字节序
+		if len(frame) < 4 {
+			return src, dst, nil, errNotUDP
+		}
+		switch order.Uint32(frame) {
+		case 2:
+			return parseIPv4(frame[4:])
+		case 24, 28, 30:
+			return parseIPv6(frame[4:])
+		}
+		return src, dst, nil, errNotUDP
+	case LinkTypeRaw, 12, 14, LinkTypeIPv4, LinkTypeIPv6:
+		if len(frame) == 0 {
+			return src, dst, nil, errNotUDP
+		}
+		switch frame[0] >> 4 {
+		case 4:
+			return parseIPv4(frame)
+		case 6:
+			return parseIPv6(frame)
+		}
+	}
+	return src, dst, nil, errNotUDP
+}
+
+func parseEtherType(etherType uint16, b []byte) (netip.AddrPort, netip.AddrPort, []byte, error) {
+	switch etherType {
+	case etherTypeIP4:
+		return parseIPv4(b)
+	case etherTypeIP6:
+		return parseIPv6(b)
+	}
+	return netip.AddrPort{}, netip.AddrPort{}, nil, errNotUDP
+}
+
+func parseIPv4(b []byte) (src, dst netip.AddrPort, payload []byte, err error) {
+	if len(b) < 20 || b[0]>>4 != 4 {
+		return src, dst, nil, errNotUDP
+	}
+	ihl := int(b[0]&0x0F) * 4
+	total := int(binary.BigEndian.Uint16(b[2:]))
+	frag := binary.BigEndian.Uint16(b[6:])
+	if b[9] != ipProtoUDP || ihl < 20 || total < ihl || total > len(b) || frag&0x3FFF != 0 {
+		return src, dst, nil, errNotUDP
+	}
+	s := netip.AddrFrom4([4]byte(b[12:16]))
+	d := netip.AddrFrom4([4]byte(b[16:20]))
+	return parseUDP(s, d, b[ihl:total])
+}
+
+func parseIPv6(b []byte) (src, dst netip.AddrPort, payload []byte, err error) {
+	if len(b) < 40 || b[0]>>4 != 6 {
+		return src, dst, nil, errNotUDP
+	}
+	plen := int(binary.BigEndian.Uint16(b[4:]))
+	if 40+plen > len(b) {
+		return src, dst, nil, errNotUDP
+	}
+	s := netip.AddrFrom16([16]byte(b[8:24]))
+	d := netip.AddrFrom16([16]byte(b[24:40]))
+	next := b[6]
+	rest := b[40 : 40+plen]
+	// 跳过逐跳/路由/目的选项扩展头；分片（44）不重组
+	for next == 0 || next == 43 || next == 60 {
+		if len(rest) < 8 {
+			return src, dst, nil, errNotUDP
+		}
+		l := (int(rest[1]) + 1) * 8
+		if l > len(rest) {
+			return src, dst, nil, errNotUDP
+		}
+		next, rest = rest[0], rest[l:]
+	}
+	if next != ipProtoUDP {
+		return src, dst, nil, errNotUDP
+	}
+	return parseUDP(s, d, rest)
+}
+
+func parseUDP(s, d netip.Addr, b []byte) (src, dst netip.AddrPort, payload []byte, err error) {
+	if len(b) < 8 {
+		return src, dst, nil, errNotUDP
+	}
+	l := int(binary.BigEndian.Uint16(b[4:]))
+	if l < 8 || l > len(b) {
+		return src, dst, nil, errNotUDP
+	}
+	src = netip.AddrPortFrom(s, binary.BigEndian.Uint16(b[0:]))
+	dst = netip.AddrPortFrom(d, binary.BigEndian.Uint16(b[2:]))
+	return src, dst, b[8:l], nil
+}
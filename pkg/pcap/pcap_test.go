@@ -0,0 +1,223 @@
+package pcap
+
+import (
+	"Flute_go/pkg/clock"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/sender"
+	"Flute_go/pkg/transport"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, data []byte) []*Packet {
+	t.Helper()
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkts []*Packet
+	for {
+		p, err := r.Next()
+		if err == io.EOF {
+			return pkts
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkts = append(pkts, p)
+	}
+}
+
+// 记录 Sender.Read 的输出，再读回，载荷、地址与时间戳一致
+func TestRecordSenderRoundTrip(t *testing.T) {
+	for _, dest := range []string{"224.0.0.1", "ff02::1"} {
+		t.Run(dest, func(t *testing.T) {
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			clk := clock.NewVirtual(start)
+			cfg := sender.DefaultConfig()
+			cfg.Clock = clk
+			endpoint := transport.NewUDPEndpoint(nil, dest, 3400)
+			s := sender.NewSender(endpoint, 1, oti.NewOti(), &cfg)
+			u, _ := url.Parse("file:///hello.txt")
+			obj, err := sender.CreateFromBuffer(bytes.Repeat([]byte("hello flute "), 500), "text/plain", u, 1,
+				nil, nil, nil, nil, 0, true, nil, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.AddObject(0, obj); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Publish(clk.Now()); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			w, err := NewEndpointWriter(&buf, endpoint, 5000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sent [][]byte
+			var times []time.Time
+			for data := s.Read(clk.Now()); data != nil; data = s.Read(clk.Now()) {
+				if err := w.WritePacket(clk.Now(), data); err != nil {
+					t.Fatal(err)
+				}
+				sent = append(sent, data)
+				times = append(times, clk.Now())
+				clk.Advance(1500 * time.Microsecond)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if len(sent) == 0 || w.Count() != uint64(len(sent)) {
+				t.Fatalf("sent %d packets, writer counted %d", len(sent), w.Count())
+			}
+
+			pkts := readAll(t, buf.Bytes())
+			if len(pkts) != len(sent) {
+				t.Fatalf("read %d packets, want %d", len(pkts), len(sent))
+			}
+			wantDst := netip.MustParseAddrPort(endpoint.DestAddr())
+			for i, p := range pkts {
+				if !bytes.Equal(p.Payload, sent[i]) {
+					t.Fatalf("packet %d: payload differs", i)
+				}
+				if !p.Timestamp.Equal(times[i]) {
+					t.Fatalf("packet %d: timestamp %v, want %v", i, p.Timestamp, times[i])
+				}
+				if p.Dst != wantDst || p.Src.Port() != 5000 {
+					t.Fatalf("packet %d: %v -> %v", i, p.Src, p.Dst)
+				}
+			}
+		})
+	}
+}
+
+// 手工构造的经典 pcap：大端、微秒、Ethernet + VLAN，混有非 UDP 帧
+func TestReadClassicEthernet(t *testing.T) {
+	src := netip.MustParseAddrPort("10.0.0.1:1234")
+	dst := netip.MustParseAddrPort("239.1.2.3:4000")
+	ip, err := BuildIPUDP(src, dst, []byte("payload"), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth := make([]byte, 12, 18+len(ip))
+	eth = binary.BigEndian.AppendUint16(eth, etherTypeVLN)
+	eth = binary.BigEndian.AppendUint16(eth, 42)
+	eth = binary.BigEndian.AppendUint16(eth, etherTypeIP4)
+	eth = append(eth, ip...)
+	arp := append(make([]byte, 12), 0x08, 0x06, 0, 1)
+
+	var buf bytes.Buffer
+	be := binary.BigEndian
+	hdr := make([]byte, 24)
+	be.PutUint32(hdr[0:], magicMicro)
+	be.PutUint16(hdr[4:], 2)
+	be.PutUint16(hdr[6:], 4)
+	be.PutUint32(hdr[16:], 65535)
+	be.PutUint32(hdr[20:], LinkTypeEthernet)
+	buf.Write(hdr)
+	for i, frame := range [][]byte{arp, eth} {
+		rec := make([]byte, 16)
+		be.PutUint32(rec[0:], 1700000000)
+		be.PutUint32(rec[4:], uint32(250000*i))
+		be.PutUint32(rec[8:], uint32(len(frame)))
+		be.PutUint32(rec[12:], uint32(len(frame)))
+		buf.Write(rec)
+		buf.Write(frame)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p.Payload) != "payload" || p.Src != src || p.Dst != dst {
+		t.Fatalf("got %v -> %v %q", p.Src, p.Dst, p.Payload)
+	}
+	if want := time.Unix(1700000000, 250_000_000); !p.Timestamp.Equal(want) {
+		t.Fatalf("timestamp %v, want %v", p.Timestamp, want)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if r.Skipped != 1 {
+		t.Fatalf("skipped %d frames, want 1", r.Skipped)
+	}
+}
+
+// 校验和可被 Wireshark 接受：对含校验和的头部再求反码和应为 0xFFFF
+func TestChecksums(t *testing.T) {
+	src := netip.MustParseAddrPort("192.168.1.10:5000")
+	dst := netip.MustParseAddrPort("224.0.0.1:3400")
+	frame, err := BuildIPUDP(src, dst, []byte("odd"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := onesSum(0, frame[:20]); sum != 0xFFFF {
+		t.Fatalf("IPv4 header checksum invalid: %#x", sum)
+	}
+	udp := frame[20:]
+	if sum := onesSum(pseudoHeaderSum(src.Addr(), dst.Addr(), len(udp)), udp); sum != 0xFFFF {
+		t.Fatalf("UDP checksum invalid: %#x", sum)
+	}
+}
+
+// 加速回放：虚拟时钟上耗时为原始间隔除以速度；按目的端口过滤，跨多个 pcapng 节
+func TestReplaySpeed(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, netip.MustParseAddrPort("10.0.0.1:1"), netip.MustParseAddrPort("224.0.0.1:3400"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Unix(1700000000, 0)
+	for i := range 5 {
+		if err := w.WritePacket(base.Add(time.Duration(i)*time.Second), []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Flush()
+	// 第二个节：发往其他端口的包应被过滤掉
+	other, err := NewWriter(&buf, netip.MustParseAddrPort("10.0.0.1:1"), netip.MustParseAddrPort("224.0.0.1:9"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.WritePacket(base.Add(10*time.Second), []byte{9})
+	other.Flush()
+
+	for _, tc := range []struct {
+		speed float64
+		want  time.Duration
+	}{{1, 4 * time.Second}, {4, time.Second}, {0, 0}} {
+		r, err := NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		clk := clock.NewVirtual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		start := clk.Now()
+		var got []byte
+		n, err := Replay(context.Background(), r, ReplayOptions{Speed: tc.speed, Clock: clk, Dst: netip.AddrPortFrom(netip.Addr{}, 3400)},
+			func(p *Packet) error {
+				got = append(got, p.Payload...)
+				return nil
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 5 || !bytes.Equal(got, []byte{0, 1, 2, 3, 4}) {
+			t.Fatalf("speed %v: delivered %d packets %v", tc.speed, n, got)
+		}
+		if elapsed := clk.Now().Sub(start); elapsed != tc.want {
+			t.Fatalf("speed %v: replay took %v, want %v", tc.speed, elapsed, tc.want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"time"
+)
+
+// Packet 从抓包中取出的一个 UDP 数据报
+type Packet struct {
+	Timestamp time.Time
+	Src       netip.AddrPort
+	Dst       netip.AddrPort
+	Payload   []byte
+}
+
+// 经典 pcap 文件头魔数
+const (
+	magicMicro = 0xA1B2C3D4
+	magicNano  = 0xA1B23C4D
+)
+
+// maxBlockLen 单个块/记录的上限，防止损坏的文件导致超大分配
+const maxBlockLen = 16 << 20
+
+var errBadFormat = errors.New("pcap: unrecognized file format")
+
+type iface struct {
+	linkType uint16
+	// 每秒的时间戳单位数
+	unitsPerSec uint64
+}
+
+// Reader 读取经典 pcap（微秒/纳秒，任意字节序）与 pcapng，只返回 UDP 数据报
+// 支持 Ethernet（含 VLAN）、Raw IP、Linux SLL/SLL2、BSD loopback 链路层；IP 分片不重组
+type Reader struct {
+	r     *bufio.Reader
+	ng    bool
+	order binary.ByteOrder
+
+	// 经典 pcap
+	linkType uint16
+	nano     bool
+
+	// pcapng：当前节的接口
+	ifaces []iface
+
+	// Skipped 被跳过的非 UDP（或分片、无法解析）的帧数
+	Skipped uint64
+}
+
+// NewReader 根据文件头自动识别 pcap/pcapng
+func NewReader(r io.Reader) (*Reader, error) {
+	pr := &Reader{r: bufio.NewReaderSize(r, 1<<16)}
+	head, err := pr.r.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("pcap: reading header: %w", err)
+	}
+	if binary.LittleEndian.Uint32(head) == blockSHB {
+		pr.ng = true
+		return pr, nil
+	}
+	var hdr [24]byte
+	if _, err := io.ReadFull(pr.r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("pcap: reading header: %w", err)
+	}
+	switch {
+	case binary.LittleEndian.Uint32(hdr[:]) == magicMicro:
+		pr.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(hdr[:]) == magicMicro:
+		pr.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(hdr[:]) == magicNano:
+		pr.order, pr.nano = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(hdr[:]) == magicNano:
+		pr.order, pr.nano = binary.BigEndian, true
+	default:
+		return nil, errBadFormat
+	}
+	// 高 16 位为 FCS 等标志
+	pr.linkType = uint16(pr.order.Uint32(hdr[20:]))
+	return pr, nil
+}
+
+// Next 返回下一个 UDP 数据报，结束时返回 io.EOF
+func (pr *Reader) Next() (*Packet, error) {
+	for {
+		var (
+			p   *Packet
+			err error
+		)
+		if pr.ng {
+			p, err = pr.nextBlock()
+		} else {
+			p, err = pr.nextRecord()
+		}
+		if err != nil || p != nil {
+			return p, err
+		}
+	}
+}
+
+// nextRecord 读一条经典 pcap 记录；非 UDP 帧返回 (nil, nil)
+func (pr *Reader) nextRecord() (*Packet, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(pr.r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("pcap: truncated record header: %w", err)
+		}
+		return nil, err
+	}
+	sec := pr.order.Uint32(hdr[0:])
+	frac := pr.order.Uint32(hdr[4:])
+	capLen := pr.order.Uint32(hdr[8:])
+	if capLen > maxBlockLen {
+		return nil, fmt.Errorf("pcap: record length %d too large", capLen)
+	}
+	frame := make([]byte, capLen)
+	if _, err := io.ReadFull(pr.r, frame); err != nil {
+		return nil, fmt.Errorf("pcap: truncated record: %w", err)
+	}
+	if !pr.nano {
+		frac *= 1000
+	}
+	return pr.packet(pr.linkType, frame, time.Unix(int64(sec), int64(frac)))
+}
+
+// nextBlock 读一个 pcapng 块；非包块或非 UDP 帧返回 (nil, nil)
+func (pr *Reader) nextBlock() (*Packet, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(pr.r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("pcap: truncated block header: %w", err)
+		}
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(hdr[:]) == blockSHB {
+		// 新的节：字节序由节内的字节序标记决定
+		var bom [4]byte
+		if _, err := io.ReadFull(pr.r, bom[:]); err != nil {
+			return nil, fmt.Errorf("pcap: truncated section header: %w", err)
+		}
+		switch {
+		case binary.LittleEndian.Uint32(bom[:]) == byteOrderMagic:
+			pr.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(bom[:]) == byteOrderMagic:
+			pr.order = binary.BigEndian
+		default:
+			return nil, errBadFormat
+		}
+		pr.ifaces = pr.ifaces[:0]
+		total := pr.order.Uint32(hdr[4:])
+		if total < 28 || total%4 != 0 || total > maxBlockLen {
+			return nil, fmt.Errorf("pcap: bad section header length %d", total)
+		}
+		_, err := pr.r.Discard(int(total) - 12)
+		return nil, err
+	}
+	if pr.order == nil {
+		return nil, errBadFormat
+	}
+
+	blockType := pr.order.Uint32(hdr[0:])
+	total := pr.order.Uint32(hdr[4:])
+	if total < 12 || total%4 != 0 || total > maxBlockLen {
+		return nil, fmt.Errorf("pcap: bad block length %d", total)
+	}
+	body := make([]byte, total-8)
+	if _, err := io.ReadFull(pr.r, body); err != nil {
+		return nil, fmt.Errorf("pcap: truncated block: %w", err)
+	}
+	body = body[:len(body)-4]
+
+	switch blockType {
+	case blockIDB:
+		if len(body) < 8 {
+			return nil, errors.New("pcap: short interface description block")
+		}
+		ifc := iface{linkType: pr.order.Uint16(body[0:]), unitsPerSec: 1_000_000}
+		walkOptions(body[8:], pr.order, func(code uint16, value []byte) {
+			if code == optIfTsResol && len(value) >= 1 {
+				ifc.unitsPerSec = tsResolution(value[0])
+			}
+		})
+		pr.ifaces = append(pr.ifaces, ifc)
+		return nil, nil
+	case blockEPB:
+		if len(body) < 20 {
+			return nil, errors.New("pcap: short enhanced packet block")
+		}
+		id := pr.order.Uint32(body[0:])
+		if int(id) >= len(pr.ifaces) {
+			return nil, fmt.Errorf("pcap: packet references unknown interface %d", id)
+		}
+		ifc := pr.ifaces[id]
+		ts := uint64(pr.order.Uint32(body[4:]))<<32 | uint64(pr.order.Uint32(body[8:]))
+		capLen := pr.order.Uint32(body[12:])
+		if uint64(capLen) > uint64(len(body)-20) {
+			return nil, errors.New("pcap: enhanced packet block shorter than captured length")
+		}
+		return pr.packet(ifc.linkType, body[20:20+capLen], ifc.timestamp(ts))
+	case blockSPB:
+		// Simple Packet Block：没有时间戳，属于接口 0
+		if len(body) < 4 || len(pr.ifaces) == 0 {
+			return nil, errors.New("pcap: bad simple packet block")
+		}
+		capLen := min(int(pr.order.Uint32(body[0:])), len(body)-4)
+		return pr.packet(pr.ifaces[0].linkType, body[4:4+capLen], time.Time{})
+	}
+	return nil, nil
+}
+
+func (pr *Reader) packet(linkType uint16, frame []byte, ts time.Time) (*Packet, error) {
+	src, dst, payload, err := parseFrame(linkType, frame, pr.order)
+	if err != nil {
+		pr.Skipped++
+		return nil, nil
+	}
+	return &Packet{Timestamp: ts, Src: src, Dst: dst, Payload: payload}, nil
+}
+
+func (ifc iface) timestamp(ts uint64) time.Time {
+	sec := ts / ifc.unitsPerSec
+	rem := ts % ifc.unitsPerSec
+	ns := rem * 1_000_000_000 / ifc.unitsPerSec
+	if ifc.unitsPerSec > 1_000_000_000 {
+		ns = uint64(float64(rem) * 1e9 / float64(ifc.unitsPerSec))
+	}
+	return time.Unix(int64(sec), int64(ns))
+}
+
+// tsResolution if_tsresol：最高位为 1 表示 2 的负幂，否则为 10 的负幂
+func tsResolution(v byte) uint64 {
+	exp := uint64(v & 0x7F)
+	if v&0x80 != 0 {
+		if exp > 63 {
+			exp = 63
+		}
+		return 1 << exp
+	}
+	if exp > 19 {
+		return math.MaxUint64
+	}
+	n := uint64(1)
+	for range exp {
+		n *= 10
+	}
+	return n
+}
+
+func walkOptions(b []byte, order binary.ByteOrder, fn func(code uint16, value []byte)) {
+	for len(b) >= 4 {
+		code := order.Uint16(b[0:])
+		l := int(order.Uint16(b[2:]))
+		if code == optEndOfOpt || 4+l > len(b) {
+			return
+		}
+		fn(code, b[4:4+l])
+		b = b[min(len(b), 4+(l+3)&^3):]
+	}
+}
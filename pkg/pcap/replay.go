@@ -0,0 +1,101 @@
+package pcap
+
+import (
+	"Flute_go/pkg/clock"
+	"Flute_go/pkg/transport"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// ReplayOptions 回放参数
+type ReplayOptions struct {
+	// 回放速度：1 = 原始节奏，>1 加速，0 = 不等待、尽快送出
+	Speed float64
+	// nil = 系统时钟；使用 clock.Virtual 时 Sleep 直接推进虚拟时间
+	Clock clock.Clock
+	// 只回放目的地址匹配的包；零值表示不过滤，地址为零值时只按端口过滤
+	Dst netip.AddrPort
+}
+
+// Source 数据报来源（*Reader 或其他封装格式的读取器），结束时 Next 返回 io.EOF
+type Source interface {
+	Next() (*Packet, error)
+}
+
+// maxSleep 单次等待上限，以便及时响应 ctx 取消
+const maxSleep = 100 * time.Millisecond
+
+func (o *ReplayOptions) match(p *Packet) bool {
+	if o.Dst.Port() != 0 && o.Dst.Port() != p.Dst.Port() {
+		return false
+	}
+	return !o.Dst.Addr().IsValid() || o.Dst.Addr() == p.Dst.Addr()
+}
+
+// Replay 按抓包中的时间间隔（除以 Speed）把数据报交给 deliver，返回已交付的包数
+// 没有时间戳的包（Simple Packet Block、MPEG-TS 解封装结果）沿用上一个包的时间
+func Replay(ctx context.Context, r Source, opts ReplayOptions, deliver func(*Packet) error) (int, error) {
+	if opts.Speed < 0 {
+		return 0, errors.New("pcap: negative replay speed")
+	}
+	clk := clock.OrReal(opts.Clock)
+	var first, last, wallStart time.Time
+	n := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		p, err := r.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if !opts.match(p) {
+			continue
+		}
+		ts := p.Timestamp
+		if ts.IsZero() {
+			ts = last
+		}
+		last = ts
+		if n == 0 {
+			first, wallStart = ts, clk.Now()
+		} else if opts.Speed > 0 && !ts.IsZero() {
+			due := wallStart.Add(time.Duration(float64(ts.Sub(first)) / opts.Speed))
+			if err := sleepUntil(ctx, clk, due); err != nil {
+				return n, err
+			}
+		}
+		if err := deliver(p); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// ReplayTo 把数据报载荷写入 conn（例如 transport.Loopback 的一个成员，接收端从另一个成员读取）
+func ReplayTo(ctx context.Context, r Source, opts ReplayOptions, conn transport.PacketConn, addr net.Addr) (int, error) {
+	return Replay(ctx, r, opts, func(p *Packet) error {
+		_, err := conn.WriteTo(p.Payload, addr)
+		return err
+	})
+}
+
+func sleepUntil(ctx context.Context, clk clock.Clock, due time.Time) error {
+	for {
+		d := due.Sub(clk.Now())
+		if d <= 0 {
+			return nil
+		}
+		clk.Sleep(min(d, maxSleep))
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package pcap 抓包的读写：把发送端输出记录为 pcapng，并把现场抓到的 pcap/pcapng 离线回放给接收端
+package pcap
+
+import (
+	"Flute_go/pkg/clock"
+	"Flute_go/pkg/transport"
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// pcapng 块类型
+const (
+	blockSHB = 0x0A0D0D0A
+	blockIDB = 0x00000001
+	blockSPB = 0x00000003
+	blockEPB = 0x00000006
+
+	byteOrderMagic = 0x1A2B3C4D
+	optEndOfOpt    = 0
+	optIfTsResol   = 9
+)
+
+// Writer 把 Sender.Read 的输出写成 pcapng（LINKTYPE_RAW，合成 IPv4/IPv6 + UDP 头，纳秒时间戳）
+// 可以直接用 Wireshark 打开，也可以用 Reader 离线回放；并发安全
+type Writer struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	src   netip.AddrPort
+	dst   netip.AddrPort
+	ipID  uint16
+	count uint64
+}
+
+// NewWriter src/dst 为合成头部使用的地址，必须同为 IPv4 或 IPv6
+func NewWriter(w io.Writer, src, dst netip.AddrPort) (*Writer, error) {
+	if src.Addr().Is4() != dst.Addr().Is4() {
+		return nil, errors.New("pcap: source and destination address families differ")
+	}
+	pw := &Writer{w: bufio.NewWriter(w), src: src, dst: dst}
+	if err := pw.writeHeader(); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// NewEndpointWriter 按 UDPEndpoint 取目的地址；未配置源地址时使用同族的未指定地址，源端口为 srcPort
+func NewEndpointWriter(w io.Writer, e transport.UDPEndpoint, srcPort uint16) (*Writer, error) {
+	dst, err := netip.ParseAddr(e.DestinationGroupAddress)
+	if err != nil {
+		return nil, fmt.Errorf("pcap: destination %q: %w", e.DestinationGroupAddress, err)
+	}
+	src := netip.IPv4Unspecified()
+	if dst.Is6() {
+		src = netip.IPv6Unspecified()
+	}
+	if e.SourceAddress != nil && *e.SourceAddress != "" {
+		if src, err = netip.ParseAddr(*e.SourceAddress); err != nil {
+			return nil, fmt.Errorf("pcap: source %q: %w", *e.SourceAddress, err)
+		}
+	}
+	return NewWriter(w, netip.AddrPortFrom(src, srcPort), netip.AddrPortFrom(dst, e.Port))
+}
+
+func (pw *Writer) writeHeader() error {
+	// Section Header Block：字节序标记、版本 1.0、节长度未知
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:], byteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[4:], 1)
+	binary.LittleEndian.PutUint16(shb[6:], 0)
+	binary.LittleEndian.PutUint64(shb[8:], ^uint64(0))
+	if err := pw.writeBlock(blockSHB, shb); err != nil {
+		return err
+	}
+
+	// Interface Description Block：LINKTYPE_RAW，if_tsresol = 10^-9
+	idb := make([]byte, 8, 20)
+	binary.LittleEndian.PutUint16(idb[0:], LinkTypeRaw)
+	binary.LittleEndian.PutUint32(idb[4:], 0) // snaplen 不限
+	idb = appendOption(idb, optIfTsResol, []byte{9})
+	idb = appendOption(idb, optEndOfOpt, nil)
+	return pw.writeBlock(blockIDB, idb)
+}
+
+// WritePacket 写入一个 UDP 载荷（如 Sender.Read 的返回值），ts 为发送时间
+func (pw *Writer) WritePacket(ts time.Time, payload []byte) error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	frame, err := BuildIPUDP(pw.src, pw.dst, payload, pw.ipID)
+	if err != nil {
+		return err
+	}
+	pw.ipID++
+
+	ns := uint64(ts.UnixNano())
+	epb := make([]byte, 20, 20+len(frame)+3)
+	binary.LittleEndian.PutUint32(epb[0:], 0) // interface 0
+	binary.LittleEndian.PutUint32(epb[4:], uint32(ns>>32))
+	binary.LittleEndian.PutUint32(epb[8:], uint32(ns))
+	binary.LittleEndian.PutUint32(epb[12:], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(epb[16:], uint32(len(frame)))
+	epb = append(epb, frame...)
+	epb = pad4(epb)
+	if err := pw.writeBlock(blockEPB, epb); err != nil {
+		return err
+	}
+	pw.count++
+	return nil
+}
+
+// Count 已写入的包数
+func (pw *Writer) Count() uint64 {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.count
+}
+
+// Flush 把缓冲写入底层 io.Writer（不关闭它）
+func (pw *Writer) Flush() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.w.Flush()
+}
+
+func (pw *Writer) writeBlock(blockType uint32, body []byte) error {
+	total := uint32(12 + len(body))
+	var hdr [8]byte
+	binary.LittleEndian.PutUint32(hdr[0:], blockType)
+	binary.LittleEndian.PutUint32(hdr[4:], total)
+	if _, err := pw.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	if _, err := pw.w.Write(body); err != nil {
+		return err
+	}
+	var tail [4]byte
+	binary.LittleEndian.PutUint32(tail[:], total)
+	_, err := pw.w.Write(tail[:])
+	return err
+}
+
+func appendOption(b []byte, code uint16, value []byte) []byte {
+	var h [4]byte
+	binary.LittleEndian.PutUint16(h[0:], code)
+	binary.LittleEndian.PutUint16(h[2:], uint16(len(value)))
+	b = append(b, h[:]...)
+	b = append(b, value...)
+	return pad4(b)
+}
+
+func pad4(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// CaptureConn 在 PacketConn 外记录每个写出的包，时间取自 clk；包仍照常写给内层连接
+type CaptureConn struct {
+	transport.PacketConn
+	w     *Writer
+	clock clock.Clock
+}
+
+// NewCaptureConn clk 为 nil 使用系统时钟
+func NewCaptureConn(inner transport.PacketConn, w *Writer, clk clock.Clock) *CaptureConn {
+	return &CaptureConn{PacketConn: inner, w: w, clock: clock.OrReal(clk)}
+}
+
+func (c *CaptureConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if err := c.w.WritePacket(c.clock.Now(), p); err != nil {
+		return 0, err
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+// Flush 刷新抓包缓冲与内层连接
+func (c *CaptureConn) Flush() error {
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	return transport.Flush(c.PacketConn)
+}
+
+// Close 刷新抓包缓冲后关闭内层连接
+func (c *CaptureConn) Close() error {
+	err := c.w.Flush()
+	if cerr := c.PacketConn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
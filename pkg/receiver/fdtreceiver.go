@@ -1 +1,26 @@
 package receiver
+
+import (
+	"Flute_go/pkg/object"
+	"errors"
+	"time"
+)
+
+// ParseFdt 解析收齐（已解压）的 FDT-Instance
+// store 非 nil 时只接受签名有效的实例，只解析被签名的字节；签名验证失败时向 o 派发 EventFdtRejected
+func ParseFdt(o *ObserverList, tsi uint64, instanceID uint32, doc []byte, store *object.TrustStore, now time.Time) (object.FdtInstance, error) {
+	if store == nil {
+		return object.ParseFdtInstance(doc)
+	}
+	inst, err := object.ParseSignedFdtInstance(doc, store)
+	var sigErr *object.FdtSignatureError
+	if errors.As(err, &sigErr) && o != nil {
+		o.Dispatch(Event{
+			Kind:          EventFdtRejected,
+			Object:        ObjectInfo{TSI: tsi},
+			FdtInstanceID: instanceID,
+			Err:           sigErr,
+		}, now)
+	}
+	return inst, err
+}
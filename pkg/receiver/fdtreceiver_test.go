@@ -0,0 +1,55 @@
+package receiver
+
+import (
+	"Flute_go/pkg/object"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+)
+
+const testFdt = `<?xml version="1.0" encoding="UTF-8"?>
+<FDT-Instance Expires="4000000000">
+  <File Content-Location="file:///a.bin" TOI="1"/>
+</FDT-Instance>`
+
+// 签名无效或缺失的 FDT 被拒绝，并派发 EventFdtRejected
+func TestParseFdtRejected(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	signed, err := object.SignFdtInstance([]byte(testFdt), &object.FdtSigner{KeyID: "k1", Key: priv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := object.NewTrustStore()
+	store.Add("k1", pub)
+
+	obs := NewObserverList()
+	sub := NewChannelSubscriber(4)
+	obs.Subscribe(sub)
+	now := time.Now()
+
+	inst, err := ParseFdt(obs, 3, 9, signed, store, now)
+	if err != nil || inst.Signature == nil || len(inst.Files) != 1 {
+		t.Fatalf("valid FDT rejected: %v", err)
+	}
+	if _, err := ParseFdt(obs, 3, 9, []byte(testFdt), nil, now); err != nil {
+		t.Fatalf("unsigned FDT without trust store rejected: %v", err)
+	}
+	if _, err := ParseFdt(obs, 3, 10, []byte(testFdt), store, now); !errors.Is(err, object.ErrFdtUnsigned) {
+		t.Fatalf("expected ErrFdtUnsigned, got %v", err)
+	}
+
+	select {
+	case ce := <-sub.C:
+		var sigErr *object.FdtSignatureError
+		if ce.Event.Kind != EventFdtRejected || ce.Event.Object.TSI != 3 || ce.Event.FdtInstanceID != 10 ||
+			!errors.As(ce.Event.Err, &sigErr) {
+			t.Fatalf("unexpected event %+v", ce.Event)
+		}
+	default:
+		t.Fatal("no EventFdtRejected dispatched")
+	}
+	if len(sub.C) != 0 {
+		t.Fatal("valid FDTs must not dispatch events")
+	}
+}
@@ -0,0 +1,201 @@
+package receiver
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ObjectInfo 事件携带的对象信息
+type ObjectInfo struct {
+	TSI             uint64
+	Toi             string
+	ContentLocation string
+	ContentType     string
+	ContentLength   uint64
+	TransferLength  uint64
+
+	// 源符号进度（所有源块合计）
+	NbSourceSymbols         uint64
+	NbSourceSymbolsReceived uint64
+}
+
+// Progress 已收到（或 FEC 恢复）的源符号百分比 [0, 100]
+func (o ObjectInfo) Progress() float64 {
+	if o.NbSourceSymbols == 0 {
+		return 0
+	}
+	p := float64(o.NbSourceSymbolsReceived) * 100 / float64(o.NbSourceSymbols)
+	if p > 100 {
+		p = 100
+	}
+	return p
+}
+
+type EventKind int
+
+const (
+	// FDT 中出现新对象
+	EventObjectDiscovered EventKind = iota
+	// 收到对象的第一个数据包
+	EventReceptionStarted
+	// 源符号进度变化，见 ObjectInfo.Progress
+	EventProgress
+	// 源块借助修复符号恢复，见 Event.Sbn
+	EventBlockRecovered
+	EventObjectCompleted
+	// 解码/校验/写出失败，见 Event.Err
+	EventObjectFailed
+	// 收到 close-session 或会话超时
+	EventSessionClosed
+	// FDT-Instance 签名验证失败被丢弃，Event.Err 为 *object.FdtSignatureError
+	EventFdtRejected
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventObjectDiscovered:
+		return "ObjectDiscovered"
+	case EventReceptionStarted:
+		return "ReceptionStarted"
+	case EventProgress:
+		return "Progress"
+	case EventBlockRecovered:
+		return "BlockRecovered"
+	case EventObjectCompleted:
+		return "ObjectCompleted"
+	case EventObjectFailed:
+		return "ObjectFailed"
+	case EventSessionClosed:
+		return "SessionClosed"
+	case EventFdtRejected:
+		return "FdtRejected"
+	default:
+		return "Unknown"
+	}
+}
+
+type Event struct {
+	Kind EventKind
+	// EventSessionClosed/EventFdtRejected 时只有 TSI 有效
+	Object ObjectInfo
+
+	// EventFdtRejected
+	FdtInstanceID uint32
+
+	// EventBlockRecovered
+	Sbn uint32
+
+	// EventObjectFailed/EventFdtRejected
+	Err error
+}
+
+// 任何实现该接口的类型都可以订阅 Receiver 的事件（对应 sender.Subscriber）
+// 注意：本包尚无 ALC/FDT 接收流水线，目前只有 ParseFdt 派发 EventFdtRejected；
+// 在流水线接入之前，ObserverList/ProgressReporter 只能由调用方自行驱动
+type Subscriber interface {
+	OnReceiverEvent(evt Event, now time.Time)
+}
+
+// ObserverList 订阅者列表
+type ObserverList struct {
+	mu          sync.RWMutex
+	subscribers []Subscriber
+}
+
+func NewObserverList() *ObserverList {
+	return &ObserverList{
+		subscribers: make([]Subscriber, 0),
+	}
+}
+
+// Subscribe 添加订阅者
+func (o *ObserverList) Subscribe(s Subscriber) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.subscribers = append(o.subscribers, s)
+}
+
+// Unsubscribe 移除订阅者
+func (o *ObserverList) Unsubscribe(s Subscriber) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	newSubs := make([]Subscriber, 0, len(o.subscribers))
+	for _, sub := range o.subscribers {
+		if sub != s {
+			newSubs = append(newSubs, sub)
+		}
+	}
+	o.subscribers = newSubs
+}
+
+// Dispatch 派发事件
+func (o *ObserverList) Dispatch(evt Event, now time.Time) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	for _, sub := range o.subscribers {
+		sub.OnReceiverEvent(evt, now)
+	}
+}
+
+// ProgressReporter 按百分比步长节流 EventProgress，避免逐包回调；非并发安全，由接收循环单协程调用
+type ProgressReporter struct {
+	Step float64 // 百分比步长，<=0 表示每次变化都上报
+	last map[string]float64
+}
+
+func NewProgressReporter(step float64) *ProgressReporter {
+	return &ProgressReporter{Step: step, last: make(map[string]float64)}
+}
+
+// Report 进度达到下一个步长（或 100%）时派发 EventProgress，返回是否派发
+func (p *ProgressReporter) Report(o *ObserverList, info ObjectInfo, now time.Time) bool {
+	key := info.Toi
+	pct := info.Progress()
+	last, ok := p.last[key]
+	if ok && pct < 100 && pct-last < p.Step {
+		return false
+	}
+	if ok && pct == last {
+		return false
+	}
+	p.last[key] = pct
+	o.Dispatch(Event{Kind: EventProgress, Object: info}, now)
+	return true
+}
+
+// Forget 对象完成或失败后清理进度状态
+func (p *ProgressReporter) Forget(toi string) {
+	delete(p.last, toi)
+}
+
+// ChannelEvent 通过 channel 投递的事件
+type ChannelEvent struct {
+	Event Event
+	Now   time.Time
+}
+
+// ChannelSubscriber 把事件转发到带缓冲的 channel，缓冲满时丢弃并计数，不阻塞接收循环
+type ChannelSubscriber struct {
+	C       <-chan ChannelEvent
+	ch      chan ChannelEvent
+	dropped atomic.Uint64
+}
+
+func NewChannelSubscriber(buffer int) *ChannelSubscriber {
+	ch := make(chan ChannelEvent, buffer)
+	return &ChannelSubscriber{C: ch, ch: ch}
+}
+
+func (c *ChannelSubscriber) OnReceiverEvent(evt Event, now time.Time) {
+	select {
+	case c.ch <- ChannelEvent{Event: evt, Now: now}:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+// Dropped 因缓冲满而丢弃的事件数
+func (c *ChannelSubscriber) Dropped() uint64 {
+	return c.dropped.Load()
+}
@@ -0,0 +1,99 @@
+package receiver
+
+import (
+	"testing"
+	"time"
+)
+
+type recordSubscriber struct {
+	name string
+	log  *[]string
+}
+
+func (r recordSubscriber) OnReceiverEvent(evt Event, now time.Time) {
+	*r.log = append(*r.log, r.name+":"+evt.Kind.String())
+}
+
+func TestObserverDispatchOrder(t *testing.T) {
+	var log []string
+	obs := NewObserverList()
+	a, b, c := recordSubscriber{"a", &log}, recordSubscriber{"b", &log}, recordSubscriber{"c", &log}
+	obs.Subscribe(a)
+	obs.Subscribe(b)
+	obs.Subscribe(c)
+	now := time.Now()
+	obs.Dispatch(Event{Kind: EventObjectDiscovered}, now)
+	obs.Unsubscribe(b)
+	obs.Dispatch(Event{Kind: EventObjectCompleted}, now)
+
+	want := []string{
+		"a:ObjectDiscovered", "b:ObjectDiscovered", "c:ObjectDiscovered",
+		"a:ObjectCompleted", "c:ObjectCompleted",
+	}
+	if len(log) != len(want) {
+		t.Fatalf("unexpected dispatch %v", log)
+	}
+	for i := range want {
+		if log[i] != want[i] {
+			t.Fatalf("subscribers must be called in subscription order: %v", log)
+		}
+	}
+
+	// 缓冲满时丢弃而不阻塞
+	ch := NewChannelSubscriber(1)
+	obs.Subscribe(ch)
+	obs.Dispatch(Event{Kind: EventSessionClosed}, now)
+	obs.Dispatch(Event{Kind: EventSessionClosed}, now)
+	if ev := <-ch.C; ev.Event.Kind != EventSessionClosed || ch.Dropped() != 1 {
+		t.Fatalf("unexpected channel event %+v, dropped %d", ev, ch.Dropped())
+	}
+}
+
+func TestProgressReporter(t *testing.T) {
+	info := ObjectInfo{Toi: "1", NbSourceSymbols: 200}
+	if info.Progress() != 0 || (ObjectInfo{}).Progress() != 0 {
+		t.Fatalf("no symbols received means 0%%")
+	}
+	info.NbSourceSymbolsReceived = 50
+	if info.Progress() != 25 {
+		t.Fatalf("expected 25%%, got %v", info.Progress())
+	}
+	// 修复符号恢复可能让计数超过源符号数
+	info.NbSourceSymbolsReceived = 250
+	if info.Progress() != 100 {
+		t.Fatalf("progress must be capped at 100%%, got %v", info.Progress())
+	}
+
+	obs := NewObserverList()
+	ch := NewChannelSubscriber(16)
+	obs.Subscribe(ch)
+	p := NewProgressReporter(10)
+	now := time.Now()
+	var reported []float64
+	for _, n := range []uint64{2, 10, 20, 30, 38, 60, 199, 200, 200} {
+		info.NbSourceSymbolsReceived = n
+		if p.Report(obs, info, now) {
+			reported = append(reported, info.Progress())
+		}
+	}
+	// 首次（1%）上报；之后距上次上报满 10 个百分点才上报；100% 只上报一次
+	want := []float64{1, 15, 30, 99.5, 100}
+	if len(reported) != len(want) {
+		t.Fatalf("unexpected progress reports %v", reported)
+	}
+	for i := range want {
+		if reported[i] != want[i] {
+			t.Fatalf("unexpected progress reports %v", reported)
+		}
+	}
+	if len(ch.C) != len(want) {
+		t.Fatalf("each report must dispatch one EventProgress, got %d", len(ch.C))
+	}
+
+	// Forget 之后重新从头上报
+	p.Forget(info.Toi)
+	info.NbSourceSymbolsReceived = 4
+	if !p.Report(obs, info, now) {
+		t.Fatalf("first report after Forget must dispatch")
+	}
+}
@@ -1 +1,16 @@
 package receiver
+
+import (
+	"Flute_go/pkg/clock"
+	"log/slog"
+)
+
+// Config 接收端配置
+type Config struct {
+	// 结构化日志，nil 使用 slog.Default()；逐包/逐符号日志仅在 Debug 级别输出
+	// FEC 解码器通过 fec.RSGalois8Codec.SetLogger 注入（附加 tsi/toi 字段，sbn 写入 RSGalois8Codec.Sbn）
+	Logger *slog.Logger
+
+	// 时间源（对象/会话超时、FDT 过期、事件时间），nil 使用系统时钟；测试/仿真可注入 clock.Virtual
+	Clock clock.Clock
+}
@@ -1 +1,51 @@
 package receiver
+
+import (
+	"Flute_go/pkg/lct"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// NewDecompressReader 按 Content-Encoding 解压对象数据；CencNull 原样返回
+func NewDecompressReader(r io.Reader, cenc lct.Cenc) (io.ReadCloser, error) {
+	switch cenc {
+	case lct.CencNull:
+		return io.NopCloser(r), nil
+	case lct.CencZlib:
+		return zlib.NewReader(r)
+	case lct.CencDeflate:
+		return flate.NewReader(r), nil
+	case lct.CencGzip:
+		return gzip.NewReader(r)
+	case lct.CencZstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	case lct.CencBrotli:
+		return io.NopCloser(brotli.NewReader(r)), nil
+	default:
+		return nil, errors.New("unsupported compression type")
+	}
+}
+
+// Decompress 解压完整的对象数据
+func Decompress(data []byte, cenc lct.Cenc) ([]byte, error) {
+	if cenc == lct.CencNull {
+		return data, nil
+	}
+	r, err := NewDecompressReader(bytes.NewReader(data), cenc)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
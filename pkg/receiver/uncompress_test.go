@@ -0,0 +1,33 @@
+package receiver
+
+import (
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/sender"
+	"bytes"
+	"testing"
+)
+
+func TestDecompressRoundTrip(t *testing.T) {
+	content := bytes.Repeat([]byte(`{"manifest":"firmware","version":42}`), 500)
+	for _, cenc := range []lct.Cenc{lct.CencZlib, lct.CencDeflate, lct.CencGzip, lct.CencZstd, lct.CencBrotli} {
+		compressed, err := sender.CompressBuffer(content, cenc)
+		if err != nil {
+			t.Fatalf("%s: CompressBuffer failed: %v", cenc, err)
+		}
+		var streamed bytes.Buffer
+		if err := sender.CompressStream(bytes.NewReader(content), cenc, &streamed); err != nil {
+			t.Fatalf("%s: CompressStream failed: %v", cenc, err)
+		}
+		for _, data := range [][]byte{compressed, streamed.Bytes()} {
+			got, err := Decompress(data, cenc)
+			if err != nil || !bytes.Equal(got, content) {
+				t.Fatalf("%s: round trip failed: %v", cenc, err)
+			}
+		}
+
+		parsed, err := lct.CencFromContentEncoding(cenc.ContentEncoding())
+		if err != nil || parsed != cenc {
+			t.Fatalf("%s: Content-Encoding %q does not parse back", cenc, cenc.ContentEncoding())
+		}
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"Flute_go/pkg/oti"
 	"Flute_go/pkg/tools"
 	"errors"
-	"log"
+	"log/slog"
 )
 
 type Block struct {
@@ -27,11 +27,17 @@ func NewBlockFromBuffer(
 	buffer []byte,
 	blockLength uint64,
 	o *oti.Oti,
+	logger *slog.Logger,
 ) (*Block, error) {
 	nbSourceSymbols := uint(tools.DivCeil(uint64(len(buffer)), uint64(o.EncodingSymbolLength)))
 
-	log.Printf("buffer_len=%d nb_source_symbols=%d encoding_symbol_length=%d",
-		len(buffer), nbSourceSymbols, o.EncodingSymbolLength)
+	// 每块都会调用：仅在开启 Debug 时才派生带 sbn 的 logger，Warn 日志由编码器按 Sbn 字段附加
+	logger = tools.LoggerOrDefault(logger)
+	if tools.DebugEnabled(logger) {
+		logger = logger.With("sbn", sbn)
+		logger.Debug("new block", "buffer_len", len(buffer),
+			"nb_source_symbols", nbSourceSymbols, "encoding_symbol_length", o.EncodingSymbolLength)
+	}
 
 	var shards []fec.FecShard
 	var err error
@@ -41,7 +47,7 @@ func NewBlockFromBuffer(
 		shards = createShardsNoCode(o, buffer)
 
 	case oti.ReedSolomonGF28, oti.ReedSolomonGF28UnderSpecified, oti.ReedSolomonGF2M:
-		shards, err = createShardsReedSolomonGF8(o, int(nbSourceSymbols), int(blockLength), buffer)
+		shards, err = createShardsReedSolomonGF8(o, sbn, int(nbSourceSymbols), int(blockLength), buffer, logger)
 		if err != nil {
 			return nil, err
 		}
@@ -102,7 +108,7 @@ func createShardsNoCode(o *oti.Oti, buffer []byte) []fec.FecShard {
 }
 
 // Reed-Solomon GF(2^8) 分片
-func createShardsReedSolomonGF8(o *oti.Oti, nbSourceSymbols, blockLength int, buffer []byte) ([]fec.FecShard, error) {
+func createShardsReedSolomonGF8(o *oti.Oti, sbn uint32, nbSourceSymbols, blockLength int, buffer []byte, logger *slog.Logger) ([]fec.FecShard, error) {
 	if nbSourceSymbols > int(o.MaximumSourceBlockLength) {
 		return nil, errors.New("nbSourceSymbols exceeds MaximumSourceBlockLength")
 	}
@@ -113,5 +119,7 @@ func createShardsReedSolomonGF8(o *oti.Oti, nbSourceSymbols, blockLength int, bu
 	if err != nil {
 		return nil, err
 	}
+	encoder.Sbn = sbn
+	encoder.SetLogger(logger)
 	return encoder.Encode(buffer)
 }
@@ -2,10 +2,11 @@ package sender
 
 import (
 	"Flute_go/pkg/object"
+	"Flute_go/pkg/tools"
 	"context"
 	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"sync"
 )
 
@@ -35,9 +36,39 @@ type BlockEncoder struct {
 
 	// 内部互斥：若 Read() 仅被单协程调用，可不必使用；保守起见加上
 	mu sync.Mutex
+
+	logger *slog.Logger
+
+	// 已编码但尚未上报的块，由 SenderSession 取走后派发 EventBlockEncoded
+	encodedBlocks []encodedBlock
+}
+
+type encodedBlock struct {
+	sbn               uint32
+	nbSourceSymbols   uint
+	nbEncodingSymbols uint
 }
 
-func NewBlockEncoder(file *FileDesc, blockMultiplexWindows int, closableObject bool) (*BlockEncoder, error) {
+// takeEncodedBlocks 取走并清空已编码块列表
+func (b *BlockEncoder) takeEncodedBlocks() []encodedBlock {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := b.encodedBlocks
+	b.encodedBlocks = nil
+	return out
+}
+
+func (b *BlockEncoder) appendBlock(blk *Block) {
+	b.blocks = append(b.blocks, blk)
+	b.encodedBlocks = append(b.encodedBlocks, encodedBlock{
+		sbn:               blk.sbn,
+		nbSourceSymbols:   blk.NbSourceSymbols,
+		nbEncodingSymbols: uint(len(blk.shards)),
+	})
+}
+
+// logger 为 nil 时使用 slog.Default()，内部会附加 toi 字段
+func NewBlockEncoder(file *FileDesc, blockMultiplexWindows int, closableObject bool, logger *slog.Logger) (*BlockEncoder, error) {
 	// 对齐 Rust：当数据源为 Stream 时 seek 到起点
 	switch file.Object.Source.Choice {
 	case DataBuffer:
@@ -73,6 +104,7 @@ func NewBlockEncoder(file *FileDesc, blockMultiplexWindows int, closableObject b
 		nbPktSent:             0,
 		stopped:               false,
 		closableObject:        closableObject,
+		logger:                tools.LoggerOrDefault(logger).With("toi", file.TOI.String()),
 	}
 
 	be.blockPartitioning()
@@ -102,17 +134,18 @@ func (b *BlockEncoder) Read(forceCloseObject bool) (*object.Pkt, error) {
 	for {
 		if err := b.readWindow(context.Background()); err != nil {
 			// handle error —— 这里记录并让窗口自然耗尽
-			log.Printf("block encoder: readWindow failed: %v", err)
+			b.logger.Error("block encoder: read window failed", "err", err)
 			b.readEnd = true
 		}
 
 		if len(b.blocks) == 0 {
 			if b.nbPktSent == 0 {
 				// 空文件：发送带 close_object 的空包
-				log.Printf("Empty file ? Send a pkt containing close-object flag")
+				b.logger.Debug("empty object, send a packet with close-object flag")
 				b.nbPktSent++
 				if b.file.Object.TransferLength != 0 {
-					log.Printf("warn: transfer_length != 0 while sending empty close pkt")
+					b.logger.Warn("transfer length is not 0 while sending empty close packet",
+						"transfer_length", b.file.Object.TransferLength)
 				}
 				return &object.Pkt{
 					Payload:           nil,
@@ -224,11 +257,11 @@ func (b *BlockEncoder) readBlockBuffer() error {
 	}
 
 	slice := content[offsetStart:offsetEnd]
-	blk, err := NewBlockFromBuffer(b.currSBN, slice, blockLen, oti)
+	blk, err := NewBlockFromBuffer(b.currSBN, slice, blockLen, oti, b.logger)
 	if err != nil {
 		return err
 	}
-	b.blocks = append(b.blocks, blk)
+	b.appendBlock(blk)
 	b.currSBN++
 	b.readEnd = (offsetEnd == len(content))
 	b.currContentOffset = uint64(offsetEnd)
@@ -265,11 +298,11 @@ func (b *BlockEncoder) readBlockStream() error {
 	}
 	buf = buf[:n]
 
-	blk, err := NewBlockFromBuffer(b.currSBN, buf, blockLen, oti)
+	blk, err := NewBlockFromBuffer(b.currSBN, buf, blockLen, oti, b.logger)
 	if err != nil {
 		return err
 	}
-	b.blocks = append(b.blocks, blk)
+	b.appendBlock(blk)
 	b.currSBN++
 	b.currContentOffset += uint64(len(buf))
 	return nil
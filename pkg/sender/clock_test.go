@@ -0,0 +1,68 @@
+package sender
+
+import (
+	"Flute_go/pkg/alc"
+	"Flute_go/pkg/clock"
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/transport"
+	"testing"
+	"time"
+)
+
+// 虚拟时钟下一小时的 TargetAcquisition.WithinTime 在毫秒内跑完
+func TestSenderVirtualClock(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	clk := clock.NewVirtual(start)
+	cfg := DefaultConfig()
+	cfg.Clock = clk
+	o := oti.NewNoCode(1024, 64)
+	endpoint := transport.NewUDPEndpoint(nil, "224.0.0.1", 1234)
+	s := NewSender(endpoint, 1, o, &cfg)
+
+	obj := createObj(10 * 1024)
+	obj.TargetAcquisition = &TargetAcquisition{Choice: WithinTime, At: start.Add(time.Hour)}
+	toi, err := s.AddObject(0, obj)
+	if err != nil {
+		t.Fatalf("AddObject failed: %v", err)
+	}
+	if err := s.Publish(s.Now()); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	var sentAt []time.Time
+	for s.IsAdded(toi) && clk.Now().Before(start.Add(2*time.Hour)) {
+		data := s.Read(s.Now())
+		if data == nil {
+			clk.Advance(time.Second)
+			continue
+		}
+		pkt, err := alc.ParseAlcPkt(data)
+		if err != nil {
+			t.Fatalf("ParseAlcPkt failed: %v", err)
+		}
+		if pkt.Lct.Toi != lct.TOI_FDT {
+			sentAt = append(sentAt, clk.Now())
+		}
+	}
+	if len(sentAt) != 10 {
+		t.Fatalf("expected 10 data packets, got %d", len(sentAt))
+	}
+	if first, last := sentAt[0], sentAt[len(sentAt)-1]; !first.Equal(start) ||
+		last.Before(start.Add(50*time.Minute)) || last.After(start.Add(time.Hour)) {
+		t.Fatalf("packets not spread over the hour: first %s, last %s", first, last)
+	}
+	if s.IsAdded(toi) {
+		t.Fatalf("object must expire after its transfer")
+	}
+}
+
+// 随机初始 TOI 由注入的时钟播种，虚拟时间下可复现
+func TestToiAllocatorVirtualClock(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	a := NewToiAllocator(ToiMax112, nil, clock.NewVirtual(start), nil)
+	b := NewToiAllocator(ToiMax112, nil, clock.NewVirtual(start), nil)
+	if a.Allocate().String() != b.Allocate().String() {
+		t.Fatalf("TOI allocation must be reproducible under a virtual clock")
+	}
+}
@@ -5,10 +5,19 @@ import (
 	"compress/flate"
 	"compress/gzip"
 	"compress/zlib"
+	"crypto/md5"
+	"encoding/base64"
 	"errors"
 	"io"
+	"os"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 )
 
+// SpoolDir 不缓存到内存的压缩对象写入的临时目录（空 = os.TempDir()）
+var SpoolDir = ""
+
 // CompressBuffer 压缩内存数据
 func CompressBuffer(data []byte, cenc lct.Cenc) ([]byte, error) {
 	switch cenc {
@@ -20,6 +29,10 @@ func CompressBuffer(data []byte, cenc lct.Cenc) ([]byte, error) {
 		return compressDeflate(data)
 	case lct.CencGzip:
 		return compressGzip(data)
+	case lct.CencZstd:
+		return compressZstd(data)
+	case lct.CencBrotli:
+		return compressBrotli(data)
 	default:
 		return nil, errors.New("unsupported compression type")
 	}
@@ -36,6 +49,10 @@ func CompressStream(input io.Reader, cenc lct.Cenc, output io.Writer) error {
 		return streamCompressDeflate(input, output)
 	case lct.CencGzip:
 		return streamCompressGzip(input, output)
+	case lct.CencZstd:
+		return streamCompressZstd(input, output)
+	case lct.CencBrotli:
+		return copyAndClose(brotli.NewWriterLevel(output, brotli.DefaultCompression), input)
 	default:
 		return errors.New("unsupported compression type")
 	}
@@ -91,20 +108,43 @@ func compressZlib(data []byte) ([]byte, error) {
 	return buf, nil
 }
 
+func compressZstd(data []byte) ([]byte, error) {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Close()
+	return enc.EncodeAll(data, nil), nil
+}
+
+func compressBrotli(data []byte) ([]byte, error) {
+	var buf []byte
+	w := brotli.NewWriterLevel(NewBufferWriter(&buf), brotli.DefaultCompression)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
 // 内部实现：Stream 压缩
 
+func streamCompressZstd(input io.Reader, output io.Writer) error {
+	w, err := zstd.NewWriter(output)
+	if err != nil {
+		return err
+	}
+	return copyAndClose(w, input)
+}
+
 func streamCompressGzip(input io.Reader, output io.Writer) error {
-	w := gzip.NewWriter(output)
-	defer w.Close()
-	_, err := io.Copy(w, input)
-	return err
+	return copyAndClose(gzip.NewWriter(output), input)
 }
 
 func streamCompressZlib(input io.Reader, output io.Writer) error {
-	w := zlib.NewWriter(output)
-	defer w.Close()
-	_, err := io.Copy(w, input)
-	return err
+	return copyAndClose(zlib.NewWriter(output), input)
 }
 
 func streamCompressDeflate(input io.Reader, output io.Writer) error {
@@ -112,9 +152,70 @@ func streamCompressDeflate(input io.Reader, output io.Writer) error {
 	if err != nil {
 		return err
 	}
-	defer w.Close()
-	_, err = io.Copy(w, input)
-	return err
+	return copyAndClose(w, input)
+}
+
+// copyAndClose Close 会写出尾部，错误不能忽略
+func copyAndClose(w io.WriteCloser, input io.Reader) error {
+	if _, err := io.Copy(w, input); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// spoolCompressed 把 input 压缩（CencNull 时原样复制）到 SpoolDir 下的临时文件，返回定位在开头的文件、
+// 压缩后长度、原始长度以及原始内容的 MD5（base64，withMD5 为 false 时为 nil）
+// 临时文件创建后立即删除目录项，随文件句柄关闭释放（Windows 上删除失败则保留到进程外清理）
+func spoolCompressed(input io.Reader, cenc lct.Cenc, withMD5 bool) (*os.File, uint64, uint64, *string, error) {
+	tmp, err := os.CreateTemp(SpoolDir, "flute-spool-*")
+	if err != nil {
+		return nil, 0, 0, nil, err
+	}
+	_ = os.Remove(tmp.Name())
+
+	h := md5.New()
+	counter := &countingReader{r: input}
+	var src io.Reader = counter
+	if withMD5 {
+		src = io.TeeReader(counter, h)
+	}
+	if cenc == lct.CencNull {
+		_, err = io.Copy(tmp, src)
+	} else {
+		err = CompressStream(src, cenc, tmp)
+	}
+	if err != nil {
+		tmp.Close()
+		return nil, 0, 0, nil, err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		tmp.Close()
+		return nil, 0, 0, nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		tmp.Close()
+		return nil, 0, 0, nil, err
+	}
+
+	var md5b64 *string
+	if withMD5 {
+		s := base64.StdEncoding.EncodeToString(h.Sum(nil))
+		md5b64 = &s
+	}
+	return tmp, uint64(size), counter.n, md5b64, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n uint64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += uint64(n)
+	return n, err
 }
 
 // 小工具：把 []byte 当作 Writer
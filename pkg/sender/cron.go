@@ -0,0 +1,125 @@
+package sender
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSpec 五段式 cron 表达式："分 时 日 月 周"
+// 支持 *、数字、a-b、a,b、*/n、a-b/n；周日为 0 或 7
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64 // 位图
+	domStar, dowStar              bool
+}
+
+func parseCron(expr string) (*cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: expected 5 fields", expr)
+	}
+	var c cronSpec
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("cron %q minute: %w", expr, err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("cron %q hour: %w", expr, err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("cron %q day of month: %w", expr, err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("cron %q month: %w", expr, err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("cron %q day of week: %w", expr, err)
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = fields[2] == "*"
+	c.dowStar = fields[4] == "*"
+	return &c, nil
+}
+
+func parseCronField(f string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(f, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			step = n
+			part = part[:i]
+		}
+		lo, hi := min, max
+		if part != "*" {
+			if i := strings.IndexByte(part, '-'); i >= 0 {
+				a, err1 := strconv.Atoi(part[:i])
+				b, err2 := strconv.Atoi(part[i+1:])
+				if err1 != nil || err2 != nil {
+					return 0, fmt.Errorf("invalid range %q", part)
+				}
+				lo, hi = a, b
+			} else {
+				v, err := strconv.Atoi(part)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+				lo, hi = v, v
+				if step > 1 {
+					hi = max
+				}
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value out of range %d-%d", min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c *cronSpec) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	// 与 cron 一致：日与周都受限时满足其一即可
+	if !c.domStar && !c.dowStar {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// nextAtOrAfter 返回 >= t（按分钟取整向上）的第一个匹配时刻，5 年内无匹配返回零值
+func (c *cronSpec) nextAtOrAfter(t time.Time) time.Time {
+	if t.Second() != 0 || t.Nanosecond() != 0 {
+		t = t.Truncate(time.Minute).Add(time.Minute)
+	}
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,79 @@
+package sender
+
+import (
+	"Flute_go/pkg/object"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// encryptObject 在 FEC 编码前加密对象的传输表示（压缩之后的数据）
+// Buffer 直接加密；Stream 使用 AES-CTR 时按需加密不读入内存，AES-GCM 需整体读入
+func encryptObject(obj *ObjectDesc, provider object.KeyProvider) error {
+	if obj.ContentKeyID == nil || obj.encrypted {
+		return nil
+	}
+	if obj.Encryption == object.EncryptionNone {
+		return errors.New("content key ID set without encryption scheme")
+	}
+	if provider == nil {
+		return errors.New("object requires encryption but no key provider is configured")
+	}
+	key, err := provider.ContentKey(*obj.ContentKeyID)
+	if err != nil {
+		return err
+	}
+	if obj.EncryptionIV == nil {
+		iv, err := object.NewIV(obj.Encryption)
+		if err != nil {
+			return err
+		}
+		obj.EncryptionIV = iv
+	}
+	if len(obj.EncryptionIV) != obj.Encryption.IVLength() {
+		return fmt.Errorf("wrong IV length %d for %s", len(obj.EncryptionIV), obj.Encryption)
+	}
+
+	src := &obj.Source
+	switch src.Choice {
+	case DataBuffer:
+		data, err := object.EncryptContent(obj.Encryption, key, obj.EncryptionIV, src.buffer)
+		if err != nil {
+			return err
+		}
+		src.buffer = data
+
+	case DataStream:
+		src.streamMu.Lock()
+		defer src.streamMu.Unlock()
+		if _, err := src.stream.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if obj.Encryption == object.EncryptionAESCTR {
+			rs, err := object.NewCTRReadSeeker(key, obj.EncryptionIV, src.stream)
+			if err != nil {
+				return err
+			}
+			src.stream = rs
+		} else {
+			plain, err := io.ReadAll(src.stream)
+			if err != nil {
+				return err
+			}
+			data, err := object.EncryptContent(obj.Encryption, key, obj.EncryptionIV, plain)
+			if err != nil {
+				return err
+			}
+			src.Choice = DataBuffer
+			src.buffer = data
+			src.stream = nil
+		}
+
+	default:
+		return errors.New("unknown data source")
+	}
+
+	obj.TransferLength += uint64(obj.Encryption.Overhead())
+	obj.encrypted = true
+	return nil
+}
@@ -1,6 +1,7 @@
 package sender
 
 import (
+	"Flute_go/pkg/clock"
 	"Flute_go/pkg/lct"
 	"Flute_go/pkg/object"
 	"Flute_go/pkg/oti"
@@ -10,6 +11,7 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/url"
 	"sort"
 	"time"
@@ -22,6 +24,12 @@ type Fdt struct {
 	filesTransferQueue []*FileDesc // VecDeque<Arc<FileDesc>>
 	fdtTransferQueue   []*FileDesc
 	files              map[string]*FileDesc // HashMap<u128, Arc<FileDesc>> —— 用一个包装 key
+	draining           map[string]*FileDesc // 被 UpdateObject 替换、仍在完成本轮传输的旧对象
+
+	// 持久化（可选），见 StateStore
+	state              StateStore
+	restored           map[string]ObjectState // TOI -> 重启前的对象，等待重新加入（同一 Content-Location 可有多个）
+	logger             *slog.Logger
 	currentFdtTransfer *FileDesc
 	complete           *bool
 
@@ -37,6 +45,8 @@ type Fdt struct {
 
 	toiAllocator *ToiAllocator
 	publishMode  FDTPublishMode
+	signer       *object.FdtSigner
+	clock        clock.Clock
 }
 
 func NewFdt(
@@ -52,7 +62,15 @@ func NewFdt(
 	toiInitialValue *t.Uint128,
 	groups *[]string,
 	publishMode FDTPublishMode,
-) *Fdt {
+	signer *object.FdtSigner,
+	clk clock.Clock,
+	logger *slog.Logger, // nil 使用 slog.Default()
+) (*Fdt, error) {
+	if err := checkFdtCenc(cenc); err != nil {
+		return nil, err
+	}
+	clk = clock.OrReal(clk)
+	logger = tools.LoggerOrDefault(logger)
 	return &Fdt{
 		tsi:                tsi,
 		fdtID:              fdtID,
@@ -60,6 +78,7 @@ func NewFdt(
 		filesTransferQueue: make([]*FileDesc, 0),
 		fdtTransferQueue:   make([]*FileDesc, 0),
 		files:              make(map[string]*FileDesc),
+		draining:           make(map[string]*FileDesc),
 		currentFdtTransfer: nil,
 		complete:           nil,
 		cenc:               cenc,
@@ -69,9 +88,20 @@ func NewFdt(
 		lastPublish:        nil,
 		observers:          observers,
 		groups:             groups,
-		toiAllocator:       NewToiAllocator(toiMaxLength, toiInitialValue),
+		toiAllocator:       NewToiAllocator(toiMaxLength, toiInitialValue, clk, logger),
 		publishMode:        publishMode,
+		signer:             signer,
+		clock:              clk,
+		logger:             logger,
+	}, nil
+}
+
+// checkFdtCenc FDT 的编码只能通过 EXT_CENC 告知接收端（NewFdt、ValidateConfig 共用）
+func checkFdtCenc(cenc lct.Cenc) error {
+	if !cenc.InbandCapable() {
+		return fmt.Errorf("FDT content encoding %s has no EXT_CENC value, receivers could not decode the FDT", cenc)
 	}
+	return nil
 }
 
 // 构建 FDT-Instance
@@ -151,13 +181,16 @@ func (f *Fdt) GetFilesBeingTransferred() []*FileDesc {
 }
 
 func (f *Fdt) AllocateToi() *Toi {
-	return f.toiAllocator.Allocate()
+	toi := f.toiAllocator.Allocate()
+	f.record(StateRecord{Op: StateOpToi, NextToi: f.toiAllocator.Next().String()})
+	return toi
 }
 
 func (f *Fdt) AddObject(priority uint32, obj *ObjectDesc) (string, error) {
 	if f.complete != nil && *f.complete {
 		return "", errors.New("FDT is complete, no new object should be added")
 	}
+	prev := f.claimRestored(obj)
 	if obj.Toi == nil || obj.Toi.String() == "" {
 		obj.SetToi(f.AllocateToi())
 	}
@@ -165,15 +198,82 @@ func (f *Fdt) AddObject(priority uint32, obj *ObjectDesc) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if prev != nil && prev.Toi == fd.TOI.String() {
+		fd.transferInfo.totalNbTransfer = prev.TotalTransfers
+	}
 	toi := fd.TOI
 	if _, dup := f.files[toi.String()]; dup {
 		return "", errors.New("duplicate TOI in FDT")
 	}
 	f.files[toi.String()] = fd
 	f.filesTransferQueue = append(f.filesTransferQueue, fd)
+	st := objectState(fd)
+	f.record(StateRecord{Op: StateOpObject, Object: &st})
+	f.observers.Dispatch(Event{Kind: EventObjectAdded, File: fd.Info()}, f.clock.Now())
 	return toi.String(), nil
 }
 
+// UpdateObject 以新 TOI 替换同一 Content-Location 的对象：新对象加入 FDT，
+// 旧对象立即移出 FDT；若旧对象正在传输，则继续发送到本轮结束（draining）
+// 版本号在旧对象基础上递增，未指定 ETag 时使用 Content-MD5
+// 返回新 TOI 和被替换的旧 TOI（不存在时为空串）
+func (f *Fdt) UpdateObject(priority uint32, obj *ObjectDesc) (string, string, error) {
+	var old *FileDesc
+	if obj.ContentLocation != nil {
+		cl := obj.ContentLocation.String()
+		for _, fd := range f.files {
+			if fd.Object.ContentLocation != nil && fd.Object.ContentLocation.String() == cl {
+				old = fd
+				break
+			}
+		}
+	}
+
+	if old != nil && obj.ContentVersion == nil {
+		var v uint32
+		if old.Object.ContentVersion != nil {
+			v = *old.Object.ContentVersion
+		}
+		v++
+		obj.ContentVersion = &v
+	}
+	if obj.ETag == nil && obj.MD5 != nil {
+		etag := *obj.MD5
+		obj.ETag = &etag
+	}
+
+	toi, err := f.AddObject(priority, obj)
+	if err != nil {
+		return "", "", err
+	}
+	if old == nil {
+		return toi, "", nil
+	}
+
+	oldToi := old.TOI.String()
+	if old.IsTransferring() {
+		f.draining[oldToi] = old
+	}
+	f.RemoveObject(oldToi)
+	return toi, oldToi, nil
+}
+
+// IsDraining 已被 UpdateObject 替换、但仍在完成本轮传输的旧对象
+func (f *Fdt) IsDraining(toi string) bool {
+	_, ok := f.draining[toi]
+	return ok
+}
+
+// SetTransferWindow 为对象打开传输窗口，见 FileDesc.SetTransferWindow
+func (f *Fdt) SetTransferWindow(toi string, start time.Time, end *time.Time) bool {
+	fd, ok := f.files[toi]
+	if !ok {
+		return false
+	}
+	fd.SetTransferWindow(start, end)
+	return true
+}
+
 func (f *Fdt) TriggerTransferAt(toi string, ts *time.Time) bool {
 	fd, ok := f.files[toi]
 	if !ok {
@@ -200,10 +300,17 @@ func (f *Fdt) IsAdded(toi string) bool {
 }
 
 func (f *Fdt) RemoveObject(toi string) bool {
-	if _, ok := f.files[toi]; !ok {
+	fd, ok := f.files[toi]
+	if !ok {
 		return false
 	}
 	delete(f.files, toi)
+	f.record(StateRecord{Op: StateOpRemove, Toi: toi})
+	f.observers.Dispatch(Event{Kind: EventObjectRemoved, File: fd.Info()}, f.clock.Now())
+	// 正在传输（含 draining）的对象在 TransferDone 时释放
+	if !fd.IsTransferring() {
+		f.release(fd)
+	}
 	dst := f.filesTransferQueue[:0]
 	for _, fd := range f.filesTransferQueue {
 		if fd.TOI.String() != toi {
@@ -228,7 +335,11 @@ func (f *Fdt) NbObjects() int {
 }
 
 func (f *Fdt) Publish(now time.Time) error {
-	buf, err := f.ToXML(now)
+	inst, err := f.getFdtInstance(now)
+	if err != nil {
+		return err
+	}
+	buf, err := f.marshal(inst)
 	if err != nil {
 		return err
 	}
@@ -258,8 +369,14 @@ func (f *Fdt) Publish(now time.Time) error {
 	}
 	fd.SetPublished()
 	f.fdtTransferQueue = append(f.fdtTransferQueue, fd)
+	f.observers.Dispatch(Event{
+		Kind:          EventFDTPublished,
+		FdtInstanceID: f.fdtID,
+		NbFiles:       len(inst.Files),
+	}, now)
 
 	f.fdtID = (f.fdtID + 1) & 0xFFFFF
+	f.record(StateRecord{Op: StateOpFdt, NextFdtID: f.fdtID})
 	nowCopy := now
 	f.lastPublish = &nowCopy
 
@@ -324,14 +441,11 @@ func (f *Fdt) GetNextFileTransfer(priority uint32, now time.Time) *FileDesc {
 	copy(f.filesTransferQueue[idx:], f.filesTransferQueue[idx+1:])
 	f.filesTransferQueue = f.filesTransferQueue[:len(f.filesTransferQueue)-1]
 
-	// 事件：开始传输
-	f.observers.Dispatch(Event{
-		Kind: EventStartTransfer,
-		File: FileInfo{Toi: fd.TOI.String()},
-	}, now)
-
 	fd.TransferStarted(now)
 
+	// 事件：开始传输
+	f.observers.Dispatch(Event{Kind: EventStartTransfer, File: fd.Info()}, now)
+
 	if f.publishMode == ObjectsBeingTransferred {
 		_ = f.Publish(now)
 	}
@@ -349,26 +463,37 @@ func (f *Fdt) TransferDone(fd *FileDesc, now time.Time) {
 	}
 
 	// 普通文件的 stop 事件
-	f.observers.Dispatch(Event{
-		Kind: EventStopTransfer,
-		File: FileInfo{Toi: fd.TOI.String()},
-	}, now)
+	f.observers.Dispatch(Event{Kind: EventStopTransfer, File: fd.Info()}, now)
+	delete(f.draining, fd.TOI.String())
 
 	if _, ok := f.files[fd.TOI.String()]; !ok {
 		// 已被移除
+		f.release(fd)
 		return
 	}
+	f.record(StateRecord{Op: StateOpTransfer, Toi: fd.TOI.String(), Transfers: fd.TotalNbTransfer()})
 	if !fd.IsExpired() {
 		// 继续轮播
 		f.filesTransferQueue = append(f.filesTransferQueue, fd)
+		f.observers.Dispatch(Event{Kind: EventCarouselCycleCompleted, File: fd.Info()}, now)
 		return
 	}
 	// 过期则从 FDT 中移除
 	delete(f.files, fd.TOI.String())
+	f.record(StateRecord{Op: StateOpRemove, Toi: fd.TOI.String()})
+	f.observers.Dispatch(Event{Kind: EventObjectExpired, File: fd.Info()}, now)
+	f.release(fd)
 	// 可选：自动 publish
 	// _ = f.Publish(now)
 }
 
+// release 对象不再发送：关闭其数据源打开的文件
+func (f *Fdt) release(fd *FileDesc) {
+	if err := fd.Object.Source.Close(); err != nil {
+		f.logger.Warn("fail to close object source", "toi", fd.TOI.String(), "err", err)
+	}
+}
+
 func (f *Fdt) SetComplete() {
 	v := true
 	f.complete = &v
@@ -380,6 +505,11 @@ func (f *Fdt) ToXML(now time.Time) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	return f.marshal(inst)
+}
+
+// marshal 序列化 FDT-Instance（带 XML 声明，按需签名）
+func (f *Fdt) marshal(inst *object.FdtInstance) ([]byte, error) {
 	out, err := xml.MarshalIndent(inst, "", "  ")
 	if err != nil {
 		return nil, err
@@ -388,9 +518,137 @@ func (f *Fdt) ToXML(now time.Time) ([]byte, error) {
 	var buf bytes.Buffer
 	buf.WriteString(xml.Header) // <?xml version="1.0" encoding="UTF-8"?>
 	buf.Write(out)
+	if f.signer != nil {
+		return object.SignFdtInstance(buf.Bytes(), f.signer)
+	}
 	return buf.Bytes(), nil
 }
 
+// ------- 持久化 -------
+
+func objectState(fd *FileDesc) ObjectState {
+	var cl string
+	if fd.Object.ContentLocation != nil {
+		cl = fd.Object.ContentLocation.String()
+	}
+	return ObjectState{
+		Toi:             fd.TOI.String(),
+		ContentLocation: cl,
+		Priority:        fd.Priority,
+		ContentLength:   fd.Object.ContentLength,
+		MD5:             fd.Object.MD5,
+		ETag:            fd.Object.ETag,
+		ContentVersion:  fd.Object.ContentVersion,
+		TotalTransfers:  fd.TotalNbTransfer(),
+	}
+}
+
+func (f *Fdt) record(r StateRecord) {
+	if f.state == nil {
+		return
+	}
+	if err := f.state.Record(r); err != nil {
+		f.logger.Error("fail to persist sender state", "op", r.Op, "err", err)
+	}
+}
+
+// restore 从持久化状态恢复 TOI 分配、FDT-Instance ID 与对象目录
+func (f *Fdt) restore(st *SenderState) {
+	reserved := make([]t.Uint128, 0, len(st.Objects))
+	f.restored = make(map[string]ObjectState, len(st.Objects))
+	for toi, o := range st.Objects {
+		reserved = append(reserved, t.StringToUint128(toi))
+		f.restored[toi] = o
+	}
+	if st.NextToi != "" {
+		f.toiAllocator.Restore(t.StringToUint128(st.NextToi), reserved)
+	}
+	// 回绕后 0 也是有效的 ID
+	f.fdtID = st.NextFdtID
+}
+
+// snapshot 当前完整状态（仍未重新加入的旧对象继续保留，避免 TOI 被复用）
+func (f *Fdt) snapshot() *SenderState {
+	st := newSenderState(f.tsi)
+	st.NextToi = f.toiAllocator.Next().String()
+	st.NextFdtID = f.fdtID
+	for _, o := range f.restored {
+		st.Objects[o.Toi] = o
+	}
+	for _, fd := range f.files {
+		st.Objects[fd.TOI.String()] = objectState(fd)
+	}
+	return st
+}
+
+// claimRestored 对象重新加入时匹配重启前的同 Content-Location 对象（有多个时按 TOI 顺序取一个）：
+// 内容相同（长度一致且 MD5 或 ETag 相同）则沿用旧 TOI 与传输次数；
+// 内容不同则视为新版本（Content-Version 递增），旧 TOI 作废
+func (f *Fdt) claimRestored(obj *ObjectDesc) *ObjectState {
+	if len(f.restored) == 0 || obj.ContentLocation == nil || obj.Toi != nil {
+		return nil
+	}
+	cl := obj.ContentLocation.String()
+	var candidates []ObjectState
+	for _, o := range f.restored {
+		if o.ContentLocation == cl {
+			candidates = append(candidates, o)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	// TOI 为定长十六进制，按字符串排序即按数值排序
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Toi < candidates[j].Toi })
+
+	for _, prev := range candidates {
+		same := prev.ContentLength == obj.ContentLength &&
+			((prev.MD5 != nil && obj.MD5 != nil && *prev.MD5 == *obj.MD5) ||
+				(prev.ETag != nil && obj.ETag != nil && *prev.ETag == *obj.ETag))
+		if !same {
+			continue
+		}
+		delete(f.restored, prev.Toi)
+		obj.SetToi(f.toiAllocator.reserved(t.StringToUint128(prev.Toi)))
+		if obj.ContentVersion == nil {
+			obj.ContentVersion = prev.ContentVersion
+		}
+		if obj.ETag == nil {
+			obj.ETag = prev.ETag
+		}
+		return &prev
+	}
+
+	prev := candidates[0]
+	delete(f.restored, prev.Toi)
+	if obj.ContentVersion == nil {
+		var v uint32
+		if prev.ContentVersion != nil {
+			v = *prev.ContentVersion
+		}
+		v++
+		obj.ContentVersion = &v
+	}
+	f.toiAllocator.Release(t.StringToUint128(prev.Toi))
+	f.record(StateRecord{Op: StateOpRemove, Toi: prev.Toi})
+	return &prev
+}
+
+// RestoredObjects 重启前存在、尚未重新加入的对象
+func (f *Fdt) RestoredObjects() []ObjectState {
+	out := make([]ObjectState, 0, len(f.restored))
+	for _, o := range f.restored {
+		out = append(out, o)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ContentLocation != out[j].ContentLocation {
+			return out[i].ContentLocation < out[j].ContentLocation
+		}
+		return out[i].Toi < out[j].Toi
+	})
+	return out
+}
+
 // ------- 小工具 -------
 
 func mustParseURL(s string) *url.URL {
@@ -1,10 +1,12 @@
 package sender
 
 import (
+	"Flute_go/pkg/lct"
 	"Flute_go/pkg/object"
 	"Flute_go/pkg/oti"
 	"Flute_go/pkg/tools"
 	t "Flute_go/pkg/type"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"sync"
@@ -29,6 +31,9 @@ type TransferInfo struct {
 	nextTransferTimestamp  *time.Time
 	packetTransmissionTick *time.Duration
 	transferStartTime      *time.Time
+	// 调度器设置的窗口结束时间；scheduled 的对象不会因传输次数用完而过期
+	transferWindowEnd *time.Time
+	scheduled         bool
 }
 
 func (t *TransferInfo) init(obj *ObjectDesc, o *oti.Oti, now time.Time) {
@@ -101,6 +106,7 @@ type FileDesc struct {
 	SenderCurrentTime bool
 
 	published    atomic.Bool
+	bytesSent    atomic.Uint64
 	TOI          t.Uint128
 	mu           sync.RWMutex
 	transferInfo TransferInfo
@@ -118,13 +124,8 @@ func NewFileDesc(
 		return nil, errors.New("Object TOI is required")
 	}
 
-	// 选择对象级 OTI 或默认 OTI
-	otiVal := *defaultOti
-	//TODO: 选择对象级 OTI 或默认 OTI
-	//if obj.OTIOverrideJSON != nil {
-	//	// 可选：支持对象级 OTI 覆盖
-	//	// _ = json.Unmarshal([]byte(*obj.OTIOverrideJSON), &otiVal)
-	//}
+	// 选择对象级 OTI 或默认 OTI（与 ValidateObject 一致），FDT 的 File 元素携带该 OTI
+	otiVal := *obj.effectiveOti(defaultOti)
 
 	maxTransferLen := otiVal.MaxTransferLength()
 	if obj.TransferLength > uint64(maxTransferLen) {
@@ -156,6 +157,25 @@ func NewFileDesc(
 	return fd, nil
 }
 
+// Info 生成事件携带的对象信息
+func (f *FileDesc) Info() FileInfo {
+	var cl string
+	if f.Object.ContentLocation != nil {
+		cl = f.Object.ContentLocation.String()
+	}
+	return FileInfo{
+		Toi:             f.TOI.String(),
+		ContentLocation: cl,
+		Priority:        f.Priority,
+		BytesSent:       f.bytesSent.Load(),
+		TransferCount:   f.TotalNbTransfer(),
+	}
+}
+
+func (f *FileDesc) addBytesSent(n int) {
+	f.bytesSent.Add(uint64(n))
+}
+
 func (f *FileDesc) TotalNbTransfer() uint64 {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
@@ -185,6 +205,11 @@ func (f *FileDesc) IsExpired() bool {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 
+	// 由调度器管理生命周期
+	if f.transferInfo.scheduled {
+		return false
+	}
+
 	// 若还没达到最大次数，不过期
 	if f.Object.MaxTransferCount > 0 && f.Object.MaxTransferCount > f.transferInfo.transferCount {
 		return false
@@ -222,6 +247,19 @@ func (f *FileDesc) ResetLastTransfer(startTime *time.Time) {
 	}
 }
 
+// SetTransferWindow 打开新的传输窗口 [start, end)：重置本轮传输计数，
+// 对象此后由调度器管理（不会自动过期）
+func (f *FileDesc) SetTransferWindow(start time.Time, end *time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.transferInfo.transferStartTime = &start
+	f.transferInfo.transferWindowEnd = end
+	f.transferInfo.transferCount = 0
+	f.transferInfo.lastTransferEndTime = nil
+	f.transferInfo.lastTransferStartTime = nil
+	f.transferInfo.scheduled = true
+}
+
 func (f *FileDesc) IsLastTransfer() bool {
 	// 有 carousel 则永远不是“最后一次”
 	if f.Object.CarouselMode != nil {
@@ -229,9 +267,6 @@ func (f *FileDesc) IsLastTransfer() bool {
 	}
 	f.mu.RLock()
 	defer f.mu.RUnlock()
-	if f.Object.MaxTransferCount > 0 {
-		return false
-	}
 	return f.Object.MaxTransferCount == f.transferInfo.transferCount+1
 }
 
@@ -251,6 +286,10 @@ func (f *FileDesc) ShouldTransferNow(priority uint32, mode FDTPublishMode, now t
 	if f.transferInfo.transferStartTime != nil && now.Before(*f.transferInfo.transferStartTime) {
 		return false
 	}
+	// 传输窗口已结束
+	if f.transferInfo.transferWindowEnd != nil && !now.Before(*f.transferInfo.transferWindowEnd) {
+		return false
+	}
 	// 正在传输
 	if f.transferInfo.transferring {
 		return false
@@ -261,6 +300,11 @@ func (f *FileDesc) ShouldTransferNow(priority uint32, mode FDTPublishMode, now t
 		return true
 	}
 
+	// 计划传输的对象：本窗口的次数用完且无轮播，等待下一个窗口
+	if f.transferInfo.scheduled && f.Object.CarouselMode == nil && f.transferInfo.transferCount > 0 {
+		return false
+	}
+
 	// 没有轮播 || 上次时间缺失：允许传
 	if f.Object.CarouselMode == nil ||
 		f.transferInfo.lastTransferEndTime == nil ||
@@ -335,6 +379,21 @@ func (f *FileDesc) ToFileXML(now time.Time) object.FdtFile {
 		cc = &object.CacheControl{Value: choice}
 	}
 
+	var encScheme, encKeyID, encIV *string
+	if f.Object.Encryption != object.EncryptionNone && f.Object.ContentKeyID != nil {
+		encScheme = tools.StrPtr(f.Object.Encryption.String())
+		encKeyID = f.Object.ContentKeyID
+		encIV = tools.StrPtr(base64.StdEncoding.EncodeToString(f.Object.EncryptionIV))
+	}
+
+	var windows []object.FdtTransferWindow
+	for _, w := range f.Object.TransferWindows {
+		if w.End != nil && !now.Before(*w.End) {
+			continue
+		}
+		windows = append(windows, object.NewFdtTransferWindow(w.Start, w.End))
+	}
+
 	return object.FdtFile{
 		// 标识
 		ContentLocation: f.Object.ContentLocation.String(),
@@ -346,9 +405,18 @@ func (f *FileDesc) ToFileXML(now time.Time) object.FdtFile {
 
 		// 内容类型
 		ContentType:     &f.Object.ContentType,
-		ContentEncoding: tools.StrPtr(f.Object.Cenc.String()),
+		ContentEncoding: contentEncoding(f.Object.Cenc),
 		ContentMD5:      f.Object.MD5,
 
+		// 版本
+		ContentVersion: f.Object.ContentVersion,
+		ETag:           f.Object.ETag,
+
+		// 内容加密
+		ContentEncryption: encScheme,
+		ContentKeyID:      encKeyID,
+		ContentIV:         encIV,
+
 		// 文件级 FEC OTI：把 FecOti* 映射到 FEC*（字段类型也匹配 *uint8/*uint64/*string）
 		FECEncID:      attr.FecOtiFecEncodingID,
 		FECInstanceID: attr.FecOtiFecInstanceID,
@@ -358,6 +426,16 @@ func (f *FileDesc) ToFileXML(now time.Time) object.FdtFile {
 		FECSchemeInfo: attr.FecOtiSchemeSpecificInfo,
 
 		// 子元素
-		CacheControl: cc,
+		CacheControl:    cc,
+		OptelPropagator: object.OptelPropagatorEntries(f.Object.OptelPropagator),
+		TransferWindows: windows,
+	}
+}
+
+// contentEncoding Null 时不携带 Content-Encoding
+func contentEncoding(c lct.Cenc) *string {
+	if c == lct.CencNull {
+		return nil
 	}
+	return tools.StrPtr(c.ContentEncoding())
 }
@@ -0,0 +1,293 @@
+package sender
+
+import (
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/tools"
+	t "Flute_go/pkg/type"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HotFolderRule 文件名 glob（filepath.Match 语法，匹配相对监视目录的路径）到优先级队列
+type HotFolderRule struct {
+	Pattern  string
+	Priority uint32
+}
+
+type HotFolderConfig struct {
+	// 监视的目录
+	Dirs []string
+	// 是否递归子目录
+	Recursive bool
+	// 按顺序匹配，首个命中的规则决定优先级；都不命中时使用 DefaultPriority
+	Rules           []HotFolderRule
+	DefaultPriority uint32
+	// 有规则但都不命中时是否忽略该文件
+	SkipUnmatched bool
+
+	// 轮询间隔（0 = 每次 Scan 都扫描）
+	PollInterval time.Duration
+	// 文件大小/修改时间保持不变这么久之后才加入，避免发送写到一半的文件
+	SettleDelay time.Duration
+
+	// 以下参数透传给 CreateFromFile
+	CacheInRAM       bool // false 时复制到 SpoolDir 后再发送
+	MaxTransferCount uint32
+	CarouselMode     *CarouselRepeatMode
+	CacheControl     *CacheControl
+	Groups           []string
+	Cenc             lct.Cenc
+	InbandCenc       bool
+	Oti              *oti.Oti
+	WithMD5          bool
+	// nil 时按扩展名推断 Content-Type
+	ContentType func(path string) string
+
+	// nil 使用 slog.Default()
+	Logger *slog.Logger
+}
+
+type hotFile struct {
+	size        int64
+	modTime     time.Time
+	stableSince time.Time
+	toi         *t.Uint128 // nil = 不在 FDT 中
+	// 最近一次处理（加入或加入失败）时的文件状态
+	handled     bool
+	handledSize int64
+	handledMod  time.Time
+}
+
+func (h *hotFile) changedSinceHandled() bool {
+	return !h.handled || h.size != h.handledSize || !h.modTime.Equal(h.handledMod)
+}
+
+func (h *hotFile) markHandled() {
+	h.handled, h.handledSize, h.handledMod = true, h.size, h.modTime
+}
+
+// HotFolder 轮询监视目录：新增/修改的文件以新 TOI 加入，删除的文件从 FDT 移除，
+// 变化稳定后重新 Publish。由发送循环周期调用 Scan，不另起 goroutine
+type HotFolder struct {
+	sender *Sender
+	cfg    HotFolderConfig
+	logger *slog.Logger
+
+	files          map[string]*hotFile
+	lastPoll       *time.Time
+	pendingPublish bool
+}
+
+func NewHotFolder(s *Sender, cfg HotFolderConfig) *HotFolder {
+	return &HotFolder{
+		sender: s,
+		cfg:    cfg,
+		logger: tools.LoggerOrDefault(cfg.Logger).With("component", "hotfolder"),
+		files:  make(map[string]*hotFile),
+	}
+}
+
+// Scan 轮询一次目录；两次轮询间隔小于 PollInterval 时直接返回
+// 返回本次是否发布了新的 FDT
+func (h *HotFolder) Scan(now time.Time) (bool, error) {
+	if h.lastPoll != nil && now.Sub(*h.lastPoll) < h.cfg.PollInterval {
+		return false, nil
+	}
+	h.lastPoll = &now
+
+	seen := make(map[string]struct{}, len(h.files))
+	for _, dir := range h.cfg.Dirs {
+		if err := h.walk(dir, seen, now); err != nil {
+			return false, err
+		}
+	}
+
+	// 已删除的文件；删除不等待其他文件稳定，立即发布
+	removed := false
+	for path, hf := range h.files {
+		if _, ok := seen[path]; ok {
+			continue
+		}
+		if hf.toi != nil {
+			h.sender.RemoveObject(*hf.toi)
+			h.logger.Info("file removed", "path", path, "toi", hf.toi.String())
+			h.pendingPublish = true
+			removed = true
+		}
+		delete(h.files, path)
+	}
+
+	// 稳定的新文件/修改过的文件，按路径排序保证 TOI 分配顺序确定
+	paths := make([]string, 0, len(h.files))
+	for p := range h.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	settling := false
+	for _, path := range paths {
+		hf := h.files[path]
+		if !hf.changedSinceHandled() {
+			continue
+		}
+		if now.Sub(hf.stableSince) < h.cfg.SettleDelay {
+			settling = true
+			continue
+		}
+		// 失败也记为已处理，文件再次变化时重试
+		hf.markHandled()
+		if err := h.addFile(path, hf); err != nil {
+			h.logger.Error("fail to add file", "path", path, "err", err)
+			continue
+		}
+		h.pendingPublish = true
+	}
+
+	// 新增的文件等其他文件稳定后一起发布，避免逐个文件重发 FDT
+	if !h.pendingPublish || (settling && !removed) {
+		return false, nil
+	}
+	if err := h.sender.Publish(now); err != nil {
+		return false, err
+	}
+	h.pendingPublish = false
+	return true, nil
+}
+
+func (h *HotFolder) walk(dir string, seen map[string]struct{}, now time.Time) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 目录暂时不可读（被删除等），忽略本轮
+			if path == dir {
+				h.logger.Warn("fail to read directory", "dir", dir, "err", err)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != dir && (!h.cfg.Recursive || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// 忽略隐藏文件（常用于原子写入的临时文件）和非普通文件
+		if strings.HasPrefix(name, ".") || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		if _, ok := h.priorityFor(rel); !ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		seen[path] = struct{}{}
+		hf, ok := h.files[path]
+		if !ok {
+			h.files[path] = &hotFile{size: info.Size(), modTime: info.ModTime(), stableSince: now}
+			return nil
+		}
+		if hf.size != info.Size() || !hf.modTime.Equal(info.ModTime()) {
+			hf.size, hf.modTime, hf.stableSince = info.Size(), info.ModTime(), now
+		}
+		return nil
+	})
+}
+
+func (h *HotFolder) priorityFor(rel string) (uint32, bool) {
+	rel = filepath.ToSlash(rel)
+	for _, r := range h.cfg.Rules {
+		if ok, _ := filepath.Match(r.Pattern, rel); ok {
+			return r.Priority, true
+		}
+		// 模式不含目录时也允许只匹配文件名
+		if !strings.Contains(r.Pattern, "/") {
+			if ok, _ := filepath.Match(r.Pattern, filepath.Base(rel)); ok {
+				return r.Priority, true
+			}
+		}
+	}
+	if len(h.cfg.Rules) > 0 && h.cfg.SkipUnmatched {
+		return 0, false
+	}
+	return h.cfg.DefaultPriority, true
+}
+
+func (h *HotFolder) addFile(path string, hf *hotFile) error {
+	rel := filepath.Base(path)
+	for _, dir := range h.cfg.Dirs {
+		if r, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(r, "..") {
+			rel = r
+			break
+		}
+	}
+	priority, _ := h.priorityFor(rel)
+	cl, err := url.Parse("file:///" + filepath.ToSlash(rel))
+	if err != nil {
+		return err
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if h.cfg.ContentType != nil {
+		contentType = h.cfg.ContentType(path)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// 不缓存到内存时也先做快照：监视目录中的文件可能被原地改写
+	obj, err := createFromFile(
+		path,
+		true,
+		cl,
+		contentType,
+		h.cfg.CacheInRAM,
+		h.cfg.MaxTransferCount,
+		h.cfg.CarouselMode,
+		nil,
+		h.cfg.CacheControl,
+		h.cfg.Groups,
+		h.cfg.Cenc,
+		h.cfg.InbandCenc,
+		h.cfg.Oti,
+		h.cfg.WithMD5,
+	)
+	if err != nil {
+		return err
+	}
+
+	// 修改过的文件：以新 TOI 替换旧对象（旧 TOI 发完本轮后停止）
+	toi, err := h.sender.UpdateObject(priority, obj)
+	if err != nil {
+		// 释放快照（已删除目录项的临时文件）
+		obj.Source.Close()
+		return err
+	}
+	hf.toi = &toi
+	h.logger.Info("file added", "path", path, "toi", toi.String(), "priority", priority)
+	return nil
+}
+
+// Tracked 当前已加入 FDT 的文件路径 -> TOI
+func (h *HotFolder) Tracked() map[string]t.Uint128 {
+	out := make(map[string]t.Uint128, len(h.files))
+	for p, hf := range h.files {
+		if hf.toi != nil {
+			out[p] = *hf.toi
+		}
+	}
+	return out
+}
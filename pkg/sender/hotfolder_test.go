@@ -0,0 +1,148 @@
+package sender
+
+import (
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/transport"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHotFolder(t *testing.T) {
+	dir := t.TempDir()
+	o := oti.NewOti()
+	endpoint := transport.NewUDPEndpoint(nil, "224.0.0.1", 1234)
+	cfg := DefaultConfig()
+	cfg.SetPriorityQueue(1, NewPriorityQueue(1))
+	sender := NewSender(endpoint, 1, o, &cfg)
+
+	hf := NewHotFolder(sender, HotFolderConfig{
+		Dirs:         []string{dir},
+		Rules:        []HotFolderRule{{Pattern: "*.mp4", Priority: 1}},
+		SettleDelay:  time.Second,
+		CacheInRAM:   true,
+		CarouselMode: &CarouselRepeatMode{Choice: DelayBetweenTransfers, Interval: time.Second},
+	})
+
+	path := filepath.Join(dir, "clip.mp4")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// 隐藏的临时文件不应被加入
+	if err := os.WriteFile(filepath.Join(dir, ".clip.mp4.tmp"), []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if published, err := hf.Scan(now); err != nil || published || sender.NbObjects() != 0 {
+		t.Fatalf("file must settle before being added (published=%v err=%v n=%d)", published, err, sender.NbObjects())
+	}
+	now = now.Add(2 * time.Second)
+	if published, err := hf.Scan(now); err != nil || !published || sender.NbObjects() != 1 {
+		t.Fatalf("expected file to be added and published (published=%v err=%v n=%d)", published, err, sender.NbObjects())
+	}
+	first := hf.Tracked()[path]
+	objs := sender.GetObjectsInFDT()
+	if obj := objs[first.String()]; obj == nil || obj.ContentLocation.String() != "file:///clip.mp4" {
+		t.Fatalf("unexpected object in FDT: %+v", objs)
+	}
+
+	// 修改后以新 TOI 加入
+	if err := os.WriteFile(path, []byte("hello world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mod := now.Add(time.Minute)
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Second)
+	hf.Scan(now)
+	now = now.Add(2 * time.Second)
+	if published, _ := hf.Scan(now); !published {
+		t.Fatalf("expected publish after modification settled")
+	}
+	second := hf.Tracked()[path]
+	if second == first || sender.IsAdded(first) || !sender.IsAdded(second) {
+		t.Fatalf("modified file must replace old TOI %s with a new one, got %s", first, second)
+	}
+
+	// 删除后从 FDT 移除
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Second)
+	if published, _ := hf.Scan(now); !published || sender.NbObjects() != 0 {
+		t.Fatalf("deleted file must be removed from the FDT")
+	}
+}
+
+func TestHotFolderSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	endpoint := transport.NewUDPEndpoint(nil, "224.0.0.1", 1234)
+	sender := NewSender(endpoint, 1, oti.NewOti(), nil)
+	hf := NewHotFolder(sender, HotFolderConfig{Dirs: []string{dir}})
+
+	path := filepath.Join(dir, "data.bin")
+	if err := os.WriteFile(path, []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if published, err := hf.Scan(now); err != nil || !published {
+		t.Fatalf("expected file to be added (published=%v err=%v)", published, err)
+	}
+
+	// 发送前原地改写：正在发送的 TOI 仍是加入时的内容
+	if err := os.WriteFile(path, []byte("REWRITE!"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	for _, p := range readDataPkts(t, sender, now) {
+		got = append(got, p.Data[p.DataPayloadOffset:]...)
+	}
+	if string(got) != "original" {
+		t.Fatalf("object must be sent from a snapshot, got %q", got)
+	}
+}
+
+// 加入失败的文件不触发发布、不占用快照；删除不等待其他文件稳定
+func TestHotFolderRejectAndRemove(t *testing.T) {
+	dir := t.TempDir()
+	o, _ := oti.NewReedSolomonRS28(4, 4, 2)
+	sender := NewSender(transport.NewUDPEndpoint(nil, "224.0.0.1", 1234), 1, o, nil)
+	hf := NewHotFolder(sender, HotFolderConfig{Dirs: []string{dir}, SettleDelay: time.Second})
+
+	keep := filepath.Join(dir, "keep.bin")
+	os.WriteFile(keep, []byte("keep"), 0o644)
+	now := time.Now()
+	hf.Scan(now)
+	now = now.Add(2 * time.Second)
+	if published, _ := hf.Scan(now); !published || sender.NbObjects() != 1 {
+		t.Fatalf("expected keep.bin to be added")
+	}
+
+	fds := func() int {
+		entries, _ := os.ReadDir("/proc/self/fd")
+		return len(entries)
+	}
+	before := fds()
+	big := filepath.Join(dir, "big.bin")
+	os.WriteFile(big, make([]byte, o.MaxTransferLength()+1), 0o644)
+	hf.Scan(now)
+	now = now.Add(2 * time.Second)
+	if published, _ := hf.Scan(now); published || sender.NbObjects() != 1 {
+		t.Fatalf("a rejected file must not trigger a publish")
+	}
+	if after := fds(); after > before {
+		t.Fatalf("snapshot of a rejected file must be closed: %d -> %d open files", before, after)
+	}
+
+	// grow.bin 持续变化，keep.bin 的删除仍须立即发布
+	grow := filepath.Join(dir, "grow.bin")
+	os.WriteFile(grow, []byte("a"), 0o644)
+	os.Remove(keep)
+	now = now.Add(time.Second)
+	if published, _ := hf.Scan(now); !published || sender.NbObjects() != 0 {
+		t.Fatalf("removal must be published while another file is settling")
+	}
+}
@@ -0,0 +1,214 @@
+package sender
+
+import (
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/tools"
+	t "Flute_go/pkg/type"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLiveStreamClosed 写入已关闭的 LiveStream
+	ErrLiveStreamClosed = errors.New("live stream closed")
+	// ErrLiveStreamFull 尚未切成分段的数据已达上限，生产者应稍后重试
+	ErrLiveStreamFull = errors.New("live stream buffer full")
+)
+
+// LiveStream 长度未知/不断增长的数据源（日志、正在生成的视频分片、stdin 管道等）
+// 生产者通过 Write 追加数据、Close 结束；LiveSegmenter 在发送循环中把数据切成长度确定的分段对象
+// 只缓冲尚未切出的数据，最多 maxBuffered 字节，超出部分 Write 返回 ErrLiveStreamFull
+type LiveStream struct {
+	mu          sync.Mutex
+	buf         []byte
+	maxBuffered int
+	closed      bool
+}
+
+// NewLiveStream maxBuffered 为尚未切出的数据上限，应不小于 LiveSegmentConfig.SegmentSize
+func NewLiveStream(maxBuffered int) *LiveStream {
+	return &LiveStream{maxBuffered: maxBuffered}
+}
+
+// Write 追加数据，可与发送循环并发调用；缓冲满时写入能放下的部分并返回 ErrLiveStreamFull
+func (l *LiveStream) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return 0, ErrLiveStreamClosed
+	}
+	n := min(len(p), l.maxBuffered-len(l.buf))
+	l.buf = append(l.buf, p[:n]...)
+	if n < len(p) {
+		return n, ErrLiveStreamFull
+	}
+	return n, nil
+}
+
+// Close 结束写入，剩余数据成为最后一个分段
+func (l *LiveStream) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	return nil
+}
+
+// Len 尚未切出的数据长度，以及是否已关闭
+func (l *LiveStream) Len() (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buf), l.closed
+}
+
+// take 凑满 n 字节，或已关闭时取出剩余数据；否则返回 nil
+func (l *LiveStream) take(n int) []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.buf) < n && (!l.closed || len(l.buf) == 0) {
+		return nil
+	}
+	n = min(n, len(l.buf))
+	out := l.buf[:n:n]
+	l.buf = append([]byte(nil), l.buf[n:]...)
+	return out
+}
+
+type LiveSegmentConfig struct {
+	// 分段 n 的 Content-Location 为 "<Location>.<n>"（n 从 0 开始）
+	Location    *url.URL
+	ContentType string
+	// 每段字节数；流关闭时剩余数据成为最后一段
+	SegmentSize int
+	Priority    uint32
+	// 保留在 FDT 中的最近分段数，更早的分段被移除（0 = 不主动移除，由传输次数/轮播决定）
+	// 轮播时应设置，否则全部分段都留在内存中
+	Keep int
+
+	// 以下参数透传给 CreateFromBuffer
+	MaxTransferCount uint32
+	CarouselMode     *CarouselRepeatMode
+	CacheControl     *CacheControl
+	Groups           []string
+	Oti              *oti.Oti
+	WithMD5          bool
+
+	// nil 使用 slog.Default()
+	Logger *slog.Logger
+}
+
+// LiveSegmenter 把 LiveStream 切成依次编号的对象：每个分段长度确定，FDT 中带 Content-Length，
+// 按 RFC 5052 分块发送，最后一个包带 Close Object 标志，接收端按普通文件接收
+// 与 HotFolder 一样由发送循环周期调用 Poll，不另起 goroutine
+// 内存上限：LiveStream 的 maxBuffered 加上仍在 Sender 中的分段（Keep 个，或尚未发完的分段）
+type LiveSegmenter struct {
+	sender *Sender
+	stream *LiveStream
+	cfg    LiveSegmentConfig
+	logger *slog.Logger
+
+	next     int
+	segments []t.Uint128 // 仍在 FDT 中的分段，按序号排列
+	done     bool
+}
+
+func NewLiveSegmenter(s *Sender, stream *LiveStream, cfg LiveSegmentConfig) (*LiveSegmenter, error) {
+	if stream == nil {
+		return nil, errors.New("live stream is nil")
+	}
+	if cfg.Location == nil {
+		return nil, errors.New("live segment location is required")
+	}
+	if cfg.SegmentSize <= 0 || cfg.SegmentSize > stream.maxBuffered {
+		return nil, fmt.Errorf("live segment size %d must be in (0, %d]", cfg.SegmentSize, stream.maxBuffered)
+	}
+	o := cfg.Oti
+	if o == nil {
+		o = &s.fdt.oti
+	}
+	if uint64(cfg.SegmentSize) > o.MaxTransferLength() {
+		return nil, fmt.Errorf("live segment size %d exceeds the maximum transfer length %d of the OTI",
+			cfg.SegmentSize, o.MaxTransferLength())
+	}
+	return &LiveSegmenter{
+		sender: s,
+		stream: stream,
+		cfg:    cfg,
+		logger: tools.LoggerOrDefault(cfg.Logger).With("component", "live", "location", cfg.Location.String()),
+	}, nil
+}
+
+// Poll 切出已凑满的分段（流关闭后连同剩余数据）加入 Sender，移除超出 Keep 的旧分段，有变化时 Publish
+// 返回本次新增的分段数
+func (l *LiveSegmenter) Poll(now time.Time) (int, error) {
+	added, removed := 0, false
+	for !l.done {
+		data := l.stream.take(l.cfg.SegmentSize)
+		if data == nil {
+			if _, closed := l.stream.Len(); closed {
+				l.done = true
+			}
+			break
+		}
+		if err := l.addSegment(data); err != nil {
+			return added, err
+		}
+		added++
+	}
+	// 已发完并被 Sender 释放的分段不再计入 Keep
+	kept := l.segments[:0]
+	for _, toi := range l.segments {
+		if l.sender.IsAdded(toi) {
+			kept = append(kept, toi)
+		}
+	}
+	l.segments = kept
+	for l.cfg.Keep > 0 && len(l.segments) > l.cfg.Keep {
+		l.sender.RemoveObject(l.segments[0])
+		l.segments = l.segments[1:]
+		removed = true
+	}
+	if added == 0 && !removed {
+		return 0, nil
+	}
+	return added, l.sender.Publish(now)
+}
+
+// Done 流已关闭且全部数据已切成分段
+func (l *LiveSegmenter) Done() bool {
+	return l.done
+}
+
+func (l *LiveSegmenter) addSegment(data []byte) error {
+	u := *l.cfg.Location
+	u.Path = fmt.Sprintf("%s.%d", u.Path, l.next)
+	obj, err := CreateFromBuffer(
+		data,
+		l.cfg.ContentType,
+		&u,
+		l.cfg.MaxTransferCount,
+		l.cfg.CarouselMode,
+		nil,
+		l.cfg.CacheControl,
+		l.cfg.Groups,
+		lct.CencNull,
+		true,
+		l.cfg.Oti,
+		l.cfg.WithMD5,
+	)
+	if err != nil {
+		return err
+	}
+	toi, err := l.sender.AddObject(l.cfg.Priority, obj)
+	if err != nil {
+		return err
+	}
+	l.logger.Info("live segment added", "segment", l.next, "toi", toi.String(), "bytes", len(data))
+	l.next++
+	l.segments = append(l.segments, toi)
+	return nil
+}
@@ -0,0 +1,191 @@
+package sender
+
+import (
+	"Flute_go/pkg/alc"
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/object"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/transport"
+	"bytes"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// readDataPkts 读出当前可发送的包，返回数据包（非 FDT）
+func readDataPkts(t *testing.T, s *Sender, now time.Time) []*alc.AlcPkt {
+	var out []*alc.AlcPkt
+	for {
+		data := s.Read(now)
+		if data == nil {
+			return out
+		}
+		pkt, err := alc.ParseAlcPkt(data)
+		if err != nil {
+			t.Fatalf("ParseAlcPkt failed: %v", err)
+		}
+		if pkt.Lct.Toi != lct.TOI_FDT {
+			out = append(out, pkt)
+		}
+	}
+}
+
+// liveConfig 单会话优先级队列，直播对象等待数据时必须让出会话
+func liveConfig() *Config {
+	cfg := DefaultConfig()
+	cfg.PriorityQueues = map[uint32]PriorityQueue{0: {MultiplexFiles: 1}}
+	return &cfg
+}
+
+// decodeSource 按 FTI 中的传输长度做 RFC 5052 分块，把源符号放回原位
+func decodeSource(t *testing.T, pkts []*alc.AlcPkt) []byte {
+	t.Helper()
+	o, length := *pkts[0].Oti, *pkts[0].TransferLength
+	esl := uint64(o.EncodingSymbolLength)
+	aLarge, aSmall, nbALarge, nbBlocks := object.BlockPartitioning(uint64(o.MaximumSourceBlockLength), length, esl)
+	offsets := make([]uint64, nbBlocks+1)
+	for sbn := uint64(0); sbn < nbBlocks; sbn++ {
+		blockLen := aSmall
+		if sbn < nbALarge {
+			blockLen = aLarge
+		}
+		offsets[sbn+1] = min(offsets[sbn]+blockLen*esl, length)
+	}
+	out := make([]byte, length)
+	for _, p := range pkts {
+		if *p.TransferLength != length {
+			t.Fatalf("transfer length changed within a transfer: %d != %d", *p.TransferLength, length)
+		}
+		pid, err := alc.ParsePayloadID(p, &o)
+		if err != nil || uint64(pid.Sbn) >= nbBlocks {
+			t.Fatalf("bad payload id %+v: %v", pid, err)
+		}
+		start := offsets[pid.Sbn] + uint64(pid.Esi)*esl
+		if start >= offsets[pid.Sbn+1] {
+			continue // 修复符号
+		}
+		copy(out[start:offsets[pid.Sbn+1]], p.Data[p.DataPayloadOffset:])
+	}
+	return out
+}
+
+// dataPktsByToi 读出当前可发送的数据包，按 TOI 分组
+func dataPktsByToi(t *testing.T, s *Sender, now time.Time) map[string][]*alc.AlcPkt {
+	out := make(map[string][]*alc.AlcPkt)
+	for _, p := range readDataPkts(t, s, now) {
+		out[p.Lct.Toi.String()] = append(out[p.Lct.Toi.String()], p)
+	}
+	return out
+}
+
+func TestSenderLiveStream(t *testing.T) {
+	// 分段 6400 字节 = 64 个符号，RFC 5052 分块为一整块；最后一段 32 个符号
+	o, err := oti.NewReedSolomonRS28(100, 64, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := NewSender(transport.NewUDPEndpoint(nil, "224.0.0.1", 1234), 1, o, liveConfig())
+	now := time.Now()
+
+	live := NewLiveStream(8000)
+	u, _ := url.Parse("file:///live.log")
+	seg, err := NewLiveSegmenter(sender, live, LiveSegmentConfig{
+		Location: u, ContentType: "text/plain", SegmentSize: 6400, MaxTransferCount: 1,
+	})
+	if err != nil {
+		t.Fatalf("NewLiveSegmenter failed: %v", err)
+	}
+
+	content := make([]byte, 160*100)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	// 缓冲上限：超出部分被拒绝，由生产者稍后重试
+	n, err := live.Write(content[:10000])
+	if n != 8000 || err != ErrLiveStreamFull {
+		t.Fatalf("expected a short write of 8000 bytes, got %d, %v", n, err)
+	}
+	if added, err := seg.Poll(now); added != 1 || err != nil {
+		t.Fatalf("expected one full segment, got %d, %v", added, err)
+	}
+	if buffered, _ := live.Len(); buffered != 1600 {
+		t.Fatalf("segmented data must leave the buffer, %d bytes left", buffered)
+	}
+	if _, err := live.Write(content[8000:14400]); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	xmlData, _ := sender.FdtXMLData(now)
+	if !bytes.Contains(xmlData, []byte(`Content-Location="file:///live.log.0" TOI="`)) ||
+		!bytes.Contains(xmlData, []byte(`Transfer-Length="6400"`)) {
+		t.Fatalf("FDT must list the segment with its length:\n%s", xmlData)
+	}
+
+	if added, err := seg.Poll(now); added != 1 || err != nil || seg.Done() {
+		t.Fatalf("expected the second segment, got %d, %v", added, err)
+	}
+	if _, err := live.Write(content[14400:]); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	live.Close()
+	if _, err := live.Write([]byte{0}); err != ErrLiveStreamClosed {
+		t.Fatalf("write after close must fail, got %v", err)
+	}
+	if added, err := seg.Poll(now); added != 1 || err != nil || !seg.Done() {
+		t.Fatalf("expected the remainder as the last segment, got %d, %v, done=%v", added, err, seg.Done())
+	}
+
+	// 每个分段都是普通对象：包内带最终长度、按 RFC 5052 分块，最后一个包带 Close Object
+	var got []byte
+	groups := dataPktsByToi(t, sender, now)
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 segment objects, got %d", len(groups))
+	}
+	for _, toi := range []uint64{1, 2, 3} {
+		pkts := groups[fmt.Sprintf("%032x", toi)]
+		if len(pkts) == 0 {
+			t.Fatalf("no packets for segment TOI %d", toi)
+		}
+		if !pkts[len(pkts)-1].Lct.CloseObject {
+			t.Fatalf("last packet of segment TOI %d lacks the close-object flag", toi)
+		}
+		got = append(got, decodeSource(t, pkts)...)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("segments do not reassemble the stream")
+	}
+	if sender.NbObjects() != 0 {
+		t.Fatalf("sent segments must be released, %d left", sender.NbObjects())
+	}
+}
+
+// 轮播时只保留最近 Keep 个分段
+func TestLiveSegmenterKeep(t *testing.T) {
+	sender := NewSender(transport.NewUDPEndpoint(nil, "224.0.0.1", 1234), 1, oti.NewOti(), nil)
+	now := time.Now()
+	live := NewLiveStream(100)
+	u, _ := url.Parse("file:///live.log")
+	cfg := LiveSegmentConfig{
+		Location: u, SegmentSize: 10, Keep: 2,
+		CarouselMode: &CarouselRepeatMode{Choice: DelayBetweenTransfers, Interval: time.Hour},
+	}
+	if _, err := NewLiveSegmenter(sender, live, LiveSegmentConfig{Location: u, SegmentSize: 101}); err == nil {
+		t.Fatal("segment larger than the stream buffer accepted")
+	}
+	seg, err := NewLiveSegmenter(sender, live, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		live.Write(bytes.Repeat([]byte{byte(i)}, 10))
+		if _, err := seg.Poll(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	xmlData, _ := sender.FdtXMLData(now)
+	if sender.NbObjects() != 2 || !bytes.Contains(xmlData, []byte("live.log.3")) ||
+		!bytes.Contains(xmlData, []byte("live.log.4")) || bytes.Contains(xmlData, []byte("live.log.2")) {
+		t.Fatalf("expected only the last two segments:\n%s", xmlData)
+	}
+}
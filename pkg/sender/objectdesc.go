@@ -7,6 +7,7 @@ import (
 	"Flute_go/pkg/tools"
 
 	"bufio"
+	"context"
 	"crypto/md5"
 	"encoding/base64"
 	"errors"
@@ -16,6 +17,8 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type CacheControlChoice int
@@ -157,6 +160,8 @@ type ObjectDataSource struct {
 	/// Source from a stream
 	streamMu sync.Mutex
 	stream   ObjectDataStream
+	// CreateFromFile 打开的文件（含压缩临时文件），对象移出 FDT 后由 Close 关闭
+	closer io.Closer
 
 	/// Source from a buffer
 	buffer []byte
@@ -227,6 +232,19 @@ func (o *ObjectDataSource) Len() (uint64, error) {
 	}
 }
 
+// Close 关闭 CreateFromFile 打开的文件，释放句柄与临时文件的磁盘空间；
+// 调用方传入的 stream 由调用方自己关闭。可重复调用
+func (o *ObjectDataSource) Close() error {
+	o.streamMu.Lock()
+	defer o.streamMu.Unlock()
+	if o.closer == nil {
+		return nil
+	}
+	err := o.closer.Close()
+	o.closer = nil
+	return err
+}
+
 type CarouselRepeatModeChoice int
 
 const (
@@ -260,12 +278,63 @@ type ObjectDesc struct {
 	Toi                                   *Toi
 	OptelPropagator                       map[string]string
 	ETag                                  *string
+	ContentVersion                        *uint32
+	TransferWindows                       []TransferWindow // 由 Scheduler 维护，在 FDT 中通告
 	AllowImmediateStopBeforeFirstTransfer *bool
+
+	// 内容加密：ContentKeyID 非 nil 时在 FEC 编码前加密传输表示，密钥由 Config.KeyProvider 提供
+	Encryption   object.EncryptionScheme
+	ContentKeyID *string
+	EncryptionIV []byte // nil = 随机生成
+	encrypted    bool
+}
+
+// TransferWindow 传输窗口 [Start, End)，End 为 nil 表示只在 Start 触发
+type TransferWindow struct {
+	Start time.Time
+	End   *time.Time
 }
 
 // SetToi
 func (o *ObjectDesc) SetToi(t *Toi) { o.Toi = t }
 
+// SetTraceContext 设置 W3C trace context，随 FDT File 下发给接收端
+func (o *ObjectDesc) SetTraceContext(traceparent, tracestate string) error {
+	m := map[string]string{object.TraceParentKey: traceparent}
+	if tracestate != "" {
+		m[object.TraceStateKey] = tracestate
+	}
+	if !trace.SpanContextFromContext(object.ExtractTraceContext(context.Background(), m)).IsValid() {
+		return object.ErrInvalidTraceParent
+	}
+	o.OptelPropagator = m
+	return nil
+}
+
+// SetTraceContextFrom 以 ctx 中当前 span（如摄取系统的 span）为父，随 FDT File 下发
+func (o *ObjectDesc) SetTraceContextFrom(ctx context.Context) error {
+	m := object.InjectTraceContext(ctx)
+	if m == nil {
+		return errors.New("context carries no valid span")
+	}
+	o.OptelPropagator = m
+	return nil
+}
+
+// effectiveOti 对象级 OTI 优先，否则为 Sender 的默认 OTI
+func (o *ObjectDesc) effectiveOti(def *oti.Oti) *oti.Oti {
+	if o.OTI != nil {
+		return o.OTI
+	}
+	return def
+}
+
+// SetEncryption 指定加密方案与内容密钥 ID
+func (o *ObjectDesc) SetEncryption(scheme object.EncryptionScheme, keyID string) {
+	o.Encryption = scheme
+	o.ContentKeyID = &keyID
+}
+
 func CreateFromFile(
 	path string,
 	contentLocation *url.URL, // 可为 nil
@@ -281,6 +350,28 @@ func CreateFromFile(
 	otiOver *oti.Oti,
 	withMD5 bool,
 ) (*ObjectDesc, error) {
+	return createFromFile(path, false, contentLocation, contentType, cacheInRAM, maxTransferCount, carouselMode,
+		targetAcquisition, cacheControl, groups, cenc, inbandCenc, otiOver, withMD5)
+}
+
+// createFromFile snapshot 为 true 时不缓存到内存的文件也先复制到 SpoolDir，
+// 发送期间原文件被原地改写不会影响正在发送的 TOI
+func createFromFile(
+	path string,
+	snapshot bool,
+	contentLocation *url.URL, // 可为 nil
+	contentType string,
+	cacheInRAM bool,
+	maxTransferCount uint32,
+	carouselMode *CarouselRepeatMode,
+	targetAcquisition *TargetAcquisition,
+	cacheControl *CacheControl,
+	groups []string,
+	cenc lct.Cenc,
+	inbandCenc bool,
+	otiOver *oti.Oti,
+	withMD5 bool,
+) (*ObjectDesc, error) {
 
 	cl := contentLocation
 	if cl == nil {
@@ -313,15 +404,44 @@ func CreateFromFile(
 		)
 	}
 
-	if cenc != lct.CencNull {
-		return nil, errors.New("compressed object is not compatible with file path")
-	}
-
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	return CreateFromStream(
+
+	// 不缓存到内存：压缩（或复制）到临时文件，发布前即可得到压缩后的 Transfer-Length
+	if cenc != lct.CencNull || snapshot {
+		spool, transferLen, contentLen, md5b64, err := spoolCompressed(f, cenc, withMD5)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		obj, err := CreateFromStream(
+			spool,
+			contentType,
+			cl,
+			maxTransferCount,
+			carouselMode,
+			targetAcquisition,
+			cacheControl,
+			groups,
+			inbandCenc,
+			otiOver,
+			false,
+		)
+		if err != nil {
+			spool.Close()
+			return nil, err
+		}
+		obj.Source.closer = spool
+		obj.Cenc = cenc
+		obj.ContentLength = contentLen
+		obj.TransferLength = transferLen
+		obj.MD5 = md5b64
+		return obj, nil
+	}
+
+	obj, err := CreateFromStream(
 		f,
 		contentType,
 		cl,
@@ -334,6 +454,12 @@ func CreateFromFile(
 		otiOver,
 		withMD5,
 	)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	obj.Source.closer = f
+	return obj, nil
 }
 
 func CreateFromStream(
@@ -385,6 +511,9 @@ func CreateFromStream(
 		OptelPropagator:                       nil,
 		ETag:                                  nil,
 		AllowImmediateStopBeforeFirstTransfer: nil,
+		Encryption:                            object.EncryptionNone,
+		ContentKeyID:                          nil,
+		EncryptionIV:                          nil,
 	}, nil
 }
 
@@ -471,5 +600,8 @@ func createWithContent(
 		OptelPropagator:                       nil,
 		ETag:                                  nil,
 		AllowImmediateStopBeforeFirstTransfer: nil,
+		Encryption:                            object.EncryptionNone,
+		ContentKeyID:                          nil,
+		EncryptionIV:                          nil,
 	}, nil
 }
@@ -2,12 +2,21 @@ package sender
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type FileInfo struct {
 	// Object TOI
 	Toi string
+	// Content-Location
+	ContentLocation string
+	// 所在优先级队列
+	Priority uint32
+	// 已发送的 ALC 字节数（含头部，累计所有轮次）
+	BytesSent uint64
+	// 已完成的传输次数（累计）
+	TransferCount uint64
 }
 
 type EventKind int
@@ -15,11 +24,54 @@ type EventKind int
 const (
 	EventStartTransfer EventKind = iota
 	EventStopTransfer
+	// FDT-Instance 已发布，见 Event.FdtInstanceID / Event.NbFiles
+	EventFDTPublished
+	EventObjectAdded
+	EventObjectRemoved
+	// 达到最大传输次数且无轮播，已从 FDT 中移除
+	EventObjectExpired
+	// 一轮轮播完成，对象重新排队
+	EventCarouselCycleCompleted
+	// 一个源块编码完成，见 Event.Sbn / Event.NbSourceSymbols / Event.NbEncodingSymbols
+	EventBlockEncoded
 )
 
+func (k EventKind) String() string {
+	switch k {
+	case EventStartTransfer:
+		return "StartTransfer"
+	case EventStopTransfer:
+		return "StopTransfer"
+	case EventFDTPublished:
+		return "FDTPublished"
+	case EventObjectAdded:
+		return "ObjectAdded"
+	case EventObjectRemoved:
+		return "ObjectRemoved"
+	case EventObjectExpired:
+		return "ObjectExpired"
+	case EventCarouselCycleCompleted:
+		return "CarouselCycleCompleted"
+	case EventBlockEncoded:
+		return "BlockEncoded"
+	default:
+		return "Unknown"
+	}
+}
+
 type Event struct {
 	Kind EventKind
+	// FDT 事件时为空
 	File FileInfo
+
+	// EventFDTPublished
+	FdtInstanceID uint32
+	NbFiles       int
+
+	// EventBlockEncoded
+	Sbn               uint32
+	NbSourceSymbols   uint
+	NbEncodingSymbols uint
 }
 
 // 任何实现该接口的类型都可以订阅 Sender 的事件
@@ -69,6 +121,38 @@ func (o *ObserverList) Dispatch(evt Event, now time.Time) {
 	}
 }
 
+// ChannelEvent 通过 channel 投递的事件
+type ChannelEvent struct {
+	Event Event
+	Now   time.Time
+}
+
+// ChannelSubscriber 把事件转发到带缓冲的 channel，供其它 goroutine 消费
+// 发送不阻塞发送循环：缓冲满时丢弃并计数
+type ChannelSubscriber struct {
+	C       <-chan ChannelEvent
+	ch      chan ChannelEvent
+	dropped atomic.Uint64
+}
+
+func NewChannelSubscriber(buffer int) *ChannelSubscriber {
+	ch := make(chan ChannelEvent, buffer)
+	return &ChannelSubscriber{C: ch, ch: ch}
+}
+
+func (c *ChannelSubscriber) OnSenderEvent(evt Event, now time.Time) {
+	select {
+	case c.ch <- ChannelEvent{Event: evt, Now: now}:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+// Dropped 因缓冲满而丢弃的事件数
+func (c *ChannelSubscriber) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
 // Debug 用 fmt.Printf("%+v", ObserverList) 时展示
 func (o *ObserverList) String() string {
 	return "ObserverList"
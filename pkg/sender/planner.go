@@ -0,0 +1,266 @@
+package sender
+
+import (
+	"Flute_go/pkg/alc"
+	"Flute_go/pkg/clock"
+	"Flute_go/pkg/object"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/tools"
+	t "Flute_go/pkg/type"
+	"errors"
+	"time"
+)
+
+// PlanItem 待规划的对象及其优先级
+type PlanItem struct {
+	Priority uint32
+	Object   *ObjectDesc
+}
+
+// PlanOptions 规划参数
+type PlanOptions struct {
+	// 发送速率（bit/s），按 UDP 载荷计，与发送循环的限速口径一致；0 = 不限速，不估算时长
+	RateBps uint64
+	// 固定发包间隔，与 RateBps 同时设置时取较慢者；0 = 不按间隔估算
+	PacketInterval time.Duration
+	// 每包 IP/UDP 头开销，0 = IPv4UDPOverhead
+	PacketOverhead int
+	// 生成 FDT 的时刻，零值 = Config.Clock 的当前时间
+	Now time.Time
+}
+
+// PlanFile 单个对象的规划结果；Packets/PayloadBytes/WireBytes 为全部传输轮次之和
+type PlanFile struct {
+	ContentLocation string
+	Priority        uint32
+	TransferLength  uint64
+	// 传输轮次；Endless 为 true 时（无限轮播）只统计一轮
+	Transfers uint32
+	Endless   bool
+
+	Blocks        uint64
+	SourcePackets uint64 // 每轮
+	ParityPackets uint64 // 每轮
+	HeaderLength  int
+
+	Packets      uint64
+	PayloadBytes uint64 // UDP 载荷
+	WireBytes    uint64 // 含 IP/UDP 头
+	Duration     time.Duration
+}
+
+// Plan 整体规划结果，合计值包含 FDT 轮播
+type Plan struct {
+	Files []PlanFile
+
+	FdtTransferLength uint64 // 单个 FDT 实例（压缩/签名后）
+	FdtPackets        uint64 // 每个 FDT 实例的包数
+	FdtTransfers      uint64
+	FdtPayloadBytes   uint64
+	FdtWireBytes      uint64
+
+	Packets      uint64
+	PayloadBytes uint64
+	WireBytes    uint64
+	// 按速率/发包间隔估算的总时长（含轮播间隔下限）；两者均未设置时为 0
+	Duration time.Duration
+}
+
+// PlanTransfer 估算一组对象在该 OTI/Config 下的包数、线上字节数与时长，不产生任何包
+// 对象本身不会被修改（不分配 TOI、不加密）；加密对象按明文长度估算
+func PlanTransfer(tsi uint64, o *oti.Oti, cfg *Config, items []PlanItem, opts PlanOptions) (*Plan, error) {
+	if cfg == nil {
+		def := DefaultConfig()
+		cfg = &def
+	}
+	if err := ValidateOti(o); err != nil {
+		return nil, err
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = clock.OrReal(cfg.Clock).Now()
+	}
+	overhead := opts.PacketOverhead
+	if overhead == 0 {
+		overhead = IPv4UDPOverhead
+	}
+
+	// 临时 FDT：与 Sender 相同的参数，用于生成 FDT 实例并测量其长度
+	fdt, err := NewFdt(tsi, cfg.FDTStartID, o, cfg.FDTCenc, cfg.FDTDuration, cfg.FDTCarouselMode,
+		cfg.FDTInbandSCT, NewObserverList(), cfg.TOIMaxLength, cfg.TOIInitialValue,
+		&cfg.Groups, cfg.FDTPublishMode, cfg.FDTSigner, cfg.Clock, cfg.Logger)
+	if err != nil {
+		return nil, err
+	}
+	fdt.publishMode = FullFDT // 按完整 FDT 估算，即上限
+
+	plan := &Plan{Files: make([]PlanFile, 0, len(items))}
+	var dataTime, minTime time.Duration
+	for _, it := range items {
+		obj := it.Object
+		if obj == nil {
+			return nil, errors.New("plan: object is nil")
+		}
+		if err := ValidateObject(o, obj); err != nil {
+			return nil, err
+		}
+		// 与 Fdt.AddObject 相同：对象使用默认 OTI
+		fd := &FileDesc{Priority: it.Priority, Object: obj, Oti: *o, TOI: fdt.toiAllocator.Allocate().value}
+		fdt.files[fd.TOI.String()] = fd
+
+		pf := planFile(fd, tsi, cfg, overhead, now)
+		if opts.paced() {
+			pf.Duration = opts.duration(pf.Packets, pf.PayloadBytes)
+			dataTime += pf.Duration
+			minTime = max(minTime, carouselMinDuration(obj, pf))
+		}
+		plan.Files = append(plan.Files, pf)
+		plan.Packets += pf.Packets
+		plan.PayloadBytes += pf.PayloadBytes
+		plan.WireBytes += pf.WireBytes
+	}
+
+	// FDT 实例
+	if err := fdt.Publish(now); err != nil {
+		return nil, err
+	}
+	fdtFd := fdt.fdtTransferQueue[0]
+	fdtPlan := planFile(fdtFd, tsi, cfg, overhead, now)
+	plan.FdtTransferLength = fdtFd.Object.TransferLength
+	plan.FdtPackets = fdtPlan.Packets
+
+	// FDT 在整个发送期间按 FDTCarouselMode 轮播，占用的时间又会拉长总时长，迭代到收敛
+	plan.FdtTransfers = 1
+	if opts.paced() {
+		fdtTime := opts.duration(fdtPlan.Packets, fdtPlan.PayloadBytes)
+		cycle := cfg.FDTCarouselMode.Interval
+		switch cfg.FDTCarouselMode.Choice {
+		case DelayBetweenTransfers:
+			cycle += fdtTime
+		case IntervalBetweenStartTimes:
+			cycle = max(cycle, fdtTime)
+		}
+		if cycle <= 0 {
+			return nil, errors.New("plan: FDT carousel interval must be > 0")
+		}
+		total := dataTime + fdtTime
+		for range 64 {
+			n := 1 + uint64(total/cycle)
+			next := dataTime + time.Duration(n)*fdtTime
+			if n == plan.FdtTransfers && next == total {
+				break
+			}
+			plan.FdtTransfers, total = n, next
+		}
+		plan.Duration = max(total, minTime)
+	}
+	plan.FdtPayloadBytes = fdtPlan.PayloadBytes * plan.FdtTransfers
+	plan.FdtWireBytes = fdtPlan.WireBytes * plan.FdtTransfers
+	plan.Packets += plan.FdtPackets * plan.FdtTransfers
+	plan.PayloadBytes += plan.FdtPayloadBytes
+	plan.WireBytes += plan.FdtWireBytes
+	return plan, nil
+}
+
+// planFile 按 BlockEncoder 的分块方式统计一个对象的包数与字节数
+func planFile(fd *FileDesc, tsi uint64, cfg *Config, overhead int, now time.Time) PlanFile {
+	o := &fd.Oti
+	obj := fd.Object
+	pf := PlanFile{
+		ContentLocation: contentLocation(obj),
+		Priority:        fd.Priority,
+		TransferLength:  obj.TransferLength,
+		Transfers:       max(obj.MaxTransferCount, 1),
+		Endless:         obj.CarouselMode != nil && obj.MaxTransferCount == 0 && fd.FdtID == nil,
+	}
+
+	esl := uint64(o.EncodingSymbolLength)
+	aLarge, aSmall, nbALarge, nbBlocks := object.BlockPartitioning(uint64(o.MaximumSourceBlockLength), obj.TransferLength, esl)
+	pf.Blocks = nbBlocks
+	var payload, remaining uint64 = 0, obj.TransferLength
+	for sbn := uint64(0); sbn < nbBlocks; sbn++ {
+		blockLen := aSmall
+		if sbn < nbALarge {
+			blockLen = aLarge
+		}
+		size := min(blockLen*esl, remaining)
+		remaining -= size
+		nbSource := tools.DivCeil(size, esl)
+		pf.SourcePackets += nbSource
+		switch o.FecEncodingID {
+		case oti.NoCode:
+			payload += size // 最后一个符号不补齐
+		default:
+			// Reed-Solomon：符号补齐到 ESL，每块附带固定数量的校验符号
+			pf.ParityPackets += uint64(o.MaxNumberOfParitySymbols)
+			payload += (nbSource + uint64(o.MaxNumberOfParitySymbols)) * esl
+		}
+	}
+	perTransfer := pf.SourcePackets + pf.ParityPackets
+	if perTransfer == 0 {
+		// 空对象也要发一个带 Close-Object 的包
+		perTransfer = 1
+	}
+
+	pf.HeaderLength = planHeaderLength(fd, tsi, cfg, now)
+	pf.Packets = perTransfer * uint64(pf.Transfers)
+	pf.PayloadBytes = (payload + perTransfer*uint64(pf.HeaderLength)) * uint64(pf.Transfers)
+	pf.WireBytes = pf.PayloadBytes + pf.Packets*uint64(overhead)
+	return pf
+}
+
+// planHeaderLength 与 BlockEncoder 相同字段构造的空载荷包长度，即每包 ALC 头长度
+func planHeaderLength(fd *FileDesc, tsi uint64, cfg *Config, now time.Time) int {
+	pkt := &object.Pkt{
+		TransferLength:    fd.Object.TransferLength,
+		Toi:               fd.TOI,
+		FdtID:             fd.FdtID,
+		Cenc:              fd.Object.Cenc,
+		InbandCenc:        fd.Object.InbandCenc,
+		SenderCurrentTime: fd.SenderCurrentTime,
+	}
+	n := len(alc.NewAlcPkt(&fd.Oti, t.Uint128{}, tsi, pkt, cfg.Profile, now))
+	if cfg.Authenticator != nil {
+		n += cfg.Authenticator.MaxExtLength()
+	}
+	return n
+}
+
+// carouselMinDuration 轮播间隔决定的最短时长：多轮传输之间至少相隔 Interval
+func carouselMinDuration(obj *ObjectDesc, pf PlanFile) time.Duration {
+	if obj.CarouselMode == nil || pf.Transfers <= 1 {
+		return pf.Duration
+	}
+	per := pf.Duration / time.Duration(pf.Transfers)
+	gaps := time.Duration(pf.Transfers - 1)
+	switch obj.CarouselMode.Choice {
+	case IntervalBetweenStartTimes:
+		return gaps*max(obj.CarouselMode.Interval, per) + per
+	default:
+		return gaps*(obj.CarouselMode.Interval+per) + per
+	}
+}
+
+func (opts *PlanOptions) paced() bool {
+	return opts.RateBps > 0 || opts.PacketInterval > 0
+}
+
+// duration 发送 packets 个包、共 bytes 字节载荷的时长
+func (opts *PlanOptions) duration(packets, bytes uint64) time.Duration {
+	d := time.Duration(packets) * opts.PacketInterval
+	if opts.RateBps > 0 {
+		d = max(d, rateDuration(bytes, opts.RateBps))
+	}
+	return d
+}
+
+func rateDuration(bytes, rateBps uint64) time.Duration {
+	return time.Duration(float64(bytes) * 8 / float64(rateBps) * float64(time.Second))
+}
+
+func contentLocation(obj *ObjectDesc) string {
+	if obj.ContentLocation == nil {
+		return ""
+	}
+	return obj.ContentLocation.String()
+}
@@ -0,0 +1,93 @@
+package sender
+
+import (
+	"Flute_go/pkg/alc"
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/transport"
+	"testing"
+	"time"
+)
+
+// 规划结果与实际发送的包数、字节数一致
+func TestPlanMatchesSender(t *testing.T) {
+	rs, _ := oti.NewReedSolomonRS28(1024, 60, 4)
+	for _, o := range []*oti.Oti{oti.NewNoCode(1024, 64), rs} {
+		now := time.Now()
+		obj := createObj(100*1024 + 17)
+		obj.MaxTransferCount = 2
+
+		plan, err := PlanTransfer(1, o, nil, []PlanItem{{Priority: 0, Object: obj}}, PlanOptions{Now: now})
+		if err != nil {
+			t.Fatalf("%s: PlanTransfer failed: %v", o.FecEncodingID, err)
+		}
+		if obj.Toi != nil {
+			t.Fatalf("%s: planning must not modify the object", o.FecEncodingID)
+		}
+
+		endpoint := transport.NewUDPEndpoint(nil, "224.0.0.1", 1234)
+		s := NewSender(endpoint, 1, o, nil)
+		if _, err := s.AddObject(0, obj); err != nil {
+			t.Fatalf("AddObject failed: %v", err)
+		}
+		if err := s.Publish(now); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		var dataPkts, dataBytes, fdtPkts, fdtBytes uint64
+		for data := s.Read(now); data != nil; data = s.Read(now) {
+			pkt, err := alc.ParseAlcPkt(data)
+			if err != nil {
+				t.Fatalf("ParseAlcPkt failed: %v", err)
+			}
+			if pkt.Lct.Toi == lct.TOI_FDT {
+				fdtPkts++
+				fdtBytes += uint64(len(data))
+			} else {
+				dataPkts++
+				dataBytes += uint64(len(data))
+			}
+		}
+
+		pf := plan.Files[0]
+		if pf.Packets != dataPkts || pf.PayloadBytes != dataBytes {
+			t.Fatalf("%s: planned %d packets / %d bytes, sent %d / %d",
+				o.FecEncodingID, pf.Packets, pf.PayloadBytes, dataPkts, dataBytes)
+		}
+		if plan.FdtPackets != fdtPkts || plan.FdtPayloadBytes != fdtBytes {
+			t.Fatalf("%s: planned FDT %d packets / %d bytes, sent %d / %d",
+				o.FecEncodingID, plan.FdtPackets, plan.FdtPayloadBytes, fdtPkts, fdtBytes)
+		}
+		if plan.WireBytes != plan.PayloadBytes+plan.Packets*IPv4UDPOverhead {
+			t.Fatalf("%s: wire bytes must include IP/UDP overhead", o.FecEncodingID)
+		}
+	}
+}
+
+func TestPlanDuration(t *testing.T) {
+	o := oti.NewNoCode(1400, 64)
+	obj := createObj(10 * 1000 * 1000)
+	cfg := DefaultConfig()
+	cfg.FDTCarouselMode = CarouselRepeatMode{Choice: DelayBetweenTransfers, Interval: time.Second}
+
+	plan, err := PlanTransfer(1, o, &cfg, []PlanItem{{Object: obj}}, PlanOptions{RateBps: 10_000_000})
+	if err != nil {
+		t.Fatalf("PlanTransfer failed: %v", err)
+	}
+	// 10 MB 载荷 @ 10 Mbit/s ≈ 8 s，FDT 约每秒一次
+	if plan.Duration < 8*time.Second || plan.Duration > 9*time.Second {
+		t.Fatalf("unexpected duration %s", plan.Duration)
+	}
+	if plan.FdtTransfers < 8 || plan.FdtTransfers > 10 {
+		t.Fatalf("unexpected FDT transfers %d", plan.FdtTransfers)
+	}
+
+	// 固定发包间隔比限速更慢时按间隔估算
+	paced, err := PlanTransfer(1, o, &cfg, []PlanItem{{Object: obj}},
+		PlanOptions{RateBps: 10_000_000, PacketInterval: 2 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("PlanTransfer failed: %v", err)
+	}
+	if f := paced.Files[0]; f.Duration != time.Duration(f.Packets)*2*time.Millisecond {
+		t.Fatalf("packet interval ignored: %d packets in %s", f.Packets, f.Duration)
+	}
+}
@@ -0,0 +1,252 @@
+package sender
+
+import (
+	"Flute_go/pkg/tools"
+	t "Flute_go/pkg/type"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ScheduleRule 传输计划：Cron 与 Every 二选一
+type ScheduleRule struct {
+	// 五段式 cron 表达式（分 时 日 月 周），按 Location 解释（nil = time.Local）
+	Cron     string
+	Location *time.Location
+	// 固定间隔，从 Start 起（零值 = 加入时刻）
+	Every time.Duration
+	Start time.Time
+	// 每个窗口的时长；0 表示窗口开始时只发送 MaxTransferCount 次
+	Window time.Duration
+	// 之后不再调度并移出 FDT（零值 = 不限）
+	Until time.Time
+}
+
+// schedule 解析后的计划
+type schedule struct {
+	rule ScheduleRule
+	cron *cronSpec
+}
+
+func newSchedule(rule ScheduleRule) (*schedule, error) {
+	if (rule.Cron == "") == (rule.Every == 0) {
+		return nil, errors.New("schedule: exactly one of Cron and Every must be set")
+	}
+	if rule.Every < 0 || rule.Window < 0 {
+		return nil, errors.New("schedule: negative duration")
+	}
+	sc := &schedule{rule: rule}
+	if rule.Cron != "" {
+		c, err := parseCron(rule.Cron)
+		if err != nil {
+			return nil, err
+		}
+		sc.cron = c
+		if sc.rule.Location == nil {
+			sc.rule.Location = time.Local
+		}
+	}
+	return sc, nil
+}
+
+// nextAtOrAfter 返回 >= t 的第一个窗口开始时刻；没有时返回零值
+func (sc *schedule) nextAtOrAfter(t time.Time) time.Time {
+	var next time.Time
+	if sc.cron != nil {
+		next = sc.cron.nextAtOrAfter(t.In(sc.rule.Location))
+	} else {
+		next = sc.rule.Start
+		if t.After(next) {
+			n := (t.Sub(next) + sc.rule.Every - 1) / sc.rule.Every
+			next = next.Add(n * sc.rule.Every)
+		}
+	}
+	if next.IsZero() || (!sc.rule.Until.IsZero() && !next.Before(sc.rule.Until)) {
+		return time.Time{}
+	}
+	return next
+}
+
+func (sc *schedule) window(start time.Time) TransferWindow {
+	w := TransferWindow{Start: start}
+	if sc.rule.Window > 0 {
+		end := start.Add(sc.rule.Window)
+		if !sc.rule.Until.IsZero() && end.After(sc.rule.Until) {
+			end = sc.rule.Until
+		}
+		w.End = &end
+	}
+	return w
+}
+
+type scheduledObject struct {
+	sched   *schedule
+	current *TransferWindow // 当前打开的窗口
+	next    time.Time       // 下一个窗口的开始时刻，零值 = 没有了
+}
+
+// Scheduler 按计划打开对象的传输窗口，并在 FDT 中通告当前/下一个窗口
+// 与 HotFolder 一样由发送循环周期调用 Tick，不另起 goroutine
+type Scheduler struct {
+	sender  *Sender
+	logger  *slog.Logger
+	objects map[t.Uint128]*scheduledObject
+}
+
+// NewScheduler logger 为 nil 时使用 slog.Default()
+func NewScheduler(s *Sender, logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		sender:  s,
+		logger:  tools.LoggerOrDefault(logger).With("component", "scheduler"),
+		objects: make(map[t.Uint128]*scheduledObject),
+	}
+}
+
+// Add 加入对象并按计划传输；对象在第一个窗口开始前不会发送。调用方随后 Publish
+func (s *Scheduler) Add(priority uint32, obj *ObjectDesc, rule ScheduleRule, now time.Time) (t.Uint128, error) {
+	sc, err := newSchedule(rule)
+	if err != nil {
+		return t.Uint128{}, err
+	}
+	first := s.firstStart(sc, now)
+	if first.IsZero() {
+		return t.Uint128{}, fmt.Errorf("schedule: no transfer window after %s", now.Format(time.RFC3339))
+	}
+	toi, err := s.sender.AddObject(priority, obj)
+	if err != nil {
+		return t.Uint128{}, err
+	}
+	so := &scheduledObject{sched: sc}
+	s.objects[toi] = so
+	s.open(so, s.sender.fdt.files[toi.String()], first)
+	return toi, nil
+}
+
+// Schedule 为已在 FDT 中的对象设置计划（替换原计划）。调用方随后 Publish
+func (s *Scheduler) Schedule(toi t.Uint128, rule ScheduleRule, now time.Time) error {
+	fd, ok := s.sender.fdt.files[toi.String()]
+	if !ok {
+		return fmt.Errorf("object %s is not in the FDT", toi.String())
+	}
+	sc, err := newSchedule(rule)
+	if err != nil {
+		return err
+	}
+	first := s.firstStart(sc, now)
+	if first.IsZero() {
+		return fmt.Errorf("schedule: no transfer window after %s", now.Format(time.RFC3339))
+	}
+	so := &scheduledObject{sched: sc}
+	s.objects[toi] = so
+	s.open(so, fd, first)
+	return nil
+}
+
+// Unschedule 取消计划，对象仍留在 FDT 中，但不会再有新的窗口
+func (s *Scheduler) Unschedule(toi t.Uint128) bool {
+	if _, ok := s.objects[toi]; !ok {
+		return false
+	}
+	delete(s.objects, toi)
+	if fd, ok := s.sender.fdt.files[toi.String()]; ok {
+		fd.Object.TransferWindows = nil
+	}
+	return true
+}
+
+// firstStart 首个窗口：若 now 落在某个窗口内，从该窗口开始
+func (s *Scheduler) firstStart(sc *schedule, now time.Time) time.Time {
+	if sc.cron == nil && sc.rule.Start.IsZero() {
+		sc.rule.Start = now
+	}
+	return sc.nextAtOrAfter(now.Add(-sc.rule.Window))
+}
+
+// open 打开从 start 开始的窗口（开始前对象不会发送），并更新通告
+func (s *Scheduler) open(so *scheduledObject, fd *FileDesc, start time.Time) {
+	w := so.sched.window(start)
+	fd.SetTransferWindow(w.Start, w.End)
+	so.current = &w
+	so.next = so.sched.nextAtOrAfter(start.Add(time.Nanosecond))
+	s.advertise(so, fd)
+}
+
+// advertise 在 FDT 中通告当前窗口和下一个窗口
+func (s *Scheduler) advertise(so *scheduledObject, fd *FileDesc) {
+	var windows []TransferWindow
+	if so.current != nil {
+		windows = append(windows, *so.current)
+	}
+	if !so.next.IsZero() {
+		windows = append(windows, so.sched.window(so.next))
+	}
+	fd.Object.TransferWindows = windows
+}
+
+// Tick 打开到期的窗口、移除计划已结束的对象并更新通告的窗口
+// 返回本次是否发布了新的 FDT
+func (s *Scheduler) Tick(now time.Time) (bool, error) {
+	changed := false
+	for toi, so := range s.objects {
+		fd, ok := s.sender.fdt.files[toi.String()]
+		if !ok {
+			// 已被外部移除
+			delete(s.objects, toi)
+			continue
+		}
+
+		if !so.sched.rule.Until.IsZero() && !now.Before(so.sched.rule.Until) {
+			s.sender.RemoveObject(toi)
+			delete(s.objects, toi)
+			s.logger.Info("schedule ended", "toi", toi.String())
+			changed = true
+			continue
+		}
+
+		if !so.next.IsZero() && !now.Before(so.next) {
+			// 跳过已经错过的窗口
+			start := so.next
+			for {
+				n := so.sched.nextAtOrAfter(start.Add(time.Nanosecond))
+				if n.IsZero() || now.Before(n) {
+					break
+				}
+				start = n
+			}
+			s.open(so, fd, start)
+			s.logger.Info("transfer window opened", "toi", toi.String(), "start", start)
+			changed = true
+		}
+
+		// 窗口结束后不再通告；无结束时间的窗口在开始后即不再通告
+		if so.current != nil {
+			end := so.current.Start
+			if so.current.End != nil {
+				end = *so.current.End
+			}
+			if !now.Before(end) {
+				so.current = nil
+				s.advertise(so, fd)
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return false, nil
+	}
+	if err := s.sender.Publish(now); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Next 对象下一个窗口的开始时刻
+func (s *Scheduler) Next(toi t.Uint128) (time.Time, bool) {
+	so, ok := s.objects[toi]
+	if !ok || so.next.IsZero() {
+		return time.Time{}, false
+	}
+	return so.next, true
+}
@@ -0,0 +1,109 @@
+package sender
+
+import (
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/transport"
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2024, 3, 1, 10, 17, 30, 0, time.UTC) // 周五
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 1, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2024, 3, 2, 2, 0, 0, 0, time.UTC)},
+		{"30 8-9 * * 1-5", time.Date(2024, 3, 4, 8, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1 * 0", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		spec, err := parseCron(c.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", c.expr, err)
+		}
+		if got := spec.nextAtOrAfter(base); !got.Equal(c.want) {
+			t.Errorf("%q: next = %s, want %s", c.expr, got, c.want)
+		}
+	}
+
+	for _, bad := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := parseCron(bad); err == nil {
+			t.Errorf("parseCron(%q) must fail", bad)
+		}
+	}
+}
+
+func TestSchedulerWindows(t *testing.T) {
+	o := oti.NewOti()
+	endpoint := transport.NewUDPEndpoint(nil, "224.0.0.1", 1234)
+	sender := NewSender(endpoint, 1, o, nil)
+	sched := NewScheduler(sender, nil)
+
+	t0 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	rule := ScheduleRule{Every: time.Hour, Start: t0.Add(10 * time.Minute), Window: 5 * time.Minute}
+	toi, err := sched.Add(0, createObj(int(o.EncodingSymbolLength)*2), rule, t0)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := sender.Publish(t0); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	xmlData, _ := sender.FdtXMLData(t0)
+	if bytes.Count(xmlData, []byte("<Transfer-Window")) != 2 {
+		t.Fatalf("FDT must advertise the current and the next window:\n%s", xmlData)
+	}
+
+	// 窗口开始前不发送
+	for sender.Read(t0) != nil {
+	}
+	if n := *sender.NbTransfers(toi); n != 0 {
+		t.Fatalf("object sent before its window: %d transfers", n)
+	}
+
+	// 窗口内发送 MaxTransferCount 次，之后等待下一个窗口
+	open := t0.Add(11 * time.Minute)
+	if _, err := sched.Tick(open); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	for sender.Read(open) != nil {
+	}
+	if n := *sender.NbTransfers(toi); n != 1 {
+		t.Fatalf("expected 1 transfer in the window, got %d", n)
+	}
+	if !sender.IsAdded(toi) {
+		t.Fatalf("scheduled object must not expire between windows")
+	}
+
+	// 窗口结束：只通告下一个窗口
+	closed := t0.Add(20 * time.Minute)
+	published, err := sched.Tick(closed)
+	if err != nil || !published {
+		t.Fatalf("Tick must republish the FDT when a window closes: %v", err)
+	}
+	xmlData, _ = sender.FdtXMLData(closed)
+	if bytes.Count(xmlData, []byte("<Transfer-Window")) != 1 {
+		t.Fatalf("FDT must advertise only the next window:\n%s", xmlData)
+	}
+	if next, ok := sched.Next(toi); !ok || !next.Equal(t0.Add(70*time.Minute)) {
+		t.Fatalf("unexpected next window %s", next)
+	}
+
+	// 错过的窗口被跳过，只打开最近的一个
+	late := t0.Add(3*time.Hour + 12*time.Minute)
+	if _, err := sched.Tick(late); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	for sender.Read(late) != nil {
+	}
+	if n := *sender.NbTransfers(toi); n != 2 {
+		t.Fatalf("expected 2 transfers after the second window, got %d", n)
+	}
+	if next, _ := sched.Next(toi); !next.Equal(t0.Add(4*time.Hour + 10*time.Minute)) {
+		t.Fatalf("unexpected next window %s", next)
+	}
+}
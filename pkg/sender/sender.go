@@ -2,14 +2,20 @@ package sender
 
 import (
 	"Flute_go/pkg/alc"
+	"Flute_go/pkg/clock"
 	"Flute_go/pkg/lct"
+	"Flute_go/pkg/metrics"
+	"Flute_go/pkg/object"
 	"Flute_go/pkg/oti"
 	"Flute_go/pkg/profile"
+	"Flute_go/pkg/tools"
 	"Flute_go/pkg/transport"
 	t "Flute_go/pkg/type"
 	"errors"
 	"fmt"
+	"log/slog"
 	"sort"
+	"strconv"
 	"time"
 )
 
@@ -66,6 +72,27 @@ type Config struct {
 
 	// FDT-Instance 的 group 列表
 	Groups []string
+
+	// 源认证（如 tesla.Sender），nil 表示不携带 EXT_AUTH
+	Authenticator alc.Authenticator
+
+	// FDT-Instance 签名（Ed25519/ECDSA），nil 表示不签名
+	FDTSigner *object.FdtSigner
+
+	// 内容密钥查找，用于加密设置了 ContentKeyID 的对象
+	KeyProvider object.KeyProvider
+
+	// 发送端指标（如 metrics.Registry），nil 表示不采集
+	Metrics metrics.SenderMetrics
+
+	// 结构化日志，nil 使用 slog.Default()；逐包/逐符号日志仅在 Debug 级别输出
+	Logger *slog.Logger
+
+	// 持久化 TOI 计数器、FDT-Instance ID 与对象目录，重启后继续（如 FileStateStore），nil 不持久化
+	StateStore StateStore
+
+	// 时间源，nil 使用系统时钟；测试/仿真可注入 clock.Virtual
+	Clock clock.Clock
 }
 
 func DefaultConfig() Config {
@@ -111,8 +138,12 @@ type Sender struct {
 	observers   *ObserverList // 指针
 	tsi         uint64
 	udpEndpoint transport.UDPEndpoint
+	keyProvider object.KeyProvider
+	metrics     metrics.SenderMetrics
+	clock       clock.Clock
 }
 
+// NewSender cfg 应先经 ValidateConfig 检查；FDT 编码无法用 EXT_CENC 告知时 panic
 func NewSender(endpoint transport.UDPEndpoint, tsi uint64, o *oti.Oti, cfg *Config) *Sender {
 	if cfg == nil {
 		def := DefaultConfig()
@@ -120,8 +151,16 @@ func NewSender(endpoint transport.UDPEndpoint, tsi uint64, o *oti.Oti, cfg *Conf
 	}
 
 	observers := NewObserverList()
+	logger := tools.LoggerOrDefault(cfg.Logger).With("tsi", tsi)
+
+	m := cfg.Metrics
+	if m == nil {
+		m = metrics.Nop{}
+	} else {
+		observers.Subscribe(&metricsSubscriber{metrics: m, tsi: tsi})
+	}
 
-	fdt := NewFdt(
+	fdt, err := NewFdt(
 		tsi,
 		cfg.FDTStartID,
 		o,
@@ -134,7 +173,28 @@ func NewSender(endpoint transport.UDPEndpoint, tsi uint64, o *oti.Oti, cfg *Conf
 		cfg.TOIInitialValue,
 		&cfg.Groups,
 		cfg.FDTPublishMode,
+		cfg.FDTSigner,
+		cfg.Clock,
+		logger,
 	)
+	if err != nil {
+		panic("NewSender: " + err.Error())
+	}
+	if cfg.StateStore != nil {
+		st, err := cfg.StateStore.Load()
+		switch {
+		case err != nil:
+			logger.Error("fail to load sender state, starting fresh", "err", err)
+		case st != nil && st.TSI == tsi:
+			fdt.restore(st)
+			logger.Info("sender state restored", "next_toi", st.NextToi,
+				"next_fdt_id", st.NextFdtID, "objects", len(st.Objects))
+		case st != nil:
+			logger.Warn("sender state belongs to another TSI, ignored", "state_tsi", st.TSI)
+		}
+		fdt.state = cfg.StateStore
+		fdt.record(StateRecord{Op: StateOpSnapshot, Snapshot: fdt.snapshot()})
+	}
 
 	fdtSession := NewSenderSession(
 		0,
@@ -143,6 +203,8 @@ func NewSender(endpoint transport.UDPEndpoint, tsi uint64, o *oti.Oti, cfg *Conf
 		true, // transfer_fdt_only
 		cfg.Profile,
 		endpoint,
+		cfg.Authenticator,
+		logger,
 	)
 
 	// 构建优先级队列的会话列表
@@ -164,6 +226,8 @@ func NewSender(endpoint transport.UDPEndpoint, tsi uint64, o *oti.Oti, cfg *Conf
 				false, // transfer_fdt_only
 				cfg.Profile,
 				endpoint,
+				cfg.Authenticator,
+				logger,
 			)
 			list.sessions = append(list.sessions, ss)
 		}
@@ -177,9 +241,22 @@ func NewSender(endpoint transport.UDPEndpoint, tsi uint64, o *oti.Oti, cfg *Conf
 		observers:   observers,
 		tsi:         tsi,
 		udpEndpoint: endpoint,
+		keyProvider: cfg.KeyProvider,
+		metrics:     m,
+		clock:       clock.OrReal(cfg.Clock),
 	}
 }
 
+// Now 当前时间（来自 Config.Clock），发送循环与控制面应以此调用 Read/Publish
+func (s *Sender) Now() time.Time {
+	return s.clock.Now()
+}
+
+// Clock 发送端使用的时间源
+func (s *Sender) Clock() clock.Clock {
+	return s.clock
+}
+
 // Subscribe / Unsubscribe
 func (s *Sender) Subscribe(sub Subscriber) {
 	s.observers.Subscribe(sub)
@@ -201,6 +278,13 @@ func (s *Sender) AddObject(priority uint32, obj *ObjectDesc) (uint128 t.Uint128,
 	if _, ok := s.sessions[priority]; !ok {
 		return t.Uint128{}, errors.New(fmt.Sprintf("priority queue %d does not exist", priority))
 	}
+	// 先校验再加密：校验失败时对象内容保持原样
+	if err := ValidateObject(&s.fdt.oti, obj); err != nil {
+		return t.Uint128{}, err
+	}
+	if err := encryptObject(obj, s.keyProvider); err != nil {
+		return t.Uint128{}, err
+	}
 	toi, e := s.fdt.AddObject(priority, obj)
 	if e != nil {
 		return t.Uint128{
@@ -211,6 +295,32 @@ func (s *Sender) AddObject(priority uint32, obj *ObjectDesc) (uint128 t.Uint128,
 	return t.StringToUint128(toi), nil
 }
 
+// UpdateObject 更新 Content-Location 对应的对象：分配新 TOI 并替换进 FDT，
+// 旧 TOI 在当前轮次结束后停止发送；Content-Version 递增，接收端据此淘汰旧副本
+// 不存在同 Content-Location 的对象时等同 AddObject。调用方随后 Publish
+func (s *Sender) UpdateObject(priority uint32, obj *ObjectDesc) (t.Uint128, error) {
+	if _, ok := s.sessions[priority]; !ok {
+		return t.Uint128{}, fmt.Errorf("priority queue %d does not exist", priority)
+	}
+	if err := ValidateObject(&s.fdt.oti, obj); err != nil {
+		return t.Uint128{}, err
+	}
+	if err := encryptObject(obj, s.keyProvider); err != nil {
+		return t.Uint128{}, err
+	}
+	toi, _, err := s.fdt.UpdateObject(priority, obj)
+	if err != nil {
+		return t.Uint128{}, err
+	}
+	return t.StringToUint128(toi), nil
+}
+
+// RestoredObjects 重启前在 FDT 中、尚未重新加入的对象；以相同 Content-Location
+// 和内容重新 AddObject 时沿用原 TOI
+func (s *Sender) RestoredObjects() []ObjectState {
+	return s.fdt.RestoredObjects()
+}
+
 func (s *Sender) TriggerTransferAt(toi t.Uint128, ts *time.Time) bool {
 	var when *time.Time
 	if ts != nil {
@@ -265,6 +375,7 @@ func (s *Sender) GetObjectsInFDT() map[string]*ObjectDesc {
 func (s *Sender) Read(now time.Time) []byte {
 	// 先让 fdtSession 尝试产生 FDT 包
 	if data := s.fdtSession.Run(s.fdt, now); data != nil {
+		s.metrics.PacketSent(s.tsi, metrics.QueueFDT, len(data))
 		return data
 	}
 
@@ -279,6 +390,7 @@ func (s *Sender) Read(now time.Time) []byte {
 
 		for _, prio := range keys {
 			if data := s.readPriorityQueue(s.fdt, s.sessions[prio], now); data != nil {
+				s.metrics.PacketSent(s.tsi, strconv.FormatUint(uint64(prio), 10), len(data))
 				return data
 			}
 		}
@@ -286,6 +398,7 @@ func (s *Sender) Read(now time.Time) []byte {
 
 	// 再次尝试 FDT（与 Rust 一致）
 	if data := s.fdtSession.Run(s.fdt, now); data != nil {
+		s.metrics.PacketSent(s.tsi, metrics.QueueFDT, len(data))
 		return data
 	}
 
@@ -317,3 +430,22 @@ func (s *Sender) readPriorityQueue(fdt *Fdt, list *senderSessionList, now time.T
 	}
 	return nil
 }
+
+// metricsSubscriber 把 Sender 事件转成指标
+type metricsSubscriber struct {
+	metrics metrics.SenderMetrics
+	tsi     uint64
+}
+
+func (m *metricsSubscriber) OnSenderEvent(evt Event, _ time.Time) {
+	switch evt.Kind {
+	case EventStartTransfer:
+		m.metrics.ObjectTransferStarted(m.tsi, evt.File.Toi)
+	case EventStopTransfer:
+		m.metrics.ObjectTransferStopped(m.tsi, evt.File.Toi)
+	case EventFDTPublished:
+		m.metrics.FDTPublished(m.tsi, evt.FdtInstanceID)
+	case EventCarouselCycleCompleted:
+		m.metrics.CarouselCycleCompleted(m.tsi, evt.File.Toi)
+	}
+}
@@ -2,11 +2,26 @@ package sender
 
 import (
 	"Flute_go/pkg/lct"
+	"Flute_go/pkg/object"
 	"Flute_go/pkg/oti"
 	"Flute_go/pkg/transport"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // createObj 创建一个指定长度的 ObjectDesc
@@ -121,3 +136,301 @@ func TestSenderComplete(t *testing.T) {
 		t.Fatalf("expected error when adding after complete, got nil")
 	}
 }
+
+func TestSenderEncryptedObject(t *testing.T) {
+	o, err := oti.NewReedSolomonRS28(100, 64, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := transport.NewUDPEndpoint(nil, "224.0.0.1", 1234)
+	keys := object.StaticKeyProvider{"paid": bytes.Repeat([]byte{7}, 16)}
+	cfg := DefaultConfig()
+	cfg.KeyProvider = keys
+	sender := NewSender(endpoint, 1, o, &cfg)
+
+	content := []byte("premium content")
+	u, _ := url.Parse("file:///paid")
+	obj, err := CreateFromBuffer(content, "text", u, 1, nil, nil, nil, nil, lct.CencNull, true, nil, false)
+	if err != nil {
+		t.Fatalf("CreateFromBuffer failed: %v", err)
+	}
+	obj.SetEncryption(object.EncryptionAESGCM, "paid")
+
+	if _, err := sender.AddObject(0, obj); err != nil {
+		t.Fatalf("AddObject failed: %v", err)
+	}
+	if obj.TransferLength != uint64(len(content))+16 {
+		t.Fatalf("unexpected transfer length %d", obj.TransferLength)
+	}
+
+	buf, err := sender.FdtXMLData(time.Now())
+	if err != nil {
+		t.Fatalf("FdtXMLData failed: %v", err)
+	}
+	inst, err := object.ParseFdtInstance(buf)
+	if err != nil {
+		t.Fatalf("ParseFdtInstance failed: %v", err)
+	}
+	if err := sender.Publish(time.Now()); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	// 解密接收端能重组出的数据，而不是发送端内部缓冲
+	pkts := readDataPkts(t, sender, time.Now())
+	if len(pkts) == 0 {
+		t.Fatal("no data packets sent")
+	}
+	payload := decodeSource(t, pkts)
+	if bytes.Contains(payload, content) {
+		t.Fatal("payload sent in clear")
+	}
+	plain, err := inst.Files[0].DecryptFile(payload, keys)
+	if err != nil {
+		t.Fatalf("DecryptFile failed: %v", err)
+	}
+	if !bytes.Equal(plain, content) {
+		t.Fatalf("decrypted content mismatch")
+	}
+}
+
+// 摄取系统的 span 经 FDT 传到接收端，接收 span 与之处于同一 trace
+func TestSenderTraceContext(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	ctx, ingest := provider.Tracer("ingest").Start(context.Background(), "ingest")
+	defer ingest.End()
+
+	obj := createObj(10)
+	if err := obj.SetTraceContext("00-bogus", ""); err == nil {
+		t.Fatal("invalid traceparent accepted")
+	}
+	if err := obj.SetTraceContextFrom(context.Background()); err == nil {
+		t.Fatal("context without a span accepted")
+	}
+	if err := obj.SetTraceContextFrom(ctx); err != nil {
+		t.Fatalf("SetTraceContextFrom failed: %v", err)
+	}
+
+	sender := NewSender(transport.NewUDPEndpoint(nil, "224.0.0.1", 1234), 1, oti.NewOti(), nil)
+	if _, err := sender.AddObject(0, obj); err != nil {
+		t.Fatalf("AddObject failed: %v", err)
+	}
+	buf, err := sender.FdtXMLData(time.Now())
+	if err != nil {
+		t.Fatalf("FdtXMLData failed: %v", err)
+	}
+	inst, err := object.ParseFdtInstance(buf)
+	if err != nil {
+		t.Fatalf("ParseFdtInstance failed: %v", err)
+	}
+
+	_, span := object.StartReceptionSpan(context.Background(), provider, &inst.Files[0], time.Now())
+	span.End()
+	got := rec.Ended()
+	if len(got) != 1 || got[0].Parent().SpanID() != ingest.SpanContext().SpanID() ||
+		got[0].SpanContext().TraceID() != ingest.SpanContext().TraceID() {
+		t.Fatalf("reception span not in the ingest trace: %+v", got)
+	}
+}
+
+func TestSenderEvents(t *testing.T) {
+	o := oti.NewOti()
+	endpoint := transport.NewUDPEndpoint(nil, "224.0.0.1", 1234)
+	sender := NewSender(endpoint, 1, o, nil)
+	sub := NewChannelSubscriber(256)
+	sender.Subscribe(sub)
+
+	length := int(o.EncodingSymbolLength) * 3
+	if _, err := sender.AddObject(0, createObj(length)); err != nil {
+		t.Fatalf("AddObject failed: %v", err)
+	}
+	if err := sender.Publish(time.Now()); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	for sender.Read(time.Now()) != nil {
+	}
+
+	seen := make(map[EventKind]Event)
+	for len(sub.C) > 0 {
+		ce := <-sub.C
+		seen[ce.Event.Kind] = ce.Event
+	}
+	for _, k := range []EventKind{EventObjectAdded, EventFDTPublished, EventStartTransfer, EventBlockEncoded, EventStopTransfer, EventObjectExpired} {
+		if _, ok := seen[k]; !ok {
+			t.Fatalf("missing %s event", k)
+		}
+	}
+	if seen[EventFDTPublished].NbFiles != 1 {
+		t.Fatalf("FDT published with %d files, want 1", seen[EventFDTPublished].NbFiles)
+	}
+	stop := seen[EventStopTransfer].File
+	if stop.ContentLocation != "file:///hello" || stop.TransferCount != 1 || stop.BytesSent < uint64(length) {
+		t.Fatalf("unexpected stop event file info: %+v", stop)
+	}
+}
+
+func TestSenderUpdateObject(t *testing.T) {
+	o := oti.NewOti()
+	endpoint := transport.NewUDPEndpoint(nil, "224.0.0.1", 1234)
+	sender := NewSender(endpoint, 1, o, nil)
+
+	oldToi, err := sender.AddObject(0, createObj(int(o.EncodingSymbolLength)*3))
+	if err != nil {
+		t.Fatalf("AddObject failed: %v", err)
+	}
+	if err := sender.Publish(time.Now()); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	// 发到旧对象开始传输
+	for !sender.fdt.files[oldToi.String()].IsTransferring() {
+		if sender.Read(time.Now()) == nil {
+			t.Fatalf("old object never started")
+		}
+	}
+
+	newToi, err := sender.UpdateObject(0, createObj(int(o.EncodingSymbolLength)*2))
+	if err != nil {
+		t.Fatalf("UpdateObject failed: %v", err)
+	}
+	if newToi == oldToi || sender.IsAdded(oldToi) || !sender.IsAdded(newToi) {
+		t.Fatalf("update must swap %s for a new TOI, got %s", oldToi, newToi)
+	}
+	if !sender.fdt.IsDraining(oldToi.String()) {
+		t.Fatalf("old TOI must keep sending until its transfer ends")
+	}
+	obj := sender.GetObjectsInFDT()[newToi.String()]
+	if obj.ContentVersion == nil || *obj.ContentVersion != 1 || obj.ETag == nil {
+		t.Fatalf("updated object must carry version 1 and an ETag")
+	}
+
+	xmlData, _ := sender.FdtXMLData(time.Now())
+	if !bytes.Contains(xmlData, []byte(`Content-Version="1"`)) {
+		t.Fatalf("FDT does not advertise Content-Version:\n%s", xmlData)
+	}
+
+	if err := sender.Publish(time.Now()); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	for sender.Read(time.Now()) != nil {
+	}
+	if sender.fdt.IsDraining(oldToi.String()) {
+		t.Fatalf("old TOI still draining after transfer ended")
+	}
+}
+
+func TestCreateFromFileCompressedOnDisk(t *testing.T) {
+	content := bytes.Repeat([]byte("flute on-disk compression "), 40000)
+	path := filepath.Join(t.TempDir(), "big.txt")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := CreateFromFile(path, nil, "text/plain", false, 1, nil, nil, nil, nil, lct.CencGzip, true, nil, true)
+	if err != nil {
+		t.Fatalf("CreateFromFile failed: %v", err)
+	}
+	if obj.Source.Choice != DataStream || obj.Cenc != lct.CencGzip {
+		t.Fatalf("expected a gzip stream source")
+	}
+	if obj.ContentLength != uint64(len(content)) || obj.TransferLength >= obj.ContentLength {
+		t.Fatalf("unexpected lengths: content=%d transfer=%d", obj.ContentLength, obj.TransferLength)
+	}
+	if n, _ := obj.Source.Len(); n != obj.TransferLength {
+		t.Fatalf("transfer length %d does not match spooled size %d", obj.TransferLength, n)
+	}
+	sum := md5.Sum(content)
+	if obj.MD5 == nil || *obj.MD5 != base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Fatalf("MD5 must cover the uncompressed content")
+	}
+
+	zr, err := gzip.NewReader(obj.Source.stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("spooled data does not decompress to the original content: %v", err)
+	}
+}
+
+// 从文件流式发送的对象在移出 FDT 或发送完过期后关闭文件
+func TestFileSourceClosedOnRelease(t *testing.T) {
+	dir := t.TempDir()
+	open := func(name string, cenc lct.Cenc) *ObjectDesc {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, bytes.Repeat([]byte(name), 500), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		obj, err := CreateFromFile(path, nil, "text/plain", false, 1, nil, nil, nil, nil, cenc, true, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+	closed := func(obj *ObjectDesc) bool {
+		_, err := obj.Source.stream.Seek(0, io.SeekStart)
+		return errors.Is(err, os.ErrClosed)
+	}
+
+	s := NewSender(transport.NewUDPEndpoint(nil, "224.0.0.1", 1234), 1, oti.NewOti(), nil)
+	removed := open("removed", lct.CencNull)
+	spooled := open("spooled", lct.CencGzip)
+	expiring := open("expiring", lct.CencNull)
+	toiRemoved, _ := s.AddObject(0, removed)
+	toiSpooled, _ := s.AddObject(0, spooled)
+	if _, err := s.AddObject(0, expiring); err != nil {
+		t.Fatal(err)
+	}
+
+	s.RemoveObject(toiRemoved)
+	s.RemoveObject(toiSpooled)
+	if !closed(removed) || !closed(spooled) {
+		t.Fatalf("removed objects still hold their files open")
+	}
+	if closed(expiring) {
+		t.Fatalf("object closed before it was sent")
+	}
+
+	now := time.Now()
+	if err := s.Publish(now); err != nil {
+		t.Fatal(err)
+	}
+	for s.Read(now) != nil {
+	}
+	if !closed(expiring) {
+		t.Fatalf("expired object still holds its file open")
+	}
+}
+
+// Config.Logger 注入后，块编码与 TOI 分配的日志都走注入的 logger，不落到 slog.Default()
+func TestLoggerInjection(t *testing.T) {
+	var global, injected bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&global, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer slog.SetDefault(prev)
+	logger := slog.New(slog.NewTextHandler(&injected, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	cfg := DefaultConfig()
+	cfg.Logger = logger
+	o, _ := oti.NewReedSolomonRS28(100, 8, 2)
+	s := NewSender(transport.NewUDPEndpoint(nil, "224.0.0.1", 1234), 1, o, &cfg)
+	if _, err := s.AddObject(0, createObj(1000)); err != nil {
+		t.Fatal(err)
+	}
+	s.Publish(time.Now())
+	for s.Read(time.Now()) != nil {
+	}
+
+	// 回退到已分配的 TOI，下一次分配必然冲突
+	a := NewToiAllocator(ToiMax112, nil, nil, logger)
+	a.Restore(a.Allocate().value, nil)
+	a.Allocate()
+
+	out := injected.String()
+	if !strings.Contains(out, "new block") || !strings.Contains(out, "sbn=1") ||
+		!strings.Contains(out, "TOI already reserved") {
+		t.Fatalf("expected block and TOI logs on the injected logger:\n%s", out)
+	}
+	if global.Len() != 0 {
+		t.Fatalf("nothing may be logged to slog.Default():\n%s", global.String())
+	}
+}
@@ -3,9 +3,10 @@ package sender
 import (
 	"Flute_go/pkg/alc"
 	"Flute_go/pkg/profile"
+	"Flute_go/pkg/tools"
 	"Flute_go/pkg/transport"
 	t "Flute_go/pkg/type"
-	"log"
+	"log/slog"
 	"time"
 )
 
@@ -18,10 +19,12 @@ type SenderSession struct {
 	InterleaveBlocks int
 	TransferFdtOnly  bool
 	Profile          profile.Profile
+	Authenticator    alc.Authenticator
+	Logger           *slog.Logger
 }
 
 // NewSenderSession 构造函数
-func NewSenderSession(priority uint32, tsi uint64, interleaveBlocks int, transferFdtOnly bool, profile profile.Profile, endpoint transport.UDPEndpoint) *SenderSession {
+func NewSenderSession(priority uint32, tsi uint64, interleaveBlocks int, transferFdtOnly bool, profile profile.Profile, endpoint transport.UDPEndpoint, auth alc.Authenticator, logger *slog.Logger) *SenderSession {
 	return &SenderSession{
 		Priority:         priority,
 		Endpoint:         endpoint,
@@ -31,6 +34,8 @@ func NewSenderSession(priority uint32, tsi uint64, interleaveBlocks int, transfe
 		InterleaveBlocks: interleaveBlocks,
 		TransferFdtOnly:  transferFdtOnly,
 		Profile:          profile,
+		Authenticator:    auth,
+		Logger:           tools.LoggerOrDefault(logger),
 	}
 }
 
@@ -65,11 +70,12 @@ func (s *SenderSession) Run(fdt *Fdt, now time.Time) []byte {
 		// 如果允许随时停止且文件已经从 FDT 移除，则停止
 		mustStopTransfer := !s.TransferFdtOnly &&
 			file.CanTransferBeStopped() &&
-			!fdt.IsAdded(file.TOI.String())
+			!fdt.IsAdded(file.TOI.String()) &&
+			!fdt.IsDraining(file.TOI.String())
 
 		if mustStopTransfer {
-			log.Printf("File has already been transferred and removed from the FDT, stop transfer %s",
-				file.Object.ContentLocation)
+			s.Logger.Info("file already transferred and removed from the FDT, stop transfer",
+				"toi", file.TOI.String(), "content_location", file.Object.ContentLocation.String())
 		}
 
 		// 若文件设置了“下次发送时间戳”，且时间未到，先返回 nil
@@ -87,8 +93,18 @@ func (s *SenderSession) Run(fdt *Fdt, now time.Time) []byte {
 		// 5) 推进下一次发送时间戳
 		file.IncNextTransferTimestamp()
 
-		// 6) 封装为 ALC/LCT（注意 Toi 常量/CCI）
-		return alc.NewAlcPkt(
+		for _, blk := range encoder.takeEncodedBlocks() {
+			fdt.observers.Dispatch(Event{
+				Kind:              EventBlockEncoded,
+				File:              file.Info(),
+				Sbn:               blk.sbn,
+				NbSourceSymbols:   blk.nbSourceSymbols,
+				NbEncodingSymbols: blk.nbEncodingSymbols,
+			}, now)
+		}
+
+		// 6) 封装为 ALC/LCT（注意 Toi 常量/CCI），可选 EXT_AUTH
+		data := alc.NewAlcPktAuth(
 			&file.Oti,
 			t.Uint128{
 				High: 0,
@@ -98,7 +114,10 @@ func (s *SenderSession) Run(fdt *Fdt, now time.Time) []byte {
 			pkt,
 			s.Profile,
 			now,
+			s.Authenticator,
 		)
+		file.addBytesSent(len(data))
+		return data
 	}
 }
 
@@ -120,9 +139,9 @@ func (s *SenderSession) getNext(fdt *Fdt, now time.Time) {
 
 	file := s.File
 	isLastTransfer := file.IsLastTransfer()
-	encoder, err := NewBlockEncoder(file, s.InterleaveBlocks, isLastTransfer)
+	encoder, err := NewBlockEncoder(file, s.InterleaveBlocks, isLastTransfer, s.Logger)
 	if err != nil {
-		log.Printf("Fail to open Block Encoder: %v", err)
+		s.Logger.Error("fail to open block encoder", "toi", file.TOI.String(), "err", err)
 		s.releaseFile(fdt, now)
 		return
 	}
@@ -0,0 +1,266 @@
+package sender
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ObjectState 对象目录中的一项，用于重启后沿用 TOI / 传输次数 / 版本
+type ObjectState struct {
+	Toi             string  `json:"toi"`
+	ContentLocation string  `json:"content_location"`
+	Priority        uint32  `json:"priority"`
+	ContentLength   uint64  `json:"content_length"`
+	MD5             *string `json:"md5,omitempty"`
+	ETag            *string `json:"etag,omitempty"`
+	ContentVersion  *uint32 `json:"content_version,omitempty"`
+	TotalTransfers  uint64  `json:"total_transfers"`
+}
+
+// SenderState 需要跨重启保留的发送端状态
+type SenderState struct {
+	TSI uint64 `json:"tsi"`
+	// 下一枚待分配的 TOI（Uint128 十六进制）
+	NextToi string `json:"next_toi"`
+	// 下一个 FDT-Instance ID
+	NextFdtID uint32 `json:"next_fdt_id"`
+	// TOI -> 对象；其 TOI 即为保留中的 TOI
+	Objects map[string]ObjectState `json:"objects"`
+}
+
+func newSenderState(tsi uint64) *SenderState {
+	return &SenderState{TSI: tsi, Objects: make(map[string]ObjectState)}
+}
+
+func (s *SenderState) clone() *SenderState {
+	c := *s
+	c.Objects = make(map[string]ObjectState, len(s.Objects))
+	for k, v := range s.Objects {
+		c.Objects[k] = v
+	}
+	return &c
+}
+
+// StateOp 日志记录类型
+type StateOp string
+
+const (
+	StateOpSnapshot StateOp = "snapshot"
+	StateOpToi      StateOp = "toi"
+	StateOpFdt      StateOp = "fdt"
+	StateOpObject   StateOp = "object"
+	StateOpRemove   StateOp = "remove"
+	StateOpTransfer StateOp = "transfer"
+)
+
+// StateRecord 一条日志记录
+type StateRecord struct {
+	Op        StateOp      `json:"op"`
+	NextToi   string       `json:"next_toi,omitempty"`
+	NextFdtID uint32       `json:"next_fdt_id"` // 不省略：回绕后 0 也是有效的 ID
+	Object    *ObjectState `json:"object,omitempty"`
+	Toi       string       `json:"toi,omitempty"`
+	Transfers uint64       `json:"transfers,omitempty"`
+	Snapshot  *SenderState `json:"snapshot,omitempty"`
+}
+
+// apply 把记录作用到状态上
+func (s *SenderState) apply(r *StateRecord) {
+	switch r.Op {
+	case StateOpSnapshot:
+		if r.Snapshot != nil {
+			*s = *r.Snapshot.clone()
+		}
+	case StateOpToi:
+		s.NextToi = r.NextToi
+	case StateOpFdt:
+		s.NextFdtID = r.NextFdtID
+	case StateOpObject:
+		if r.Object != nil {
+			s.Objects[r.Object.Toi] = *r.Object
+		}
+		if r.NextToi != "" {
+			s.NextToi = r.NextToi
+		}
+	case StateOpRemove:
+		delete(s.Objects, r.Toi)
+	case StateOpTransfer:
+		if o, ok := s.Objects[r.Toi]; ok {
+			o.TotalTransfers = r.Transfers
+			s.Objects[r.Toi] = o
+		}
+	}
+}
+
+// StateStore 发送端状态存储
+type StateStore interface {
+	// Load 返回已保存的状态，没有时返回 (nil, nil)
+	Load() (*SenderState, error)
+	// Record 追加一条状态变化
+	Record(r StateRecord) error
+	Close() error
+}
+
+// FileStateStore 基于文件的日志：每行一条 JSON 记录，追加写入；
+// 记录数超过 CompactEvery 时改写为单条 snapshot（写临时文件后 rename，崩溃安全）
+type FileStateStore struct {
+	path         string
+	CompactEvery int
+	// 每条记录后 fsync（默认开启），关闭可提升吞吐但崩溃时可能丢失最近的记录
+	Sync bool
+
+	mu      sync.Mutex
+	f       *os.File
+	state   *SenderState
+	loaded  bool
+	records int
+}
+
+// OpenFileStateStore 打开（不存在则创建）日志文件并回放
+func OpenFileStateStore(path string) (*FileStateStore, error) {
+	s := &FileStateStore{path: path, CompactEvery: 1000, Sync: true}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.f = f
+	return s, nil
+}
+
+func (s *FileStateStore) replay() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var r StateRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			// 末行不完整（写入时崩溃）：丢弃
+			if !sc.Scan() {
+				break
+			}
+			return fmt.Errorf("state journal %s line %d: %w", s.path, line, err)
+		}
+		if s.state == nil {
+			s.state = newSenderState(0)
+		}
+		s.state.apply(&r)
+		s.records++
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	s.loaded = s.state != nil
+	return nil
+}
+
+func (s *FileStateStore) Load() (*SenderState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.loaded {
+		return nil, nil
+	}
+	return s.state.clone(), nil
+}
+
+func (s *FileStateStore) Record(r StateRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return errors.New("state store closed")
+	}
+	if s.state == nil {
+		s.state = newSenderState(0)
+	}
+	if r.Op == StateOpSnapshot && r.Snapshot != nil {
+		s.state = r.Snapshot.clone()
+	} else {
+		s.state.apply(&r)
+	}
+
+	if s.CompactEvery > 0 && s.records >= s.CompactEvery {
+		return s.compact()
+	}
+	b, err := json.Marshal(&r)
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	s.records++
+	if s.Sync {
+		return s.f.Sync()
+	}
+	return nil
+}
+
+// compact 调用方持锁
+func (s *FileStateStore) compact() error {
+	b, err := json.Marshal(&StateRecord{Op: StateOpSnapshot, Snapshot: s.state})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	s.f.Close()
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		s.f = nil
+		return err
+	}
+	s.f = f
+	s.records = 1
+	return nil
+}
+
+func (s *FileStateStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
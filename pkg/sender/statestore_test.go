@@ -0,0 +1,117 @@
+package sender
+
+import (
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/transport"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSenderStateResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sender.journal")
+	o := oti.NewOti()
+	endpoint := transport.NewUDPEndpoint(nil, "224.0.0.1", 1234)
+
+	newSender := func() (*Sender, *FileStateStore) {
+		store, err := OpenFileStateStore(path)
+		if err != nil {
+			t.Fatalf("OpenFileStateStore failed: %v", err)
+		}
+		store.CompactEvery = 4 // 测试中也走一遍压缩
+		cfg := DefaultConfig()
+		cfg.StateStore = store
+		return NewSender(endpoint, 1, o, &cfg), store
+	}
+
+	s1, store := newSender()
+	toiA, err := s1.AddObject(0, createObj(100))
+	if err != nil {
+		t.Fatalf("AddObject failed: %v", err)
+	}
+	if err := s1.Publish(time.Now()); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	for s1.Read(time.Now()) != nil {
+	}
+	if err := s1.Publish(time.Now()); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	nextFdtID := s1.fdt.fdtID
+	nextToi := s1.fdt.toiAllocator.Next()
+	store.Close()
+
+	// 重启：FDT-Instance ID 与 TOI 计数继续
+	s2, store := newSender()
+	defer store.Close()
+	if s2.fdt.fdtID != nextFdtID {
+		t.Fatalf("FDT instance ID restarted at %d, want %d", s2.fdt.fdtID, nextFdtID)
+	}
+	if s2.fdt.toiAllocator.Next() != nextToi {
+		t.Fatalf("TOI allocator restarted at %s, want %s", s2.fdt.toiAllocator.Next(), nextToi)
+	}
+	if len(s2.RestoredObjects()) != 0 {
+		t.Fatalf("expired object must not be restored")
+	}
+
+	// 新对象不与重启前的 TOI 冲突；同内容重新加入沿用 TOI
+	toiB, err := s2.AddObject(0, createObj(200))
+	if err != nil {
+		t.Fatalf("AddObject failed: %v", err)
+	}
+	if toiB == toiA {
+		t.Fatalf("TOI %s reused after restart", toiA)
+	}
+	store.Close()
+
+	s3, store := newSender()
+	defer store.Close()
+	if got := s3.RestoredObjects(); len(got) != 1 || got[0].Toi != toiB.String() {
+		t.Fatalf("unexpected restored catalog: %+v", got)
+	}
+	toiB2, err := s3.AddObject(0, createObj(200))
+	if err != nil {
+		t.Fatalf("AddObject failed: %v", err)
+	}
+	if toiB2 != toiB {
+		t.Fatalf("re-added object got TOI %s, want %s", toiB2, toiB)
+	}
+	toiC, err := s3.AddObject(0, createObj(300))
+	if err != nil {
+		t.Fatalf("AddObject failed: %v", err)
+	}
+	if toiC == toiB || toiC == toiA {
+		t.Fatalf("TOI collision after restart: %s", toiC)
+	}
+
+	// FDT-Instance ID 回绕到 0 后重启仍为 0
+	s3.fdt.fdtID = 0xFFFFF
+	if err := s3.Publish(time.Now()); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if s3.fdt.fdtID != 0 {
+		t.Fatalf("FDT instance ID did not wrap: %d", s3.fdt.fdtID)
+	}
+	store.Close()
+
+	// 同一 Content-Location 的两个对象都保留
+	s4, store := newSender()
+	defer store.Close()
+	if s4.fdt.fdtID != 0 {
+		t.Fatalf("wrapped FDT instance ID restored as %d", s4.fdt.fdtID)
+	}
+	got := s4.RestoredObjects()
+	if len(got) != 2 || got[0].Toi != toiB.String() || got[1].Toi != toiC.String() {
+		t.Fatalf("objects sharing a Content-Location collapsed: %+v", got)
+	}
+	toiC2, err := s4.AddObject(0, createObj(300))
+	if err != nil {
+		t.Fatalf("AddObject failed: %v", err)
+	}
+	if toiC2 != toiC {
+		t.Fatalf("re-added object got TOI %s, want %s", toiC2, toiC)
+	}
+	if got := s4.RestoredObjects(); len(got) != 1 || got[0].Toi != toiB.String() {
+		t.Fatalf("unexpected restored catalog: %+v", got)
+	}
+}
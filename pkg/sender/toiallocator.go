@@ -1,12 +1,13 @@
 package sender
 
 import (
+	"Flute_go/pkg/clock"
 	"Flute_go/pkg/lct"
+	"Flute_go/pkg/tools"
 	t "Flute_go/pkg/type"
-	"log"
+	"log/slog"
 	"math/rand"
 	"sync"
-	"time"
 )
 
 // TOIMaxLength 枚举，对应 Rust 的 TOIMaxLength
@@ -27,6 +28,7 @@ type toiAllocatorInternal struct {
 	toiReserved  map[string]struct{}
 	toi          t.Uint128
 	toiMaxLength TOIMaxLength
+	logger       *slog.Logger
 }
 
 // ToiAllocator
@@ -48,7 +50,7 @@ func (t Toi) String() string {
 
 // ToiAllocatorInternal 方法
 
-func newInternal(toiMaxLength TOIMaxLength, toiInitialValue *t.Uint128) *toiAllocatorInternal {
+func newInternal(toiMaxLength TOIMaxLength, toiInitialValue *t.Uint128, clk clock.Clock, logger *slog.Logger) *toiAllocatorInternal {
 	var toi t.Uint128
 	if toiInitialValue != nil {
 		if toiInitialValue.High == 0 && toiInitialValue.Low == 0 {
@@ -58,7 +60,7 @@ func newInternal(toiMaxLength TOIMaxLength, toiInitialValue *t.Uint128) *toiAllo
 		}
 	} else {
 		// 随机生成 u128
-		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		r := rand.New(rand.NewSource(clk.Now().UnixNano()))
 		toi = t.Uint128{High: r.Uint64(), Low: r.Uint64()}
 	}
 
@@ -72,6 +74,7 @@ func newInternal(toiMaxLength TOIMaxLength, toiInitialValue *t.Uint128) *toiAllo
 		toiReserved:  make(map[string]struct{}),
 		toi:          toi,
 		toiMaxLength: toiMaxLength,
+		logger:       logger,
 	}
 }
 
@@ -115,7 +118,7 @@ func (i *toiAllocatorInternal) allocate() t.Uint128 {
 		}
 
 		// 冲突：继续尝试下一枚
-		log.Printf("warn: TOI %s already reserved; bumping", ret.String())
+		i.logger.Warn("TOI already reserved, bumping", "toi", ret.String())
 		i.bump()
 	}
 }
@@ -132,9 +135,10 @@ func (i *toiAllocatorInternal) release(toi t.Uint128) {
 	delete(i.toiReserved, toi.String())
 }
 
-func NewToiAllocator(toiMaxLength TOIMaxLength, toiInitialValue *t.Uint128) *ToiAllocator {
+// NewToiAllocator clk 为随机初始 TOI 提供种子，nil 使用系统时钟；logger 为 nil 时使用 slog.Default()
+func NewToiAllocator(toiMaxLength TOIMaxLength, toiInitialValue *t.Uint128, clk clock.Clock, logger *slog.Logger) *ToiAllocator {
 	return &ToiAllocator{
-		state: newInternal(toiMaxLength, toiInitialValue),
+		state: newInternal(toiMaxLength, toiInitialValue, clock.OrReal(clk), tools.LoggerOrDefault(logger)),
 	}
 }
 
@@ -148,6 +152,34 @@ func (a *ToiAllocator) Allocate() *Toi {
 	}
 }
 
+// Next 下一枚将要尝试分配的 TOI（用于持久化）
+func (a *ToiAllocator) Next() t.Uint128 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.state.toi
+}
+
+// Restore 恢复持久化的分配位置与保留表
+func (a *ToiAllocator) Restore(next t.Uint128, reserved []t.Uint128) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.state.toi = toMaxLength(next, a.state.toiMaxLength)
+	if a.state.toi == lct.TOI_FDT {
+		a.state.bump()
+	}
+	for _, r := range reserved {
+		a.state.toiReserved[r.String()] = struct{}{}
+	}
+}
+
+// reserved 以已保留的 TOI 构造 Toi（重启后沿用旧 TOI）
+func (a *ToiAllocator) reserved(v t.Uint128) *Toi {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.state.toiReserved[v.String()] = struct{}{}
+	return &Toi{allocator: a, value: v}
+}
+
 func (a *ToiAllocator) AllocateToiFDT() *Toi {
 	return &Toi{
 		allocator: a,
@@ -0,0 +1,279 @@
+package sender
+
+import (
+	"Flute_go/pkg/alc"
+	"Flute_go/pkg/clock"
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/object"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/tools"
+	t "Flute_go/pkg/type"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// IP + UDP 头开销
+const (
+	IPv4UDPOverhead = 20 + 8
+	IPv6UDPOverhead = 40 + 8
+)
+
+// rsMaxCodeLength GF(2^8) Reed-Solomon 码长上限（源符号 + 校验符号）
+const rsMaxCodeLength = 255
+
+// UDPPayloadForMTU 路径 MTU 下单个 UDP 包可用的载荷
+func UDPPayloadForMTU(mtu int, ipv6 bool) int {
+	if ipv6 {
+		return mtu - IPv6UDPOverhead
+	}
+	return mtu - IPv4UDPOverhead
+}
+
+// ValidationError 汇总全部问题，避免逐个修改配置
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+func (e *ValidationError) addf(format string, args ...any) {
+	e.Problems = append(e.Problems, fmt.Sprintf(format, args...))
+}
+
+func (e *ValidationError) err() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ValidateOti 检查 OTI 自身的约束（符号长度、块长度、RS 码长）
+func ValidateOti(o *oti.Oti) error {
+	verr := &ValidationError{}
+	validateOti(o, verr)
+	return verr.err()
+}
+
+func validateOti(o *oti.Oti, verr *ValidationError) {
+	if o == nil {
+		verr.addf("OTI is nil")
+		return
+	}
+	if o.EncodingSymbolLength == 0 {
+		verr.addf("encoding symbol length must be > 0")
+	}
+	if o.MaximumSourceBlockLength == 0 {
+		verr.addf("maximum source block length must be > 0")
+	}
+	switch o.FecEncodingID {
+	case oti.NoCode:
+		if o.MaximumSourceBlockLength > 0xFFFF {
+			verr.addf("no-code maximum source block length %d exceeds the 16-bit ESI", o.MaximumSourceBlockLength)
+		}
+	case oti.ReedSolomonGF28, oti.ReedSolomonGF28UnderSpecified:
+		if n := uint64(o.MaximumSourceBlockLength) + uint64(o.MaxNumberOfParitySymbols); n > rsMaxCodeLength {
+			verr.addf("Reed-Solomon GF(2^8): maximum source block length %d + parity %d = %d exceeds %d",
+				o.MaximumSourceBlockLength, o.MaxNumberOfParitySymbols, n, rsMaxCodeLength)
+		}
+	case oti.ReedSolomonGF2M:
+		verr.addf("Reed-Solomon GF(2^m) is not supported by the sender")
+	default:
+		verr.addf("unknown FEC encoding ID %d", o.FecEncodingID)
+	}
+}
+
+// MaxAlcHeaderLength 该配置下 ALC/LCT 头（含 FDT/CENC/SCT/EXT_AUTH/FTI 与 FEC Payload ID）的最大长度
+// 通过构造最坏情况的空载荷包测得，与实际封包代码保持一致
+func MaxAlcHeaderLength(o *oti.Oti, cfg *Config) int {
+	if cfg == nil {
+		def := DefaultConfig()
+		cfg = &def
+	}
+	fdtID := uint32(0xFFFFF)
+	probes := []object.Pkt{
+		// FDT 包：EXT_FDT + EXT_CENC + SCT
+		{Toi: lct.TOI_FDT, FdtID: &fdtID, Cenc: cfg.FDTCenc, SenderCurrentTime: cfg.FDTInbandSCT},
+		// 数据包：最长 TOI + EXT_CENC + SCT
+		{Toi: maxToi(cfg.TOIMaxLength), Cenc: lct.CencGzip, InbandCenc: true, SenderCurrentTime: true},
+	}
+	max := 0
+	now := clock.OrReal(cfg.Clock).Now()
+	for i := range probes {
+		n := len(alc.NewAlcPkt(o, t.Uint128{}, ^uint64(0), &probes[i], cfg.Profile, now))
+		if n > max {
+			max = n
+		}
+	}
+	if cfg.Authenticator != nil {
+		// 取最长的扩展：启动时（早于 T0 或尚未披露密钥）AuthExt 返回的扩展更短
+		max += cfg.Authenticator.MaxExtLength()
+	}
+	return max
+}
+
+func maxToi(l TOIMaxLength) t.Uint128 {
+	bits := map[TOIMaxLength]uint{ToiMax16: 16, ToiMax32: 32, ToiMax48: 48, ToiMax64: 64, ToiMax80: 80}[l]
+	switch {
+	case bits == 0:
+		return t.Uint128{High: 1<<48 - 1, Low: ^uint64(0)}
+	case bits <= 64:
+		return t.Uint128{Low: ^uint64(0) >> (64 - bits)}
+	default:
+		return t.Uint128{High: 1<<(bits-64) - 1, Low: ^uint64(0)}
+	}
+}
+
+// ValidateConfig 在 NewSender 之前检查 OTI 与 Config；maxPayload 为单个 UDP 包可用载荷
+// （见 UDPPayloadForMTU），0 表示不检查 MTU
+func ValidateConfig(o *oti.Oti, cfg *Config, maxPayload int) error {
+	verr := &ValidationError{}
+	validateOti(o, verr)
+	if cfg == nil {
+		def := DefaultConfig()
+		cfg = &def
+	}
+	if cfg.InterleaveBlocks == 0 {
+		verr.addf("interleave blocks must be > 0")
+	}
+	if len(cfg.PriorityQueues) == 0 {
+		verr.addf("at least one priority queue is required")
+	}
+	if cfg.FDTDuration <= 0 {
+		verr.addf("FDT duration must be > 0")
+	}
+	if cfg.FDTStartID > 0xFFFFF {
+		verr.addf("FDT start ID %d exceeds 20 bits", cfg.FDTStartID)
+	}
+	if err := checkFdtCenc(cfg.FDTCenc); err != nil {
+		verr.addf("%v", err)
+	}
+	if cfg.TOIInitialValue != nil {
+		if m := maxToi(cfg.TOIMaxLength); cfg.TOIInitialValue.High > m.High ||
+			(cfg.TOIInitialValue.High == m.High && cfg.TOIInitialValue.Low > m.Low) {
+			verr.addf("TOI initial value %s does not fit the TOI max length", cfg.TOIInitialValue.String())
+		}
+	}
+	if maxPayload > 0 && o != nil && o.FecEncodingID != oti.ReedSolomonGF2M {
+		hdr := MaxAlcHeaderLength(o, cfg)
+		if hdr+int(o.EncodingSymbolLength) > maxPayload {
+			verr.addf("encoding symbol length %d + ALC header %d = %d bytes exceeds the UDP payload of %d bytes",
+				o.EncodingSymbolLength, hdr, hdr+int(o.EncodingSymbolLength), maxPayload)
+		}
+	}
+	return verr.err()
+}
+
+// ValidateObject 在 AddObject 之前检查对象能否用该 OTI 发送
+func ValidateObject(o *oti.Oti, obj *ObjectDesc) error {
+	verr := &ValidationError{}
+	if obj.OTI != nil {
+		validateOti(obj.OTI, verr)
+	}
+	o = obj.effectiveOti(o)
+	if obj.ContentLocation == nil {
+		verr.addf("content location is required")
+	}
+	if o != nil && o.EncodingSymbolLength > 0 && o.MaximumSourceBlockLength > 0 {
+		// 尚未加密的对象按加密后的长度（含 GCM 标签）检查
+		length := obj.TransferLength
+		if obj.ContentKeyID != nil && !obj.encrypted {
+			length += uint64(obj.Encryption.Overhead())
+		}
+		if max := o.MaxTransferLength(); length > max {
+			verr.addf("transfer length %d exceeds %d, the maximum for this OTI (%s, %d x %d bytes per block)",
+				length, max, o.FecEncodingID, o.MaximumSourceBlockLength, o.EncodingSymbolLength)
+		}
+	}
+	return verr.err()
+}
+
+// AutoOtiParams 自动选择 OTI 的输入
+type AutoOtiParams struct {
+	FEC oti.FECEncodingID
+	// 单个 UDP 包可用载荷（见 UDPPayloadForMTU）
+	MaxPayload int
+	// 最大对象的传输长度，用于保证 MaxTransferLength 足够并为小对象缩短块长度；0 = 不考虑
+	ObjectSize uint64
+	// Reed-Solomon 校验符号占源符号的百分比（默认 20）
+	ParityPercent uint32
+	// 符号长度按此对齐（默认 4）
+	SymbolAlignment uint16
+}
+
+// AutoOti 由目标 MTU、FEC 方案与对象大小推导符号长度和块长度
+// cfg 用于计算头部开销（TOI 长度、Profile、EXT_AUTH 等），nil 使用 DefaultConfig
+func AutoOti(p AutoOtiParams, cfg *Config) (*oti.Oti, error) {
+	if p.MaxPayload <= 0 {
+		return nil, errors.New("auto OTI: max payload must be > 0")
+	}
+	align := p.SymbolAlignment
+	if align == 0 {
+		align = 4
+	}
+	parity := p.ParityPercent
+	if parity == 0 {
+		parity = 20
+	}
+
+	// 先按最大块长度构造，测出头部开销后再定符号长度
+	var o *oti.Oti
+	switch p.FEC {
+	case oti.NoCode:
+		o = oti.NewNoCode(1, 64)
+	case oti.ReedSolomonGF28, oti.ReedSolomonGF28UnderSpecified:
+		o = &oti.Oti{FecEncodingID: p.FEC, InBandFti: true, EncodingSymbolLength: 1, MaximumSourceBlockLength: 1}
+	default:
+		return nil, fmt.Errorf("auto OTI: FEC %s is not supported", p.FEC)
+	}
+	hdr := MaxAlcHeaderLength(o, cfg)
+	esl := p.MaxPayload - hdr
+	esl -= esl % int(align)
+	if esl <= 0 {
+		return nil, fmt.Errorf("auto OTI: payload of %d bytes cannot hold the %d-byte ALC header", p.MaxPayload, hdr)
+	}
+	if esl > 0xFFFF {
+		esl = 0xFFFF - 0xFFFF%int(align)
+	}
+	o.EncodingSymbolLength = uint16(esl)
+	nbSymbols := tools.DivCeil(p.ObjectSize, uint64(esl))
+
+	switch p.FEC {
+	case oti.NoCode:
+		// 块数受 16 位 SBN 限制，大对象需要更长的块
+		msbl := uint64(64)
+		if need := tools.DivCeil(nbSymbols, o.MaxSourceBlockNumber()); need > msbl {
+			msbl = need
+		}
+		if msbl > 0xFFFF {
+			return nil, fmt.Errorf("auto OTI: object of %d bytes is too large for no-code FEC", p.ObjectSize)
+		}
+		o.MaximumSourceBlockLength = uint32(msbl)
+	default:
+		msbl := uint64(rsMaxCodeLength) * 100 / uint64(100+parity)
+		// 小对象只用一个块
+		if nbSymbols > 0 && nbSymbols < msbl {
+			msbl = nbSymbols
+		}
+		if msbl == 0 {
+			msbl = 1
+		}
+		nbParity := tools.DivCeil(msbl*uint64(parity), 100)
+		if msbl+nbParity > rsMaxCodeLength {
+			nbParity = rsMaxCodeLength - msbl
+		}
+		o.MaximumSourceBlockLength = uint32(msbl)
+		o.MaxNumberOfParitySymbols = uint32(nbParity)
+	}
+
+	if p.ObjectSize > o.MaxTransferLength() {
+		return nil, fmt.Errorf("auto OTI: object of %d bytes exceeds %d, the maximum for %s at %d-byte symbols",
+			p.ObjectSize, o.MaxTransferLength(), p.FEC, esl)
+	}
+	if err := ValidateOti(o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
@@ -0,0 +1,139 @@
+package sender
+
+import (
+	"Flute_go/pkg/clock"
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/tesla"
+	"Flute_go/pkg/transport"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := ValidateConfig(oti.NewOti(), &cfg, UDPPayloadForMTU(1500, false)); err != nil {
+		t.Fatalf("default config must be valid: %v", err)
+	}
+
+	// 符号过长 + RS 码长超限，一次报告全部问题
+	o, _ := oti.NewReedSolomonRS28(1500, 240, 20)
+	err := ValidateConfig(o, &cfg, UDPPayloadForMTU(1500, false))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 2 {
+		t.Fatalf("expected MTU and code length problems, got %v", err)
+	}
+
+	obj := createObj(100)
+	obj.TransferLength = oti.NewNoCode(1424, 1).MaxTransferLength() + 1
+	if err := ValidateObject(oti.NewNoCode(1424, 1), obj); err == nil {
+		t.Fatalf("object larger than MaxTransferLength must be rejected")
+	}
+
+	// zstd 没有 EXT_CENC 取值
+	cfg.FDTCenc = lct.CencZstd
+	if err := ValidateConfig(oti.NewOti(), &cfg, 0); err == nil {
+		t.Fatalf("FDT content encoding without EXT_CENC value must be rejected")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("NewSender must refuse an FDT encoding that ValidateConfig rejects")
+			}
+		}()
+		NewSender(transport.NewUDPEndpoint(nil, "224.0.0.1", 1234), 1, oti.NewOti(), &cfg)
+	}()
+
+	// 对象的 zstd 只在 FDT 中声明（不发 EXT_CENC），照常接受
+	obj = createObj(100)
+	obj.Cenc, obj.InbandCenc = lct.CencZstd, true
+	if err := ValidateObject(oti.NewOti(), obj); err != nil {
+		t.Fatalf("zstd object must be accepted: %v", err)
+	}
+}
+
+func TestAutoOti(t *testing.T) {
+	payload := UDPPayloadForMTU(1500, false)
+	for _, fec := range []oti.FECEncodingID{oti.NoCode, oti.ReedSolomonGF28, oti.ReedSolomonGF28UnderSpecified} {
+		for _, size := range []uint64{1000, 50 << 20} {
+			if fec == oti.ReedSolomonGF28 && size > 1<<20 {
+				continue // 8 位 SBN，大对象改用 under-specified
+			}
+			o, err := AutoOti(AutoOtiParams{FEC: fec, MaxPayload: payload, ObjectSize: size}, nil)
+			if err != nil {
+				t.Fatalf("%s/%d: AutoOti failed: %v", fec, size, err)
+			}
+			cfg := DefaultConfig()
+			if err := ValidateConfig(o, &cfg, payload); err != nil {
+				t.Fatalf("%s/%d: derived OTI does not validate: %v", fec, size, err)
+			}
+			if hdr := MaxAlcHeaderLength(o, &cfg); payload-hdr-int(o.EncodingSymbolLength) >= 4 {
+				t.Fatalf("%s: symbol length %d wastes payload (header %d)", fec, o.EncodingSymbolLength, hdr)
+			}
+			if size > o.MaxTransferLength() {
+				t.Fatalf("%s: object does not fit", fec)
+			}
+			if size > 1<<20 {
+				continue
+			}
+			// 实际发出的包不超过载荷
+			s := NewSender(transport.NewUDPEndpoint(nil, "224.0.0.1", 1234), 1, o, nil)
+			if _, err := s.AddObject(0, createObj(int(size))); err != nil {
+				t.Fatalf("AddObject failed: %v", err)
+			}
+			s.Publish(time.Now())
+			for pkt := s.Read(time.Now()); pkt != nil; pkt = s.Read(time.Now()) {
+				if len(pkt) > payload {
+					t.Fatalf("%s: packet of %d bytes exceeds payload %d", fec, len(pkt), payload)
+				}
+			}
+		}
+	}
+
+	// 8 位 SBN 的 RS28 装不下大对象
+	if _, err := AutoOti(AutoOtiParams{FEC: oti.ReedSolomonGF28, MaxPayload: payload, ObjectSize: 1 << 30}, nil); err == nil {
+		t.Fatalf("RS28 must reject a 1 GiB object")
+	}
+}
+
+// 启动时（早于 T0、尚未披露密钥）也按披露密钥的 EXT_AUTH 计算头部
+func TestMaxAlcHeaderLengthAuth(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	auth, err := tesla.NewSender(tesla.Params{
+		T0: start.Add(time.Hour), Interval: time.Second, DisclosureDelay: 2, KeyChainLength: 100,
+	}, []byte("seed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.Clock = clock.NewVirtual(start)
+	plain := MaxAlcHeaderLength(oti.NewOti(), &cfg)
+	cfg.Authenticator = auth
+	if got := MaxAlcHeaderLength(oti.NewOti(), &cfg); got != plain+auth.MaxExtLength() {
+		t.Fatalf("header with EXT_AUTH %d, want %d", got, plain+auth.MaxExtLength())
+	}
+}
+
+// 对象级 OTI 在校验与发送中都生效
+func TestObjectOti(t *testing.T) {
+	def := oti.NewNoCode(1424, 64)
+	own, _ := oti.NewReedSolomonRS28(100, 10, 2)
+	obj := createObj(int(own.MaxTransferLength()) + 1)
+	obj.OTI = own
+	if err := ValidateObject(def, obj); err == nil {
+		t.Fatalf("object larger than its own OTI allows must be rejected")
+	}
+
+	s := NewSender(transport.NewUDPEndpoint(nil, "224.0.0.1", 1234), 1, def, nil)
+	obj = createObj(500)
+	obj.OTI = own
+	if _, err := s.AddObject(0, obj); err != nil {
+		t.Fatal(err)
+	}
+	s.Publish(time.Now())
+	pkts := readDataPkts(t, s, time.Now())
+	if len(pkts) == 0 || pkts[0].Oti == nil || *pkts[0].Oti != *own {
+		t.Fatalf("packets must carry the object OTI")
+	}
+}
@@ -0,0 +1,166 @@
+package tesla
+
+import (
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/tools"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EXT_AUTH 中的 Type 字段（RFC 5776 §3）
+const (
+	TypeBootstrap     uint8 = 0
+	TypeAuthTag       uint8 = 3
+	TypeAuthTagNoKey  uint8 = 4
+	bootstrapFixedLen       = 56
+)
+
+// Params TESLA 会话参数
+type Params struct {
+	// ASID 认证方案标识（4 bit）
+	ASID uint8
+	// T0 第 0 个区间的起始时间
+	T0 time.Time
+	// Interval 区间长度 Tint
+	Interval time.Duration
+	// DisclosureDelay 密钥披露延迟 d（单位：区间）
+	DisclosureDelay uint32
+	// KeyChainLength 密钥链长度 N（可认证的区间数）
+	KeyChainLength uint32
+	// MACLength MAC 截断长度 n_m，0 表示 DefaultMACLength
+	MACLength int
+}
+
+func (p *Params) macLength() int {
+	if p.MACLength <= 0 {
+		return DefaultMACLength
+	}
+	return p.MACLength
+}
+
+// IntervalAt 返回 t 所在的区间索引；t 早于 T0 时返回 false
+func (p *Params) IntervalAt(t time.Time) (uint32, bool) {
+	if t.Before(p.T0) || p.Interval <= 0 {
+		return 0, false
+	}
+	return uint32(t.Sub(p.T0) / p.Interval), true
+}
+
+// Bootstrap 接收端启动 TESLA 所需的信息：参数、承诺值 K_0 与发送端时间（用于时间同步）
+type Bootstrap struct {
+	Params     Params
+	Commitment []byte
+	SenderTime time.Time
+}
+
+// MarshalBootstrap 按 EXT_AUTH Type=0 的格式编码 Bootstrap，signKey 非 nil 时附带 Ed25519 签名
+func MarshalBootstrap(b *Bootstrap, signKey ed25519.PrivateKey) ([]byte, error) {
+	if len(b.Commitment) != KeyLength {
+		return nil, fmt.Errorf("wrong commitment length %d", len(b.Commitment))
+	}
+	t0, err := tools.SystemTimeToNTP(b.Params.T0)
+	if err != nil {
+		return nil, err
+	}
+	st, err := tools.SystemTimeToNTP(b.SenderTime)
+	if err != nil {
+		return nil, err
+	}
+
+	sigLen := 0
+	if signKey != nil {
+		sigLen = ed25519.SignatureSize
+	}
+	total := bootstrapFixedLen + sigLen
+	total = (total + 3) &^ 3
+
+	buf := make([]byte, total)
+	buf[0] = uint8(lct.ExtAuth)
+	buf[1] = uint8(total / 4)
+	buf[2] = (b.Params.ASID&0xF)<<4 | TypeBootstrap
+	binary.BigEndian.PutUint64(buf[4:12], t0)
+	binary.BigEndian.PutUint32(buf[12:16], uint32(b.Params.Interval/time.Microsecond))
+	binary.BigEndian.PutUint32(buf[16:20], b.Params.DisclosureDelay)
+	binary.BigEndian.PutUint32(buf[20:24], b.Params.KeyChainLength)
+	binary.BigEndian.PutUint64(buf[24:32], st)
+	buf[32] = uint8(b.Params.macLength())
+	binary.BigEndian.PutUint16(buf[34:36], uint16(sigLen))
+	copy(buf[36:56], b.Commitment)
+
+	if signKey != nil {
+		sig := ed25519.Sign(signKey, buf[:bootstrapFixedLen])
+		copy(buf[bootstrapFixedLen:], sig)
+	}
+	return buf, nil
+}
+
+// ParseBootstrap 解析 Bootstrap；verifyKey 非 nil 时要求签名存在且有效
+func ParseBootstrap(data []byte, verifyKey ed25519.PublicKey) (*Bootstrap, error) {
+	if len(data) < bootstrapFixedLen {
+		return nil, errors.New("bootstrap too short")
+	}
+	if data[0] != uint8(lct.ExtAuth) {
+		return nil, fmt.Errorf("wrong HET: %d", data[0])
+	}
+	if int(data[1])*4 != len(data) {
+		return nil, fmt.Errorf("wrong HEL: %d", data[1])
+	}
+	if data[2]&0xF != TypeBootstrap {
+		return nil, fmt.Errorf("wrong EXT_AUTH type: %d", data[2]&0xF)
+	}
+
+	if int(data[32]) > MaxMACLength {
+		return nil, fmt.Errorf("TESLA MAC length %d exceeds %d bytes", data[32], MaxMACLength)
+	}
+
+	sigLen := int(binary.BigEndian.Uint16(data[34:36]))
+	if bootstrapFixedLen+sigLen > len(data) {
+		return nil, errors.New("bootstrap signature truncated")
+	}
+	if verifyKey != nil {
+		if sigLen != ed25519.SignatureSize {
+			return nil, errors.New("bootstrap is not signed")
+		}
+		sig := data[bootstrapFixedLen : bootstrapFixedLen+sigLen]
+		if !ed25519.Verify(verifyKey, data[:bootstrapFixedLen], sig) {
+			return nil, errors.New("bootstrap signature is invalid")
+		}
+	}
+
+	t0, err := tools.NTPToSystemTime(binary.BigEndian.Uint64(data[4:12]))
+	if err != nil {
+		return nil, err
+	}
+	st, err := tools.NTPToSystemTime(binary.BigEndian.Uint64(data[24:32]))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bootstrap{
+		Params: Params{
+			ASID:            data[2] >> 4,
+			T0:              t0,
+			Interval:        time.Duration(binary.BigEndian.Uint32(data[12:16])) * time.Microsecond,
+			DisclosureDelay: binary.BigEndian.Uint32(data[16:20]),
+			KeyChainLength:  binary.BigEndian.Uint32(data[20:24]),
+			MACLength:       int(data[32]),
+		},
+		Commitment: append([]byte(nil), data[36:56]...),
+		SenderTime: st,
+	}, nil
+}
+
+// TimeSync 接收端对发送端时间的同步结果
+// LocalTime 为接收端发出 Bootstrap 请求时的本地时间，SenderTime 为 Bootstrap 中携带的发送端时间
+type TimeSync struct {
+	SenderTime time.Time
+	LocalTime  time.Time
+}
+
+// SenderTimeUpperBound 返回本地时间 now 时发送端时间的上界
+func (s TimeSync) SenderTimeUpperBound(now time.Time) time.Time {
+	return s.SenderTime.Add(now.Sub(s.LocalTime))
+}
@@ -0,0 +1,86 @@
+package tesla
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// KeyLength 密钥长度 n_k（字节）
+const KeyLength = 20
+
+// DefaultMACLength 默认 MAC 长度 n_m（字节），HMAC-SHA-256 截断
+const DefaultMACLength = 10
+
+// MaxMACLength MAC 最大长度，即 HMAC-SHA-256 输出长度
+const MaxMACLength = sha256.Size
+
+// KeyChain 单向密钥链：K_{i-1} = F(K_i)，K_0 为承诺值（commitment）
+type KeyChain struct {
+	keys [][]byte
+}
+
+// NewKeyChain 生成长度为 n+1 的密钥链（K_0..K_n）；seed 为 nil 时随机生成 K_n
+func NewKeyChain(n uint32, seed []byte) (*KeyChain, error) {
+	if n == 0 {
+		return nil, errors.New("key chain length can not be 0")
+	}
+	last := make([]byte, KeyLength)
+	if seed == nil {
+		if _, err := rand.Read(last); err != nil {
+			return nil, err
+		}
+	} else {
+		// seed 经 F 规约到 n_k 字节
+		last = prf(seed, 0x00)
+	}
+
+	keys := make([][]byte, n+1)
+	keys[n] = last
+	for i := n; i > 0; i-- {
+		keys[i-1] = F(keys[i])
+	}
+	return &KeyChain{keys: keys}, nil
+}
+
+// Len 返回最后一个可用区间的索引 n
+func (k *KeyChain) Len() uint32 {
+	return uint32(len(k.keys) - 1)
+}
+
+// Key 返回 K_i；越界返回 nil
+func (k *KeyChain) Key(i uint32) []byte {
+	if int(i) >= len(k.keys) {
+		return nil
+	}
+	return k.keys[i]
+}
+
+// Commitment 返回 K_0
+func (k *KeyChain) Commitment() []byte {
+	return k.keys[0]
+}
+
+// F 单向函数，生成密钥链（RFC 4082: F(K) = HMAC(K, 0)）
+func F(key []byte) []byte {
+	return prf(key, 0x00)
+}
+
+// FPrime 由 K_i 派生 MAC 密钥 K'_i（RFC 4082: F'(K) = HMAC(K, 1)）
+func FPrime(key []byte) []byte {
+	return prf(key, 0x01)
+}
+
+func prf(key []byte, label byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte{label})
+	return h.Sum(nil)[:KeyLength]
+}
+
+// computeMAC 用 K'_i 计算截断后的 MAC
+func computeMAC(macKey []byte, pkt []byte, macLength int) []byte {
+	h := hmac.New(sha256.New, macKey)
+	h.Write(pkt)
+	return h.Sum(nil)[:macLength]
+}
@@ -0,0 +1,148 @@
+package tesla
+
+import (
+	"Flute_go/pkg/lct"
+	"Flute_go/pkg/tools"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Sender TESLA 发送端，实现 alc.Authenticator
+// 区间 i 内发送的包用 K'_i = F'(K_i) 计算 MAC，并披露 K_{i-d}
+type Sender struct {
+	params Params
+	chain  *KeyChain
+	// nil 时使用 slog.Default()
+	Logger *slog.Logger
+	// unauth 上一次 AuthExt 的不认证原因，只在状态变化时告警
+	unauth atomic.Int32
+}
+
+const (
+	authOK = iota
+	authBeforeT0
+	authChainExhausted
+)
+
+// NewSender 创建发送端；seed 为 nil 时随机生成密钥链
+func NewSender(params Params, seed []byte) (*Sender, error) {
+	if params.Interval <= 0 {
+		return nil, errors.New("TESLA interval must be positive")
+	}
+	if params.DisclosureDelay == 0 {
+		return nil, errors.New("TESLA disclosure delay must be at least 1 interval")
+	}
+	if params.MACLength > MaxMACLength {
+		return nil, errors.New("TESLA MAC length can not exceed 32 bytes")
+	}
+	chain, err := NewKeyChain(params.KeyChainLength, seed)
+	if err != nil {
+		return nil, err
+	}
+	return &Sender{params: params, chain: chain}, nil
+}
+
+// Params 返回会话参数
+func (s *Sender) Params() Params {
+	return s.params
+}
+
+// Bootstrap 生成带发送端当前时间的 Bootstrap 信息，交由带外通道（SDP、HTTP 等）分发
+func (s *Sender) Bootstrap(now time.Time, signKey ed25519.PrivateKey) ([]byte, error) {
+	return MarshalBootstrap(&Bootstrap{
+		Params:     s.params,
+		Commitment: s.chain.Commitment(),
+		SenderTime: now,
+	}, signKey)
+}
+
+// SetLogger 注入 logger
+func (s *Sender) SetLogger(l *slog.Logger) { s.Logger = l }
+
+// interval 区间 0 保留给承诺值 K_0，第一个可认证区间为 1
+func (s *Sender) interval(now time.Time) (uint32, int) {
+	i, ok := s.params.IntervalAt(now)
+	if !ok {
+		return 0, authBeforeT0
+	}
+	i++
+	if i > s.chain.Len() {
+		return 0, authChainExhausted
+	}
+	return i, authOK
+}
+
+// AuthExt 构建 EXT_AUTH（Type=3 披露密钥 / Type=4 尚无可披露密钥），MAC 置 0
+// 密钥链耗尽或早于 T0 时返回 nil，包不做认证，进入该状态时输出 Warn 日志
+func (s *Sender) AuthExt(now time.Time) ([]byte, int) {
+	i, state := s.interval(now)
+	if prev := s.unauth.Swap(int32(state)); prev != int32(state) {
+		switch state {
+		case authBeforeT0:
+			tools.LoggerOrDefault(s.Logger).Warn("TESLA: sending unauthenticated packets before T0",
+				"t0", s.params.T0)
+		case authChainExhausted:
+			tools.LoggerOrDefault(s.Logger).Warn("TESLA: key chain exhausted, sending unauthenticated packets",
+				"key_chain_length", s.chain.Len())
+		}
+	}
+	if state != authOK {
+		return nil, 0
+	}
+	macLen := s.params.macLength()
+	d := s.params.DisclosureDelay
+
+	/*
+		 0                   1                   2                   3
+		 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+		+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+		|   HET (=1)    |      HEL      |  ASID | Type  |   Reserved    |
+		+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+		|                        i (interval index)                     |
+		+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+		~            Disclosed Key K_{i-d} (n_k, Type=3 only)           ~
+		+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+		~                     MAC(K'_i, M) (n_m) + padding              ~
+		+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+	*/
+	typ := TypeAuthTagNoKey
+	keyLen := 0
+	if i > d {
+		typ = TypeAuthTag
+		keyLen = KeyLength
+	}
+	total := extLength(keyLen, macLen)
+
+	ext := make([]byte, total)
+	ext[0] = uint8(lct.ExtAuth)
+	ext[1] = uint8(total / 4)
+	ext[2] = (s.params.ASID&0xF)<<4 | typ
+	binary.BigEndian.PutUint32(ext[4:8], i)
+	if typ == TypeAuthTag {
+		copy(ext[8:8+KeyLength], s.chain.Key(i-d))
+	}
+	return ext, 8 + keyLen
+}
+
+// MaxExtLength 披露密钥的 EXT_AUTH（Type=3）长度，即最长的扩展
+func (s *Sender) MaxExtLength() int {
+	return extLength(KeyLength, s.params.macLength())
+}
+
+// extLength 扩展头 8 字节 + 密钥 + MAC，补齐到 4 字节
+func extLength(keyLen, macLen int) int {
+	return (8 + keyLen + macLen + 3) &^ 3
+}
+
+// Sign 计算 MAC(K'_i, M)
+func (s *Sender) Sign(pkt []byte, now time.Time) []byte {
+	i, state := s.interval(now)
+	if state != authOK {
+		return nil
+	}
+	return computeMAC(FPrime(s.chain.Key(i)), pkt, s.params.macLength())
+}
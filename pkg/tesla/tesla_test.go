@@ -0,0 +1,175 @@
+package tesla
+
+import (
+	"Flute_go/pkg/alc"
+	"Flute_go/pkg/object"
+	"Flute_go/pkg/oti"
+	"Flute_go/pkg/profile"
+	t "Flute_go/pkg/type"
+	"bytes"
+	"crypto/ed25519"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSender(tb testing.TB, base time.Time) *Sender {
+	s, err := NewSender(Params{
+		ASID:            1,
+		T0:              base,
+		Interval:        100 * time.Millisecond,
+		DisclosureDelay: 2,
+		KeyChainLength:  100,
+	}, []byte("seed"))
+	if err != nil {
+		tb.Fatalf("NewSender failed: %v", err)
+	}
+	return s
+}
+
+func buildPkt(s *Sender, esi uint32, now time.Time) []byte {
+	o, _ := oti.NewReedSolomonRS28(16, 4, 2)
+	p := &object.Pkt{
+		Payload:        []byte("0123456789abcdef"),
+		TransferLength: 64,
+		Esi:            esi,
+		Toi:            t.Uint128{Low: 1},
+	}
+	return alc.NewAlcPktAuth(o, t.Uint128{}, 1, p, profile.RFC6726, now, s)
+}
+
+func TestTeslaVerify(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestSender(t, base)
+
+	boot, err := s.Bootstrap(base, nil)
+	if err != nil {
+		t.Fatalf("Bootstrap failed: %v", err)
+	}
+	b, err := ParseBootstrap(boot, nil)
+	if err != nil {
+		t.Fatalf("ParseBootstrap failed: %v", err)
+	}
+	v, err := NewVerifier(b, TimeSync{SenderTime: b.SenderTime, LocalTime: base})
+	if err != nil {
+		t.Fatalf("NewVerifier failed: %v", err)
+	}
+
+	verified := 0
+	for k := 0; k <= 20; k++ {
+		now := base.Add(time.Duration(k) * 50 * time.Millisecond)
+		pkt := buildPkt(s, uint32(k), now)
+		if k == 3 {
+			pkt[len(pkt)-1] ^= 0xFF
+		}
+		out, err := v.Push(pkt, now.Add(10*time.Millisecond))
+		if err != nil {
+			t.Fatalf("packet %d dropped: %v", k, err)
+		}
+		verified += len(out)
+	}
+
+	// 最后一个包位于区间 11，披露 K_9：区间 1..9 的 18 个包中 1 个被篡改
+	if verified != 17 {
+		t.Fatalf("expected 17 verified packets, got %d", verified)
+	}
+	if st := v.Stats(); st.BadMAC != 1 {
+		t.Fatalf("expected 1 bad MAC, got %d", st.BadMAC)
+	}
+}
+
+func TestTeslaUnsafePacket(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestSender(t, base)
+	b := &Bootstrap{Params: s.Params(), Commitment: s.chain.Commitment(), SenderTime: base}
+	v, _ := NewVerifier(b, TimeSync{SenderTime: base, LocalTime: base})
+
+	// 区间 1 的包在 300ms 后才到达，此时 K_1 可能已被披露
+	pkt := buildPkt(s, 0, base)
+	if _, err := v.Push(pkt, base.Add(300*time.Millisecond)); err != ErrUnsafe {
+		t.Fatalf("expected ErrUnsafe, got %v", err)
+	}
+}
+
+// 区间号超出发送端当前可能所在区间的包直接丢弃，不会触发长链推导
+func TestTeslaFuturePacket(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestSender(t, base)
+	b := &Bootstrap{Params: s.Params(), Commitment: s.chain.Commitment(), SenderTime: base}
+	v, _ := NewVerifier(b, TimeSync{SenderTime: base, LocalTime: base})
+
+	// 区间 81 的包在发送端时间 base+50ms（区间 1）时到达
+	pkt := buildPkt(s, 0, base.Add(8*time.Second))
+	if _, err := v.Push(pkt, base.Add(50*time.Millisecond)); err != ErrFuture {
+		t.Fatalf("expected ErrFuture, got %v", err)
+	}
+	if st := v.Stats(); st.Future != 1 || v.NbPending() != 0 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+func TestTeslaSignedBootstrap(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestSender(t, base)
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+
+	boot, err := s.Bootstrap(base, priv)
+	if err != nil {
+		t.Fatalf("Bootstrap failed: %v", err)
+	}
+	if _, err := ParseBootstrap(boot, pub); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+	if _, err := ParseBootstrap(boot, otherPub); err == nil {
+		t.Fatalf("expected signature error with wrong key")
+	}
+}
+
+// 超过 HMAC-SHA-256 输出长度的 MAC 长度会让 computeMAC 越界，必须在入口拒绝
+func TestTeslaMACLengthLimit(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestSender(t, base)
+	data, err := s.Bootstrap(base, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[32] = MaxMACLength + 1
+	if _, err := ParseBootstrap(data, nil); err == nil {
+		t.Fatal("bootstrap with oversized MAC length accepted")
+	}
+
+	params := s.Params()
+	params.MACLength = MaxMACLength + 1
+	b := &Bootstrap{Params: params, Commitment: s.chain.Commitment(), SenderTime: base}
+	if _, err := NewVerifier(b, TimeSync{SenderTime: base, LocalTime: base}); err == nil {
+		t.Fatal("verifier with oversized MAC length accepted")
+	}
+}
+
+// 早于 T0 与密钥链耗尽时不认证，进入该状态时各告警一次
+func TestTeslaUnauthenticatedWarning(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestSender(t, base)
+	var buf bytes.Buffer
+	s.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	for _, now := range []time.Time{base.Add(-time.Second), base.Add(-time.Millisecond)} {
+		if ext, _ := s.AuthExt(now); ext != nil {
+			t.Fatal("packet before T0 authenticated")
+		}
+	}
+	if ext, _ := s.AuthExt(base); ext == nil {
+		t.Fatal("packet at T0 not authenticated")
+	}
+	for _, now := range []time.Time{base.Add(20 * time.Second), base.Add(30 * time.Second)} {
+		if ext, _ := s.AuthExt(now); ext != nil {
+			t.Fatal("packet past the key chain authenticated")
+		}
+	}
+	out := buf.String()
+	if strings.Count(out, "before T0") != 1 || strings.Count(out, "key chain exhausted") != 1 {
+		t.Fatalf("unexpected warnings:\n%s", out)
+	}
+}
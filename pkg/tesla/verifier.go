@@ -0,0 +1,236 @@
+package tesla
+
+import (
+	"Flute_go/pkg/lct"
+	"bytes"
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultMaxPending 等待密钥披露时最多缓存的包数
+const DefaultMaxPending = 4096
+
+var (
+	ErrNoAuth     = errors.New("packet has no EXT_AUTH")
+	ErrUnsafe     = errors.New("packet received after its key may have been disclosed")
+	ErrBadKey     = errors.New("disclosed key does not belong to the key chain")
+	ErrBufferFull = errors.New("TESLA pending buffer is full")
+	ErrFuture     = errors.New("packet claims an interval the sender cannot have reached yet")
+)
+
+// Stats 接收端统计
+type Stats struct {
+	Verified uint64
+	Dropped  uint64
+	Unsafe   uint64
+	BadMAC   uint64
+	BadKey   uint64
+	NoAuth   uint64
+	Future   uint64
+}
+
+type pendingPkt struct {
+	data []byte // MAC 字段已置 0
+	mac  []byte
+	raw  []byte
+}
+
+// Verifier TESLA 接收端：缓存包直到对应区间的密钥被披露，验证 MAC 后放行，失败则丢弃
+// 非并发安全，由接收端在收包协程中调用
+type Verifier struct {
+	params Params
+	sync   TimeSync
+
+	lastIndex uint32
+	lastKey   []byte
+
+	pending   map[uint32][]pendingPkt
+	nbPending int
+
+	// MaxPending 缓存上限，0 表示 DefaultMaxPending
+	MaxPending int
+
+	stats Stats
+}
+
+// NewVerifier 由已解析（并验证过签名）的 Bootstrap 与时间同步结果创建接收端
+func NewVerifier(b *Bootstrap, sync TimeSync) (*Verifier, error) {
+	if len(b.Commitment) != KeyLength {
+		return nil, fmt.Errorf("wrong commitment length %d", len(b.Commitment))
+	}
+	if b.Params.Interval <= 0 {
+		return nil, errors.New("TESLA interval must be positive")
+	}
+	if b.Params.MACLength > MaxMACLength {
+		return nil, fmt.Errorf("TESLA MAC length %d exceeds %d bytes", b.Params.MACLength, MaxMACLength)
+	}
+	return &Verifier{
+		params:    b.Params,
+		sync:      sync,
+		lastIndex: 0,
+		lastKey:   append([]byte(nil), b.Commitment...),
+		pending:   make(map[uint32][]pendingPkt),
+	}, nil
+}
+
+// Stats 返回统计信息
+func (v *Verifier) Stats() Stats {
+	return v.stats
+}
+
+// NbPending 返回正在等待密钥披露的包数
+func (v *Verifier) NbPending() int {
+	return v.nbPending
+}
+
+// Push 收到一个 ALC 包。返回因本包披露的密钥而验证通过的包（按区间顺序）；
+// 若本包本身被丢弃，error 给出原因
+func (v *Verifier) Push(pkt []byte, now time.Time) ([][]byte, error) {
+	hdr, err := lct.ParseLCTHeader(pkt)
+	if err != nil {
+		return nil, v.drop(err)
+	}
+	offset, hel, err := lct.FindExt(pkt, hdr, uint8(lct.ExtAuth))
+	if err != nil || hel == 0 {
+		v.stats.NoAuth++
+		return nil, v.drop(ErrNoAuth)
+	}
+	ext := pkt[offset : offset+hel]
+	if hel < 8 {
+		return nil, v.drop(fmt.Errorf("EXT_AUTH too short: %d", hel))
+	}
+	if ext[2]>>4 != v.params.ASID&0xF {
+		return nil, v.drop(fmt.Errorf("unexpected ASID %d", ext[2]>>4))
+	}
+
+	typ := ext[2] & 0xF
+	keyLen := 0
+	switch typ {
+	case TypeAuthTag:
+		keyLen = KeyLength
+	case TypeAuthTagNoKey:
+	default:
+		return nil, v.drop(fmt.Errorf("unsupported EXT_AUTH type %d", typ))
+	}
+	macLen := v.params.macLength()
+	if 8+keyLen+macLen > hel {
+		return nil, v.drop(fmt.Errorf("EXT_AUTH too short for type %d: %d", typ, hel))
+	}
+
+	i := binary.BigEndian.Uint32(ext[4:8])
+	if i == 0 || i > v.params.KeyChainLength {
+		return nil, v.drop(fmt.Errorf("interval %d outside of key chain", i))
+	}
+	// 区间号来自包本身：超过发送端当前可能所在区间的包是伪造的，
+	// 否则一次披露就要向下推导到 KeyChainLength
+	if i > v.currentInterval(now) {
+		v.stats.Future++
+		return nil, v.drop(ErrFuture)
+	}
+
+	// 安全条件：发送端在接收时刻最多处于区间 j，K_i 在区间 i+d 才披露
+	if i <= v.lastIndex || !v.isSafe(i, now) {
+		v.stats.Unsafe++
+		return nil, v.drop(ErrUnsafe)
+	}
+
+	maxPending := v.MaxPending
+	if maxPending <= 0 {
+		maxPending = DefaultMaxPending
+	}
+	if v.nbPending >= maxPending {
+		return nil, v.drop(ErrBufferFull)
+	}
+
+	// 先验证本包披露的 K_{i-d}，伪造的披露使本包被丢弃
+	var verified [][]byte
+	if typ == TypeAuthTag && i > v.params.DisclosureDelay {
+		verified, err = v.disclose(i-v.params.DisclosureDelay, ext[8:8+KeyLength])
+		if err != nil {
+			return nil, v.drop(err)
+		}
+	}
+
+	macStart := offset + 8 + keyLen
+	data := append([]byte(nil), pkt...)
+	mac := append([]byte(nil), data[macStart:macStart+macLen]...)
+	for k := macStart; k < macStart+macLen; k++ {
+		data[k] = 0
+	}
+	v.pending[i] = append(v.pending[i], pendingPkt{data: data, mac: mac, raw: pkt})
+	v.nbPending++
+	return verified, nil
+}
+
+// currentInterval 由 Bootstrap 的时间同步得到发送端此刻最多所处的区间（与 Sender 相同，从 1 开始）
+func (v *Verifier) currentInterval(now time.Time) uint32 {
+	j, ok := v.params.IntervalAt(v.sync.SenderTimeUpperBound(now))
+	if !ok {
+		return 0
+	}
+	return j + 1
+}
+
+func (v *Verifier) isSafe(i uint32, now time.Time) bool {
+	upper := v.sync.SenderTimeUpperBound(now)
+	j, ok := v.params.IntervalAt(upper)
+	if !ok {
+		return true
+	}
+	return uint64(j)+1 < uint64(i)+uint64(v.params.DisclosureDelay)
+}
+
+// disclose 验证披露的 K_j 并处理区间 (lastIndex, j] 内缓存的包
+func (v *Verifier) disclose(j uint32, key []byte) ([][]byte, error) {
+	if j <= v.lastIndex {
+		return nil, nil
+	}
+
+	// K_j 向下推导，得到 lastIndex..j 的全部密钥
+	keys := make([][]byte, j-v.lastIndex+1)
+	keys[len(keys)-1] = append([]byte(nil), key...)
+	for x := len(keys) - 1; x > 0; x-- {
+		keys[x-1] = F(keys[x])
+	}
+	if !bytes.Equal(keys[0], v.lastKey) {
+		v.stats.BadKey++
+		return nil, ErrBadKey
+	}
+
+	intervals := make([]uint32, 0)
+	for idx := range v.pending {
+		if idx <= j {
+			intervals = append(intervals, idx)
+		}
+	}
+	sort.Slice(intervals, func(a, b int) bool { return intervals[a] < intervals[b] })
+
+	out := make([][]byte, 0)
+	for _, idx := range intervals {
+		macKey := FPrime(keys[idx-v.lastIndex])
+		for _, p := range v.pending[idx] {
+			if hmac.Equal(computeMAC(macKey, p.data, len(p.mac)), p.mac) {
+				v.stats.Verified++
+				out = append(out, p.raw)
+			} else {
+				v.stats.BadMAC++
+				v.stats.Dropped++
+			}
+			v.nbPending--
+		}
+		delete(v.pending, idx)
+	}
+
+	v.lastIndex = j
+	v.lastKey = keys[len(keys)-1]
+	return out, nil
+}
+
+func (v *Verifier) drop(err error) error {
+	v.stats.Dropped++
+	return err
+}
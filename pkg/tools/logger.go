@@ -0,0 +1,19 @@
+package tools
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LoggerOrDefault 未注入 logger 时使用 slog.Default()
+func LoggerOrDefault(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return slog.Default()
+	}
+	return l
+}
+
+// DebugEnabled 热路径门控：Debug 关闭时连参数都不构造
+func DebugEnabled(l *slog.Logger) bool {
+	return l != nil && l.Enabled(context.Background(), slog.LevelDebug)
+}
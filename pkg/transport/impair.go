@@ -0,0 +1,393 @@
+package transport
+
+import (
+	"Flute_go/pkg/clock"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Packet 经过损伤流水线的包；At 为计划送达时间
+type Packet struct {
+	Data []byte
+	Addr net.Addr
+	At   time.Time
+}
+
+// Stage 损伤流水线中的一级：输入本次的包，返回要继续传递的包（可丢弃、复制、修改或暂存）
+// 同一随机源按包顺序使用，相同种子得到相同结果
+type Stage interface {
+	Apply(pkts []Packet, rng *rand.Rand) []Packet
+}
+
+// flusher 暂存包的 Stage（如 Reorder）在结束时交出剩余的包
+type flusher interface {
+	Flush() []Packet
+	Held() int
+}
+
+// RandomLoss 独立随机丢包，概率 P
+type RandomLoss struct {
+	P float64
+}
+
+func (s RandomLoss) Apply(pkts []Packet, rng *rand.Rand) []Packet {
+	out := pkts[:0]
+	for _, p := range pkts {
+		if rng.Float64() >= s.P {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// GilbertElliott 两状态突发丢包模型
+// 好状态以概率 P 进入坏状态，坏状态以概率 R 回到好状态；各状态内的丢包率为 LossGood/LossBad
+type GilbertElliott struct {
+	P, R              float64
+	LossGood, LossBad float64
+	bad               bool
+}
+
+// NewGilbertElliott 经典 Gilbert 模型：好状态不丢包，坏状态全丢，平均突发长度 1/r
+func NewGilbertElliott(p, r float64) *GilbertElliott {
+	return &GilbertElliott{P: p, R: r, LossGood: 0, LossBad: 1}
+}
+
+func (s *GilbertElliott) Apply(pkts []Packet, rng *rand.Rand) []Packet {
+	out := pkts[:0]
+	for _, p := range pkts {
+		if s.bad {
+			s.bad = rng.Float64() >= s.R
+		} else {
+			s.bad = rng.Float64() < s.P
+		}
+		loss := s.LossGood
+		if s.bad {
+			loss = s.LossBad
+		}
+		if rng.Float64() >= loss {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Duplicate 以概率 P 复制一个包
+type Duplicate struct {
+	P float64
+}
+
+func (s Duplicate) Apply(pkts []Packet, rng *rand.Rand) []Packet {
+	out := make([]Packet, 0, len(pkts))
+	for _, p := range pkts {
+		out = append(out, p)
+		if rng.Float64() < s.P {
+			dup := p
+			dup.Data = append([]byte(nil), p.Data...)
+			out = append(out, dup)
+		}
+	}
+	return out
+}
+
+// Delay 固定时延 Base 加 [0, Jitter) 均匀抖动；抖动大于包间隔时会造成乱序
+type Delay struct {
+	Base   time.Duration
+	Jitter time.Duration
+}
+
+func (s Delay) Apply(pkts []Packet, rng *rand.Rand) []Packet {
+	for i := range pkts {
+		d := s.Base
+		if s.Jitter > 0 {
+			d += time.Duration(rng.Int63n(int64(s.Jitter)))
+		}
+		pkts[i].At = pkts[i].At.Add(d)
+	}
+	return pkts
+}
+
+// Reorder 以概率 P 暂存一个包，在其后 Gap 个包发出之后再发出（Gap 0 = 1）
+type Reorder struct {
+	P    float64
+	Gap  int
+	held []heldPacket
+}
+
+type heldPacket struct {
+	pkt  Packet
+	left int
+}
+
+func (s *Reorder) Apply(pkts []Packet, rng *rand.Rand) []Packet {
+	gap := max(s.Gap, 1)
+	out := make([]Packet, 0, len(pkts))
+	for _, p := range pkts {
+		if rng.Float64() < s.P {
+			s.held = append(s.held, heldPacket{pkt: p, left: gap})
+			continue
+		}
+		out = append(out, p)
+		kept := s.held[:0]
+		for _, h := range s.held {
+			if h.left--; h.left == 0 {
+				// 不早于超过它的包送达
+				h.pkt.At = maxTime(h.pkt.At, p.At)
+				out = append(out, h.pkt)
+			} else {
+				kept = append(kept, h)
+			}
+		}
+		s.held = kept
+	}
+	return out
+}
+
+func (s *Reorder) Held() int {
+	return len(s.held)
+}
+
+func (s *Reorder) Flush() []Packet {
+	out := make([]Packet, 0, len(s.held))
+	for _, h := range s.held {
+		out = append(out, h.pkt)
+	}
+	s.held = nil
+	return out
+}
+
+// Truncate 以概率 P 把包截短为随机长度 [1, len)
+type Truncate struct {
+	P float64
+}
+
+func (s Truncate) Apply(pkts []Packet, rng *rand.Rand) []Packet {
+	for i := range pkts {
+		if n := len(pkts[i].Data); n > 1 && rng.Float64() < s.P {
+			pkts[i].Data = pkts[i].Data[:1+rng.Intn(n-1)]
+		}
+	}
+	return pkts
+}
+
+// ImpairStats 进入与离开损伤流水线的包数
+type ImpairStats struct {
+	In  uint64
+	Out uint64
+}
+
+// ImpairedConn 在 PacketConn 外包一层损伤流水线，可多层嵌套组合
+// 用在发送端时对 WriteTo 生效，用在接收端时对 ReadFrom 生效
+// 发送端延迟的包在之后的写入或 Flush 时按送达时间释放；接收端 ReadFrom 在等待内层读取的同时
+// 用 clock 定时器等待最早的送达时间，因此在虚拟时钟下完全可复现
+type ImpairedConn struct {
+	inner  PacketConn
+	clock  clock.Clock
+	stages []Stage
+
+	mu      sync.Mutex
+	rng     *rand.Rand
+	queue   []Packet // 按 At 排序
+	writing bool
+	stats   ImpairStats
+
+	// 接收端：同一时刻至多一个内层读取在进行，完成后通知 readDone
+	rbuf     []byte
+	reading  bool
+	readErr  error
+	readDone chan struct{}
+}
+
+// NewImpairedConn stages 按顺序执行；seed 相同且输入相同时输出相同；clk 为 nil 使用系统时钟
+func NewImpairedConn(inner PacketConn, seed int64, clk clock.Clock, stages ...Stage) *ImpairedConn {
+	return &ImpairedConn{
+		inner:    inner,
+		clock:    clock.OrReal(clk),
+		stages:   stages,
+		rng:      rand.New(rand.NewSource(seed)),
+		readDone: make(chan struct{}, 1),
+	}
+}
+
+// WriteTo 与 UDP 一样，被丢弃的包也视为写入成功
+func (c *ImpairedConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writing = true
+	now := c.clock.Now()
+	c.push(Packet{Data: append([]byte(nil), p...), Addr: addr, At: now})
+	if err := c.writeDueLocked(now, false); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ReadFrom 返回已到送达时间的包；否则等待内层读取或最早的延迟包到期。内层关闭后交出剩余的包，再返回错误
+func (c *ImpairedConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		c.mu.Lock()
+		now := c.clock.Now()
+		if pkt, ok := c.popLocked(now, false); ok {
+			c.mu.Unlock()
+			return copy(p, pkt.Data), pkt.Addr, nil
+		}
+		if c.readErr != nil {
+			c.flushStagesLocked()
+			pkt, ok := c.popLocked(time.Time{}, true)
+			err := c.readErr
+			c.mu.Unlock()
+			if ok {
+				return copy(p, pkt.Data), pkt.Addr, nil
+			}
+			return 0, nil, err
+		}
+		if !c.reading {
+			c.reading = true
+			go c.readInner()
+		}
+		var due <-chan time.Time
+		if len(c.queue) > 0 {
+			due = c.clock.After(c.queue[0].At.Sub(now))
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-c.readDone:
+		case <-due:
+		}
+	}
+}
+
+// readInner 读取一个内层包送入流水线；内层的读取无法取消，因此放在单独的协程中
+func (c *ImpairedConn) readInner() {
+	if c.rbuf == nil {
+		c.rbuf = make([]byte, 65536)
+	}
+	n, addr, err := c.inner.ReadFrom(c.rbuf)
+	c.mu.Lock()
+	if err != nil {
+		c.readErr = err
+	} else {
+		c.push(Packet{Data: append([]byte(nil), c.rbuf[:n]...), Addr: addr, At: c.clock.Now()})
+	}
+	c.reading = false
+	c.mu.Unlock()
+	select {
+	case c.readDone <- struct{}{}:
+	default: // 已有未取走的通知
+	}
+}
+
+// Flush 写出已到送达时间的包（发送端在空闲时调用），再刷新内层连接
+func (c *ImpairedConn) Flush() error {
+	c.mu.Lock()
+	err := c.writeDueLocked(c.clock.Now(), false)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return Flush(c.inner)
+}
+
+// Close 发送端：写出全部暂存/延迟中的包后关闭内层连接
+func (c *ImpairedConn) Close() error {
+	c.mu.Lock()
+	var err error
+	if c.writing {
+		c.flushStagesLocked()
+		err = c.writeDueLocked(time.Time{}, true)
+	}
+	c.mu.Unlock()
+	if cerr := c.inner.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (c *ImpairedConn) LocalAddr() net.Addr {
+	return c.inner.LocalAddr()
+}
+
+func (c *ImpairedConn) Stats() ImpairStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Pending 延迟或暂存中的包数
+func (c *ImpairedConn) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.queue)
+	for _, s := range c.stages {
+		if f, ok := s.(flusher); ok {
+			n += f.Held()
+		}
+	}
+	return n
+}
+
+func (c *ImpairedConn) push(p Packet) {
+	c.stats.In++
+	pkts := []Packet{p}
+	for _, s := range c.stages {
+		pkts = s.Apply(pkts, c.rng)
+	}
+	c.enqueue(pkts)
+}
+
+// flushStagesLocked 取出各级暂存的包，并继续经过其后的各级
+func (c *ImpairedConn) flushStagesLocked() {
+	for i, s := range c.stages {
+		f, ok := s.(flusher)
+		if !ok {
+			continue
+		}
+		pkts := f.Flush()
+		for _, next := range c.stages[i+1:] {
+			pkts = next.Apply(pkts, c.rng)
+		}
+		c.enqueue(pkts)
+	}
+}
+
+func (c *ImpairedConn) enqueue(pkts []Packet) {
+	for _, p := range pkts {
+		i := sort.Search(len(c.queue), func(i int) bool { return c.queue[i].At.After(p.At) })
+		c.queue = append(c.queue, Packet{})
+		copy(c.queue[i+1:], c.queue[i:])
+		c.queue[i] = p
+	}
+}
+
+func (c *ImpairedConn) popLocked(now time.Time, all bool) (Packet, bool) {
+	if len(c.queue) == 0 || (!all && c.queue[0].At.After(now)) {
+		return Packet{}, false
+	}
+	p := c.queue[0]
+	c.queue = c.queue[1:]
+	c.stats.Out++
+	return p, true
+}
+
+func (c *ImpairedConn) writeDueLocked(now time.Time, all bool) error {
+	for {
+		p, ok := c.popLocked(now, all)
+		if !ok {
+			return nil
+		}
+		if _, err := c.inner.WriteTo(p.Data, p.Addr); err != nil {
+			return err
+		}
+	}
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
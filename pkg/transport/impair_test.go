@@ -0,0 +1,171 @@
+package transport
+
+import (
+	"Flute_go/pkg/clock"
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLoopback(t *testing.T) {
+	lb := NewLoopback(0)
+	tx := lb.Join("sender")
+	rx1 := lb.Join("rx1")
+	rx2 := lb.Join("rx2")
+
+	if _, err := tx.WriteTo([]byte("hello"), nil); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	buf := make([]byte, 16)
+	for _, rx := range []*LoopbackConn{rx1, rx2} {
+		n, from, err := rx.ReadFrom(buf)
+		if err != nil || string(buf[:n]) != "hello" || from.String() != "sender" {
+			t.Fatalf("unexpected read %q from %v: %v", buf[:n], from, err)
+		}
+	}
+
+	// 关闭后先读完队列，再返回 ErrClosed
+	tx.WriteTo([]byte("last"), nil)
+	lb.Close()
+	if n, _, err := rx1.ReadFrom(buf); err != nil || string(buf[:n]) != "last" {
+		t.Fatalf("queued packet lost on close: %q %v", buf[:n], err)
+	}
+	if _, _, err := rx1.ReadFrom(buf); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected net.ErrClosed, got %v", err)
+	}
+}
+
+// 经过损伤后收到的包序列
+func impairedRun(seed int64, stages ...Stage) [][]byte {
+	lb := NewLoopback(4096)
+	tx := NewImpairedConn(lb.Join("tx"), seed, nil, stages...)
+	rx := lb.Join("rx")
+	for i := 0; i < 1000; i++ {
+		tx.WriteTo(bytes.Repeat([]byte{byte(i)}, 1+i%50), nil)
+	}
+	tx.Close()
+	lb.Close()
+	var out [][]byte
+	buf := make([]byte, 64)
+	for {
+		n, _, err := rx.ReadFrom(buf)
+		if err != nil {
+			return out
+		}
+		out = append(out, append([]byte(nil), buf[:n]...))
+	}
+}
+
+func TestImpairedConnReproducible(t *testing.T) {
+	stages := func() []Stage {
+		return []Stage{
+			RandomLoss{P: 0.05},
+			NewGilbertElliott(0.02, 0.3),
+			Duplicate{P: 0.05},
+			&Reorder{P: 0.05, Gap: 3},
+			Truncate{P: 0.05},
+		}
+	}
+	a := impairedRun(42, stages()...)
+	b := impairedRun(42, stages()...)
+	if len(a) != len(b) {
+		t.Fatalf("same seed, different packet count: %d vs %d", len(a), len(b))
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			t.Fatalf("same seed, packet %d differs", i)
+		}
+	}
+	if c := impairedRun(7, stages()...); len(c) == len(a) && bytes.Equal(bytes.Join(c, nil), bytes.Join(a, nil)) {
+		t.Fatalf("different seeds must give different runs")
+	}
+	if len(a) >= 1000 || len(a) < 700 {
+		t.Fatalf("unexpected number of delivered packets %d", len(a))
+	}
+
+	// 仅乱序：包不丢，只是顺序变化
+	r := impairedRun(1, &Reorder{P: 0.1, Gap: 2})
+	if len(r) != 1000 {
+		t.Fatalf("reorder must not lose packets, got %d", len(r))
+	}
+	inOrder := true
+	for i := range r {
+		if r[i][0] != byte(i) {
+			inOrder = false
+		}
+	}
+	if inOrder {
+		t.Fatalf("expected reordered packets")
+	}
+}
+
+func TestImpairedConnDelay(t *testing.T) {
+	clk := clock.NewVirtual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	lb := NewLoopback(0)
+	tx := NewImpairedConn(lb.Join("tx"), 1, clk, Delay{Base: 100 * time.Millisecond, Jitter: 50 * time.Millisecond})
+	rx := lb.Join("rx")
+
+	for i := 0; i < 10; i++ {
+		tx.WriteTo([]byte{byte(i)}, nil)
+	}
+	if tx.Pending() != 10 {
+		t.Fatalf("packets must be held until their delivery time, pending %d", tx.Pending())
+	}
+	clk.Advance(120 * time.Millisecond)
+	tx.Flush()
+	early := tx.Stats().Out
+	clk.Advance(100 * time.Millisecond)
+	tx.Flush()
+	if early == 0 || early == 10 || tx.Stats().Out != 10 || tx.Pending() != 0 {
+		t.Fatalf("unexpected release: %d after 120ms, %+v", early, tx.Stats())
+	}
+	buf := make([]byte, 1)
+	for i := 0; i < 10; i++ {
+		if _, _, err := rx.ReadFrom(buf); err != nil {
+			t.Fatalf("ReadFrom failed: %v", err)
+		}
+	}
+}
+
+func TestImpairedConnReadDelay(t *testing.T) {
+	clk := clock.NewVirtual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	lb := NewLoopback(0)
+	tx := lb.Join("tx")
+	rx := NewImpairedConn(lb.Join("rx"), 1, clk, Delay{Base: 100 * time.Millisecond})
+
+	done := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 16)
+		_, _, err := rx.ReadFrom(buf)
+		done <- err
+	}()
+	tx.WriteTo([]byte("hello"), nil)
+
+	// 等包进入延迟队列、定时器注册之后再推进时钟
+	deadline := time.Now().Add(2 * time.Second)
+	for rx.Pending() != 1 || clk.Pending() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("packet not queued (pending %d, timers %d)", rx.Pending(), clk.Pending())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("packet released before its delivery time: %v", err)
+	default:
+	}
+
+	// 不再有后续包到达，到期后也必须释放
+	clk.Advance(100 * time.Millisecond)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ReadFrom failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("delayed packet not released without further traffic")
+	}
+	lb.Close()
+}
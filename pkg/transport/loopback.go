@@ -0,0 +1,149 @@
+package transport
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// LoopbackAddr 进程内连接的地址
+type LoopbackAddr string
+
+func (a LoopbackAddr) Network() string { return "loopback" }
+func (a LoopbackAddr) String() string  { return string(a) }
+
+// Loopback 进程内的“组播组”：任一成员写入的包投递给其他所有成员，不经过网络
+// 与 UDP 一样不做流控：接收队列满时丢包（见 LoopbackConn.Dropped）
+type Loopback struct {
+	mu       sync.Mutex
+	members  []*LoopbackConn
+	queueLen int
+}
+
+type loopbackPkt struct {
+	data []byte
+	from net.Addr
+}
+
+// NewLoopback queueLen 为每个成员的接收队列长度（包），0 = 1024
+func NewLoopback(queueLen int) *Loopback {
+	if queueLen <= 0 {
+		queueLen = 1024
+	}
+	return &Loopback{queueLen: queueLen}
+}
+
+// Join 加入组，name 作为该成员的本地地址
+func (l *Loopback) Join(name string) *LoopbackConn {
+	c := &LoopbackConn{
+		hub:    l,
+		addr:   LoopbackAddr(name),
+		ch:     make(chan loopbackPkt, l.queueLen),
+		closed: make(chan struct{}),
+	}
+	l.mu.Lock()
+	l.members = append(l.members, c)
+	l.mu.Unlock()
+	return c
+}
+
+// Close 关闭全部成员，阻塞中的 ReadFrom 在读完队列后返回 net.ErrClosed
+func (l *Loopback) Close() error {
+	l.mu.Lock()
+	members := append([]*LoopbackConn(nil), l.members...)
+	l.mu.Unlock()
+	for _, c := range members {
+		c.Close()
+	}
+	return nil
+}
+
+func (l *Loopback) leave(c *LoopbackConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, m := range l.members {
+		if m == c {
+			l.members = append(l.members[:i], l.members[i+1:]...)
+			return
+		}
+	}
+}
+
+func (l *Loopback) deliver(from *LoopbackConn, p []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, m := range l.members {
+		if m == from {
+			continue
+		}
+		select {
+		case m.ch <- loopbackPkt{data: append([]byte(nil), p...), from: from.addr}:
+		default:
+			m.dropped.Add(1)
+		}
+	}
+}
+
+// LoopbackConn Loopback 的成员，可并发读写
+type LoopbackConn struct {
+	hub       *Loopback
+	addr      LoopbackAddr
+	ch        chan loopbackPkt
+	closed    chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Uint64
+}
+
+// WriteTo 投递给组内其他成员，addr 被忽略
+func (c *LoopbackConn) WriteTo(p []byte, _ net.Addr) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	c.hub.deliver(c, p)
+	return len(p), nil
+}
+
+// ReadFrom 包长超过 p 时截断（与 UDP 一致）
+func (c *LoopbackConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	// 关闭后仍先读完已排队的包
+	select {
+	case pkt := <-c.ch:
+		return copy(p, pkt.data), pkt.from, nil
+	default:
+	}
+	select {
+	case pkt := <-c.ch:
+		return copy(p, pkt.data), pkt.from, nil
+	case <-c.closed:
+		select {
+		case pkt := <-c.ch:
+			return copy(p, pkt.data), pkt.from, nil
+		default:
+			return 0, nil, net.ErrClosed
+		}
+	}
+}
+
+func (c *LoopbackConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.hub.leave(c)
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *LoopbackConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+// Dropped 因接收队列满而丢弃的包数
+func (c *LoopbackConn) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
+// Queued 接收队列中待读的包数；单协程仿真可据此非阻塞地读取
+func (c *LoopbackConn) Queued() int {
+	return len(c.ch)
+}
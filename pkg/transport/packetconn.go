@@ -0,0 +1,28 @@
+package transport
+
+import "net"
+
+// PacketConn 发送循环与接收端使用的最小包接口
+// net.PacketConn（UDP socket）、LoopbackConn 与 ImpairedConn 均满足该接口，可以互相替换
+type PacketConn interface {
+	// ReadFrom 阻塞读取一个包；连接关闭后返回 net.ErrClosed
+	ReadFrom(p []byte) (n int, addr net.Addr, err error)
+	WriteTo(p []byte, addr net.Addr) (n int, err error)
+	Close() error
+	LocalAddr() net.Addr
+}
+
+var _ PacketConn = net.PacketConn(nil)
+
+// Flusher 带缓冲的连接（延迟队列、TS 打包输出等），发送端空闲时应调用 Flush
+type Flusher interface {
+	Flush() error
+}
+
+// Flush c 实现 Flusher 时调用其 Flush，否则什么也不做；包装其他连接的实现应逐层向内传递
+func Flush(c PacketConn) error {
+	if f, ok := c.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}